@@ -13,7 +13,10 @@ package actor // import "tideland.dev/go/actor"
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"runtime/pprof"
+	"sync"
 	"sync/atomic"
 	"time"
 )
@@ -28,6 +31,19 @@ const (
 	defaultQueueCap = 256
 )
 
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrDone is returned by all calls to an Actor that is stopped,
+// stopping, or handing over its work. An Actor stopped due to an
+// error returns that error instead, a migrated one ErrMigrated.
+var ErrDone = errors.New("actor is done")
+
+// ErrInvalid is returned if an argument like a nil Action or a
+// nil context is passed. The Actor is not affected.
+var ErrInvalid = errors.New("invalid argument")
+
 //--------------------
 // HELPER
 //--------------------
@@ -35,6 +51,10 @@ const (
 // Action defines the signature of an actor action.
 type Action func()
 
+// ContextAction defines the signature of an actor action receiving
+// the context of its request as possibly augmented by interceptors.
+type ContextAction func(ctx context.Context)
+
 // Recoverer defines the signature of a function for recovering
 // from a panic during executing an action. The reason is the
 // panic value. The function should return the error to be
@@ -53,71 +73,274 @@ type Finalizer func(err error) error
 
 // request wraps an action with its context.
 type request struct {
-	ctx    context.Context
-	done   chan struct{}
-	err    error
-	action Action
+	ctx       context.Context
+	submitted time.Time
+	sequence  uint64
+	done      chan struct{}
+	err       error
+	action    ContextAction
+	origin    any
+	marker    bool
+	read      bool
+	sync      bool
+	exempt    bool
+	enqueued  bool
+	arrival   uint64
+	started   atomic.Bool
+	ext       *requestExt
 }
 
-// newRequest creates a request including a done channel. The
-// Action is wrapped with a closure which closes the done channel
-// after the action has been executed.
-func newRequest(ctx context.Context, action Action) *request {
+// requestExt contains the request data only needed by optional
+// features. It is allocated by the first one using it, so requests
+// of Actors not using them stay small.
+type requestExt struct {
+	awaited      bool
+	finished     time.Time
+	eventTime    time.Time
+	size         int64
+	quotaKey     string
+	quotaCounted bool
+	slotted      bool
+	delay        time.Duration
+	class        PriorityClass
+	priority     *priority
+}
+
+// extension returns the extension of the request, allocating
+// it if needed.
+func (req *request) extension() *requestExt {
+	if req.ext == nil {
+		req.ext = &requestExt{}
+	}
+	return req.ext
+}
+
+// newRequest creates a request including a done channel and
+// stamps it with its submission time.
+func newRequest(ctx context.Context, action ContextAction) *request {
 	return &request{
-		ctx:    ctx,
-		done:   make(chan struct{}),
-		action: action,
+		ctx:       ctx,
+		submitted: time.Now(),
+		done:      make(chan struct{}),
+		action:    action,
+		origin:    action,
 	}
 }
 
+// newActionRequest creates a request for a plain Action. The Action
+// is kept as origin for introspection.
+func newActionRequest(ctx context.Context, action Action) *request {
+	req := newRequest(ctx, func(context.Context) { action() })
+	req.origin = action
+	return req
+}
+
+// newMarker creates an internal request marking a position in the
+// queue. It is neither stamped nor intercepted.
+func newMarker() *request {
+	req := newRequest(context.Background(), func(context.Context) {})
+	req.marker = true
+	return req
+}
+
 // execute checks if the request context is canceled or timed out.
-// If not, it performs the action and closes the done channel.
-func (req *request) execute() {
-	defer close(req.done)
+// If not, it stamps the request with the next apply sequence, passes
+// the request metadata through the interceptors of the Actor, and
+// performs the action. Finally it closes the done channel.
+func (req *request) execute(act *Actor) {
+	defer req.finish()
 	select {
 	case <-req.ctx.Done():
 		req.err = req.ctx.Err()
+		return
 	default:
-		req.action()
 	}
+	if req.marker {
+		req.action(req.ctx)
+		return
+	}
+	req.sequence = act.sequence.Add(1)
+	ctx := act.stamp(req.ctx, req)
+	if len(act.interceptors) > 0 {
+		// Only build the metadata if anyone looks at it.
+		meta := &RequestMeta{
+			Context:        ctx,
+			Submitted:      req.submitted,
+			Sequence:       req.sequence,
+			WatchdogExempt: req.exempt,
+			origin:         req.origin,
+		}
+		for _, intercept := range act.interceptors {
+			if err := intercept(meta); err != nil {
+				req.err = err
+				return
+			}
+		}
+		if meta.Context == nil {
+			req.err = fmt.Errorf("%w: nil context set by interceptor", ErrInvalid)
+			return
+		}
+		ctx = meta.Context
+	}
+	act.chaosLatency(req)
+	req.action(ctx)
+	act.chaosComplete(req)
+}
+
+// finish stamps an awaited request with its finishing time and
+// closes its done channel.
+func (req *request) finish() {
+	if req.ext != nil && req.ext.awaited {
+		req.ext.finished = time.Now()
+	}
+	close(req.done)
 }
 
 // Actor introduces the actor model, where call simply are executed
 // sequentially in a backend goroutine.
 type Actor struct {
-	ctx       context.Context
-	cancel    func()
-	requests  chan *request
-	recoverer Recoverer
-	finalizer Finalizer
-	err       atomic.Pointer[error]
-	done      chan struct{}
+	parentCtx        context.Context
+	started          time.Time
+	stopped          atomic.Pointer[time.Time]
+	ctx              context.Context
+	cancel           func()
+	noContextWrap    bool
+	stopInitiated    atomic.Bool
+	released         atomic.Bool
+	requests         chan *request
+	ordering         SubmitOrdering
+	orderingWindow   time.Duration
+	pending          []*request
+	pendingLen       atomic.Int64
+	arrivals         uint64
+	lastArrival      uint64
+	reorders         atomic.Uint64
+	events           []*request
+	eventDelay       time.Duration
+	latePolicy       LatePolicy
+	watermark        time.Time
+	eventTimer       *time.Timer
+	goroutineID      uint64
+	recoverer        Recoverer
+	finalizer        Finalizer
+	summaryFinalizer SummaryFinalizer
+	closers          []func() error
+	calibration      *calibration
+	dispatcher       *dispatcher
+	pendingBytes     *pendingBytes
+	quota            *quota
+	afterNs          []*afterN
+	syncBudget       atomic.Int64
+	slots            *slots
+	autoScale        atomic.Pointer[autoScale]
+	autoScaleOnce    sync.Once
+	stopWhen         func() bool
+	conditions       []*condition
+	labels           *pprof.LabelSet
+	idempotency      idempotency
+	hlc              hlc
+	hlcEnabled       atomic.Bool
+	lastTimestamp    atomic.Pointer[HLCTimestamp]
+	errorBackoff     errorBackoff
+	duplicates       atomic.Pointer[duplicates]
+	coalesced        atomic.Uint64
+	occupancy        occupancy
+	spill            atomic.Pointer[spill]
+	spillConfig      *spillConfig
+	chaos            atomic.Pointer[chaos]
+	panics           atomic.Uint64
+	maxPanics        uint64
+	watchers         []*fieldWatcher
+	dropped          int
+	observability    atomic.Pointer[Observability]
+	history          history
+	watchdog         time.Duration
+	onStuck          func()
+	executing        atomic.Pointer[execution]
+	current          *request
+	crashDumper      CrashDumper
+	summarizer       func() string
+	interceptors     []Interceptor
+	sequence         atomic.Uint64
+	received         atomic.Uint64
+	handled          atomic.Uint64
+	queueIndex       *queueIndex
+	throttles        throttles
+	lockMu           sync.Mutex
+	lockRelease      chan struct{}
+	lockTimeout      time.Duration
+	stopping         atomic.Bool
+	drainReads       bool
+	progressInterval time.Duration
+	wallClock        func() time.Time
+	scheduleCadence  time.Duration
+	tracked          tracked
+	id               uint64
+	tokens           tokens
+	commits          commits
+	priority         atomic.Pointer[priority]
+	userData         any
+	shutdownTimeout  time.Duration
+	handingOver      atomic.Bool
+	migration        atomic.Pointer[migration]
+	sending          atomic.Int64
+	err              atomic.Pointer[error]
+	cause            atomic.Pointer[error]
+	finalizerErr     atomic.Pointer[error]
+	done             chan struct{}
 }
 
 // Go starts an Actor with the given options.
 func Go(options ...Option) (*Actor, error) {
 	// Init with options.
 	act := &Actor{
-		ctx: context.Background(),
+		id:   actorIDs.Add(1),
+		ctx:  context.Background(),
+		done: make(chan struct{}),
 	}
 	for _, option := range options {
 		if err := option(act); err != nil {
 			return nil, err
 		}
 	}
+	// Check the limit of live Actors.
+	if err := acquireActor(); err != nil {
+		return nil, err
+	}
 	// Ensure default settings.
-	act.ctx, act.cancel = context.WithCancel(act.ctx)
+	act.started = time.Now()
+	act.occupancy.base = act.started
+	act.parentCtx = act.ctx
+	if act.noContextWrap {
+		sc := newStopContext(act.ctx)
+		act.ctx, act.cancel = sc, sc.stop
+	} else {
+		act.ctx, act.cancel = context.WithCancel(act.ctx)
+	}
 	if act.requests == nil {
 		act.requests = make(chan *request, defaultQueueCap)
 	}
+	act.initSlots()
+	if act.orderingWindow <= 0 {
+		act.orderingWindow = defaultOrderingWindow
+	}
 	if act.recoverer == nil {
 		act.recoverer = func(reason any) error {
+			if err, ok := reason.(error); ok {
+				return fmt.Errorf("panic during actor action: %w", err)
+			}
 			return fmt.Errorf("panic during actor action: %v", reason)
 		}
 	}
 	if act.finalizer == nil {
 		act.finalizer = func(err error) error { return err }
 	}
+	if act.observability.Load() == nil {
+		act.observability.Store(&Observability{BlockingReporter: logBlocking})
+	}
+	if act.dispatcher != nil {
+		go act.dispatcher.run(act.done)
+	}
 	// Start the backend, wait for it to be ready.
 	started := make(chan struct{})
 
@@ -126,8 +349,19 @@ func Go(options ...Option) (*Actor, error) {
 	select {
 	case <-started:
 	case <-time.After(time.Second):
+		// The caller never gets the Actor, so stop it here.
+		act.cancel()
+		act.release()
 		return nil, fmt.Errorf("actor backend did not start")
 	}
+	if err := act.startSpill(); err != nil {
+		act.Stop()
+		<-act.Done()
+		return nil, err
+	}
+	if act.autoScale.Load() != nil {
+		act.startAutoScale()
+	}
 	return act, nil
 }
 
@@ -140,8 +374,41 @@ func (act *Actor) DoAsync(action Action) error {
 // DoAsyncWithContext send the actor function to the backend and returns
 // when it's queued. A context allows to cancel the action or add a timeout.
 func (act *Actor) DoAsyncWithContext(ctx context.Context, action Action) error {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return err
+	}
+	req := newActionRequest(ctx, action)
+	return act.sendAsync(req)
+}
+
+// DoSyncSequenced executes the action like DoSyncWithContext and
+// additionally returns the apply sequence the Actor stamped it with.
+// The sequences are monotonically increasing in the order the Actor
+// executes the Actions, so concurrent producers can later replay their
+// changes in the same order.
+func (act *Actor) DoSyncSequenced(ctx context.Context, action Action) (uint64, error) {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return 0, err
+	}
+	req := newActionRequest(ctx, action)
+	if err := act.sendSync(req); err != nil {
+		return 0, err
+	}
+	if err := act.wait(req); err != nil {
+		return 0, err
+	}
+	return req.sequence, nil
+}
+
+// DoAsyncCtx sends the context action to the backend and returns when
+// it's queued. The action receives the context as augmented by the
+// interceptors.
+func (act *Actor) DoAsyncCtx(ctx context.Context, action ContextAction) error {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return err
+	}
 	req := newRequest(ctx, action)
-	return act.send(req)
+	return act.sendAsync(req)
 }
 
 // DoSync executes the actor function and returns when it's done.
@@ -152,8 +419,42 @@ func (act *Actor) DoSync(action Action) error {
 // DoSyncWithContext executes the action and returns when it's done.
 // A context allows to cancel the action or add a timeout.
 func (act *Actor) DoSyncWithContext(ctx context.Context, action Action) error {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return err
+	}
+	req := newActionRequest(ctx, action)
+	err := act.sendSync(req)
+	if err != nil {
+		return err
+	}
+	return act.wait(req)
+}
+
+// DoSyncCtx executes the context action and returns when it's done.
+// The action receives the context as augmented by the interceptors.
+func (act *Actor) DoSyncCtx(ctx context.Context, action ContextAction) error {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return err
+	}
 	req := newRequest(ctx, action)
-	err := act.send(req)
+	err := act.sendSync(req)
+	if err != nil {
+		return err
+	}
+	return act.wait(req)
+}
+
+// DoRead executes the Action synchronously like DoSyncWithContext but
+// marks it as read. The Action must not change the state guarded by
+// the Actor. With WithDrainReads reads are still admitted while
+// StopGraceful drains the queue.
+func (act *Actor) DoRead(ctx context.Context, action Action) error {
+	if err := act.admitFor(ctx, action != nil, true); err != nil {
+		return err
+	}
+	req := newActionRequest(ctx, action)
+	req.read = true
+	err := act.sendSync(req)
 	if err != nil {
 		return err
 	}
@@ -176,6 +477,25 @@ func (act *Actor) IsDone() bool {
 	}
 }
 
+// InActor tells if the caller runs in the backend goroutine of the
+// Actor, e.g. inside one of its Actions. Helpers shared by Actions and
+// other code can use it to avoid sending to their own Actor, which
+// would deadlock.
+func (act *Actor) InActor() bool {
+	return currentGoroutineID() == act.goroutineID
+}
+
+// UserData returns the data attached with WithUserData.
+func (act *Actor) UserData() any {
+	return act.userData
+}
+
+// Sequence returns the apply sequence of the latest Action
+// the Actor started to execute.
+func (act *Actor) Sequence() uint64 {
+	return act.sequence.Load()
+}
+
 // Err returns information if the Actor has an error.
 func (act *Actor) Err() error {
 	err := act.err.Load()
@@ -185,34 +505,206 @@ func (act *Actor) Err() error {
 	return *err
 }
 
-// Stop terminates the Actor backend.
+// Stop terminates the Actor backend. Synchronous callers of Actions
+// still queued receive ErrDone, or the error of the Actor if it failed.
+// The caller of an Action currently executed receives the context
+// error of the Actor, the Action itself is finished.
 func (act *Actor) Stop() {
+	act.TryStop()
+}
+
+// TryStop terminates the Actor backend like Stop. It returns true
+// only for the one caller actually initiating the termination, e.g.
+// to let only one component run the shutdown cleanup. Calls while the
+// Actor is already stopping or done return false.
+func (act *Actor) TryStop() bool {
+	if act.IsDone() || act.ctx.Err() != nil {
+		return false
+	}
+	if !act.stopInitiated.CompareAndSwap(false, true) {
+		return false
+	}
+	act.cancel()
+	return true
+}
+
+// StopGraceful rejects new Actions and waits up to the grace period
+// for the queued ones to be executed before it terminates the Actor
+// backend. Actions still queued after the grace period are dropped
+// like with Stop. See WithDrainReads for serving reads meanwhile.
+func (act *Actor) StopGraceful(grace time.Duration) {
 	if act.IsDone() {
 		return
 	}
-	act.cancel()
+	act.stopping.Store(true)
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	// Wait for a marker behind all queued requests. Reads admitted
+	// during the drain are queued behind it, so they need more rounds.
+	for act.awaitMarker(timer.C) {
+		if !act.drainReads || (len(act.requests) == 0 && act.sending.Load() == 0) {
+			break
+		}
+	}
+	act.TryStop()
+}
+
+// awaitMarker enqueues a marker and waits until it is executed. It
+// returns false if the timeout fired or the Actor is done before.
+func (act *Actor) awaitMarker(timeout <-chan time.Time) bool {
+	marker := newMarker()
+	select {
+	case act.requests <- marker:
+		select {
+		case <-marker.done:
+			return true
+		case <-timeout:
+		case <-act.done:
+		}
+	case <-timeout:
+	case <-act.done:
+	}
+	return false
+}
+
+// admit is the single admission check of all entry points. It first
+// checks the lifecycle of the Actor, so that a stopped one rejects
+// calls with the canonical ErrDone without allocating a request. Then
+// it validates the arguments in the caller's goroutine, so that they
+// cannot harm the backend.
+func (act *Actor) admit(ctx context.Context, hasAction bool) error {
+	return act.admitFor(ctx, hasAction, false)
+}
+
+// admitFor is the admission check for a read or write request.
+func (act *Actor) admitFor(ctx context.Context, hasAction, read bool) error {
+	if err := act.aliveFor(read); err != nil {
+		return err
+	}
+	if ctx == nil {
+		return fmt.Errorf("%w: nil context", ErrInvalid)
+	}
+	if !hasAction {
+		return fmt.Errorf("%w: nil action", ErrInvalid)
+	}
+	return nil
+}
+
+// alive returns the error of a failed Actor or ErrDone if it is
+// stopped, stopping, or handing over.
+func (act *Actor) alive() error {
+	return act.aliveFor(false)
+}
+
+// aliveFor is the lifecycle check for a read or write request.
+// Reads are still alive while the Actor is draining its queue
+// if WithDrainReads is set.
+func (act *Actor) aliveFor(read bool) error {
+	if err := act.err.Load(); err != nil {
+		return *err
+	}
+	if m := act.migration.Load(); m != nil {
+		return &MigratedError{Successor: m.successor}
+	}
+	if act.ctx.Err() != nil || act.IsDone() {
+		return ErrDone
+	}
+	if act.stopping.Load() && !(read && act.drainReads && !act.handingOver.Load()) {
+		return ErrDone
+	}
+	return nil
 }
 
 // send sends a request to the backend.
 func (act *Actor) send(req *request) error {
-	// Check if we're error free and still working.
-	if act.err.Load() != nil {
-		return *act.err.Load()
+	// Count the sends in flight, so that a handover can wait
+	// for them before draining the queue.
+	act.sending.Add(1)
+	defer act.sending.Add(-1)
+	if err := act.aliveFor(req.read); err != nil {
+		return err
 	}
-	if act.IsDone() {
-		return fmt.Errorf("actor is done")
+	if act.chaosReject(req) {
+		return ErrQueueFull
 	}
-	// Send the request to the backend.
+	if act.dropDuplicate(req) {
+		return nil
+	}
+	if err := act.acquireSlot(req); err != nil {
+		act.forgetDuplicate(req)
+		return err
+	}
+	if err := act.acquireQuota(req); err != nil {
+		act.leaveQueue(req)
+		return err
+	}
+	act.queueIndex.add(req)
+	act.occupancy.enqueued(req)
 	select {
 	case act.requests <- req:
+		act.countReceived(req)
 	case <-req.ctx.Done():
+		act.leaveQueue(req)
 		return fmt.Errorf("action context sending: %v", req.ctx.Err())
 	case <-act.ctx.Done():
-		return fmt.Errorf("actor context sending: %v", act.ctx.Err())
+		act.leaveQueue(req)
+		return act.alive()
 	}
 	return nil
 }
 
+// trySend sends a request to the backend only if the queue has room.
+// Otherwise it returns false without an error.
+func (act *Actor) trySend(req *request) (bool, error) {
+	act.sending.Add(1)
+	defer act.sending.Add(-1)
+	if err := act.aliveFor(req.read); err != nil {
+		return false, err
+	}
+	if act.chaosReject(req) {
+		return false, nil
+	}
+	if act.dropDuplicate(req) {
+		return true, nil
+	}
+	if !act.tryAcquireSlot(req) {
+		act.forgetDuplicate(req)
+		return false, nil
+	}
+	if err := act.acquireQuota(req); err != nil {
+		act.leaveQueue(req)
+		return false, err
+	}
+	act.queueIndex.add(req)
+	act.occupancy.enqueued(req)
+	select {
+	case act.requests <- req:
+		act.countReceived(req)
+		return true, nil
+	default:
+		act.leaveQueue(req)
+		return false, nil
+	}
+}
+
+// countReceived counts a queued request unless it is a marker, as
+// those are not counted as handled.
+func (act *Actor) countReceived(req *request) {
+	if !req.marker {
+		act.received.Add(1)
+	}
+}
+
+// leaveQueue releases the bookkeeping of a request leaving the queue.
+func (act *Actor) leaveQueue(req *request) {
+	act.queueIndex.remove(req)
+	act.occupancy.dequeued(req)
+	act.releaseBytes(req)
+	act.releaseQuota(req)
+	act.releaseSlot(req)
+	act.forgetDuplicate(req)
+}
+
 // wait waits for synchronous requests to be done or returning an error.
 func (act *Actor) wait(req *request) error {
 	select {
@@ -220,7 +712,25 @@ func (act *Actor) wait(req *request) error {
 	case <-req.ctx.Done():
 		return fmt.Errorf("action context waiting: %v", req.ctx.Err())
 	case <-act.ctx.Done():
-		return fmt.Errorf("actor context waiting: %v", act.ctx.Err())
+		if m := act.migration.Load(); m != nil && !req.started.Load() {
+			// The request is executed by the successor.
+			return &MigratedError{Successor: m.successor, Forwarded: true}
+		}
+		if act.handingOver.Load() || req.started.Load() {
+			// The request is executed by the next Actor or
+			// already running while the Actor stops.
+			return fmt.Errorf("actor context waiting: %v", act.ctx.Err())
+		}
+		// The stopping backend either executes the queued request
+		// or finishes it with ErrDone or the error of the Actor.
+		select {
+		case <-req.done:
+		case <-req.ctx.Done():
+			return fmt.Errorf("action context waiting: %v", req.ctx.Err())
+		}
+	}
+	if err := act.chaosDelay(req); err != nil {
+		return err
 	}
 	return req.err
 }
@@ -228,10 +738,10 @@ func (act *Actor) wait(req *request) error {
 // backend runs the goroutine of the Actor.
 func (act *Actor) backend(started chan struct{}) {
 	defer act.finalize()
+	act.applyLabels()
+	act.goroutineID = currentGoroutineID()
 	close(started)
 
-	act.done = make(chan struct{})
-
 	// Work as long as we're not stopped.
 	for !act.IsDone() {
 		act.work()
@@ -244,34 +754,119 @@ func (act *Actor) work() {
 	defer func() {
 		// Check panics and possibly send notification.
 		if reason := recover(); reason != nil {
+			panics := act.panics.Add(1)
 			err := act.recoverer(reason)
+			if err == nil && reason == ErrLockTimeout {
+				// The lock holder still believes to have exclusive
+				// access, so the backend must not continue.
+				err = ErrLockTimeout
+			}
+			if err == nil {
+				err = act.checkPanics(panics, reason)
+			}
+			act.dumpCrash(reason, err)
+			act.current = nil
 			if err != nil {
 				act.err.Store(&err)
-				close(act.done)
+				act.terminate()
+				return
 			}
+			act.failed()
 		}
 	}()
+	act.awaitErrorBackoff()
 	// Select in loop.
 	for {
+		// A stopped Actor takes no further requests, even
+		// if some are still queued.
+		if act.ctx.Err() != nil {
+			act.terminate()
+			return
+		}
+		// Execute requests left from an ordered batch or the
+		// sync latency backlog first.
+		act.pendingLen.Store(int64(len(act.pending)))
+		if len(act.pending) > 0 {
+			act.dispatch(act.nextPending())
+			continue
+		}
 		select {
 		case <-act.ctx.Done():
-			close(act.done)
+			act.terminate()
 			return
+		case <-act.parentCtx.Done():
+			// Only needed for an unwrapped context.
+			act.cancel()
+			act.terminate()
+			return
+		case <-act.eventDue():
+			act.releaseEvents()
 		case req := <-act.requests:
-			req.execute()
+			act.arrive(req)
+			if act.ordering == ByArrivalTime {
+				act.pending = act.orderRequests(req)
+				continue
+			}
+			if act.absorbing() {
+				act.pending = append(act.pending, req)
+				continue
+			}
+			act.dispatch(req)
+		}
+	}
+}
+
+// terminate waits for the tracked goroutines, marks the Actor as done,
+// releases it from the live Actors, and completes the requests it will
+// not execute anymore.
+func (act *Actor) terminate() {
+	act.awaitTracked()
+	act.release()
+	stopped := time.Now()
+	act.stopped.Store(&stopped)
+	close(act.done)
+	act.cancel()
+	if act.handingOver.Load() {
+		// Pending requests will be handed over.
+		return
+	}
+	// Complete the pending requests with the reason of
+	// the termination, so that nobody waits for them.
+	err := ErrDone
+	if aerr := act.err.Load(); aerr != nil {
+		err = *aerr
+	}
+	// Sends admitted before the stop may still enqueue, so
+	// drain again until none is in flight anymore.
+	for {
+		for _, req := range act.drainPending() {
+			req.err = err
+			req.finish()
+			act.dropped++
 		}
+		if act.sending.Load() == 0 {
+			return
+		}
+		time.Sleep(time.Millisecond)
 	}
 }
 
 // finalize takes care for a clean loop finalization.
 func (act *Actor) finalize() {
+	var err error
+	if aerr := act.err.Load(); aerr != nil {
+		err = *aerr
+	}
+	err = act.finalCommit(err)
+	err = act.runClosers(err)
+	act.cause.Store(&err)
 	var ferr error
-	err := act.err.Load()
-	if err != nil {
-		ferr = act.finalizer(*err)
+	if act.summaryFinalizer != nil {
+		ferr = act.summaryFinalizer(act.summary(err), err)
 	} else {
-		ferr = act.finalizer(nil)
+		ferr = act.finalizer(err)
 	}
+	act.finalizerErr.Store(&ferr)
 	if ferr != nil {
 		act.err.Store(&ferr)
 	}
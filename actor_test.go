@@ -15,12 +15,14 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 
 	"tideland.dev/go/audit/asserts"
 
 	"tideland.dev/go/actor"
+	"tideland.dev/go/actor/actortest"
 )
 
 //--------------------
@@ -83,6 +85,194 @@ func TestPureError(t *testing.T) {
 	assert.ErrorMatch(act.Err(), "damn")
 }
 
+// TestStopGraceful verifies that queued Actions are executed before
+// the Actor terminates, while new ones are rejected.
+func TestStopGraceful(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	executed := 0
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoAsync(func() {
+			time.Sleep(5 * time.Millisecond)
+			executed++
+		}))
+	}
+	stopped := make(chan struct{})
+	go func() {
+		act.StopGraceful(time.Second)
+		close(stopped)
+	}()
+	time.Sleep(time.Millisecond)
+	assert.True(act.DoAsync(func() {}) == actor.ErrDone)
+
+	<-stopped
+	<-act.Done()
+	assert.Equal(executed, 5)
+	assert.NoError(act.Err())
+
+	// Grace period exceeded drops the rest.
+	act, err = actor.Go()
+	assert.OK(err)
+	executed = 0
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoAsync(func() {
+			time.Sleep(20 * time.Millisecond)
+			executed++
+		}))
+	}
+	act.StopGraceful(30 * time.Millisecond)
+	<-act.Done()
+	assert.Range(executed, 1, 3)
+}
+
+// TestDrainReads verifies that reads are served while the Actor
+// drains its queue and writes are rejected.
+func TestDrainReads(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithDrainReads())
+	assert.OK(err)
+
+	written := 0
+	for i := 0; i < 10; i++ {
+		assert.OK(act.DoAsync(func() {
+			time.Sleep(5 * time.Millisecond)
+			written++
+		}))
+	}
+	stopped := make(chan struct{})
+	go func() {
+		act.StopGraceful(time.Second)
+		close(stopped)
+	}()
+	time.Sleep(time.Millisecond)
+	assert.True(act.DoAsync(func() {}) == actor.ErrDone)
+	assert.True(act.DoSync(func() {}) == actor.ErrDone)
+
+	// Only values of succeeded reads are owned by the test.
+	reads := 0
+	last := 0
+	for !act.IsDone() {
+		values := make(chan int, 1)
+		err := act.DoRead(context.Background(), func() {
+			values <- written
+		})
+		if err != nil {
+			// Rejected or dropped once the drain completed.
+			break
+		}
+		read := <-values
+		assert.True(read >= last && read <= 10, "reads see the progressing writes")
+		last = read
+		reads++
+	}
+	<-stopped
+	assert.True(reads > 0)
+	assert.Equal(written, 10)
+	assert.True(act.DoRead(context.Background(), func() {}) == actor.ErrDone)
+
+	// Without the option reads are rejected too.
+	act, err = actor.Go()
+	assert.OK(err)
+	assert.OK(act.DoAsync(func() {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	go act.StopGraceful(time.Second)
+	time.Sleep(time.Millisecond)
+	assert.True(act.DoRead(context.Background(), func() {}) == actor.ErrDone)
+	<-act.Done()
+}
+
+// TestSummaryFinalizer verifies the shutdown summary of a scripted run.
+func TestSummaryFinalizer(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, rec := actortest.NewTestActor(t, actor.WithRecoverer(func(reason any) error {
+		return nil
+	}))
+
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	act.DoSync(func() {
+		panic("ouch")
+	})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	for i := 0; i < 3; i++ {
+		assert.OK(act.DoAsync(func() {}))
+	}
+	time.Sleep(10 * time.Millisecond)
+	act.Stop()
+	close(release)
+
+	sum, ok := rec.Shutdown(time.Second)
+	assert.True(ok)
+	assert.Length(rec.Panics(), 1)
+	assert.Equal(sum.Processed, uint64(7))
+	assert.Equal(sum.Panics, uint64(1))
+	assert.Equal(sum.Dropped, 3)
+	assert.False(sum.HandedOver)
+	assert.NoError(sum.Reason)
+	assert.False(sum.Clean())
+	assert.True(sum.Uptime >= 10*time.Millisecond)
+	assert.NoError(act.Err())
+}
+
+// TestShutdownCause verifies that the cause of the shutdown and the
+// error of the finalizer replacing it are both retrievable.
+func TestShutdownCause(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	errReplaced := errors.New("replaced")
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		return fmt.Errorf("%w: %v", errReplaced, err)
+	}))
+	assert.OK(err)
+	assert.NoError(act.ShutdownCause())
+	assert.NoError(act.FinalizerError())
+
+	act.DoAsync(func() {
+		panic("ouch")
+	})
+	<-act.Done()
+	assert.Retry(func() bool { return act.FinalizerError() != nil }, 100, time.Millisecond)
+	assert.ErrorMatch(act.ShutdownCause(), "panic during actor action: ouch")
+	assert.True(errors.Is(act.FinalizerError(), errReplaced))
+	assert.False(errors.Is(act.ShutdownCause(), errReplaced))
+	assert.Equal(act.Err(), act.FinalizerError())
+
+	// A finalizer keeping the error leaves both equal.
+	act, err = actor.Go()
+	assert.OK(err)
+	act.Stop()
+	<-act.Done()
+	assert.Retry(func() bool { return act.Err() == nil && act.ShutdownCause() == nil }, 100, time.Millisecond)
+	assert.NoError(act.FinalizerError())
+}
+
+// TestUserData verifies round-tripping user data.
+func TestUserData(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	type handle struct {
+		key string
+	}
+	h := &handle{key: "account-4711"}
+	act, err := actor.Go(actor.WithUserData(h))
+	assert.OK(err)
+	defer act.Stop()
+
+	data, ok := act.UserData().(*handle)
+	assert.True(ok)
+	assert.Equal(data, h)
+
+	plain, err := actor.Go()
+	assert.OK(err)
+	defer plain.Stop()
+	assert.Nil(plain.UserData())
+}
+
 // TestContext verifies starting and stopping an Actor
 // with an external context.
 func TestContext(t *testing.T) {
@@ -96,6 +286,34 @@ func TestContext(t *testing.T) {
 	assert.NoError(act.Err())
 }
 
+// TestWithoutContextWrap verifies that an Actor using its context
+// directly stops via Stop as well as via the canceled parent.
+func TestWithoutContextWrap(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	// Stop.
+	act, err := actor.Go(actor.WithoutContextWrap())
+	assert.OK(err)
+	assert.OK(act.DoSync(func() {}))
+	assert.True(act.TryStop())
+	<-act.Done()
+	assert.NoError(act.Err())
+	assert.True(errors.Is(act.DoSync(func() {}), actor.ErrDone))
+
+	// Canceled parent.
+	ctx, cancel := context.WithCancel(context.Background())
+	act, err = actor.Go(actor.WithContext(ctx), actor.WithoutContextWrap())
+	assert.OK(err)
+	assert.OK(act.DoSync(func() {}))
+	cancel()
+	select {
+	case <-act.Done():
+	case <-time.After(time.Second):
+		t.Fatal("actor not stopped by canceled parent")
+	}
+	assert.False(act.TryStop())
+}
+
 // TestSync verifies synchronous calls.
 func TestSync(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -125,6 +343,217 @@ func TestSync(t *testing.T) {
 	}), "actor is done")
 }
 
+// TestConcurrentCallersOrder verifies that with the default ordering
+// the Actions of concurrent callers interleave nondeterministically,
+// but the Actions of each single caller keep their order.
+func TestConcurrentCallersOrder(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	const callers = 5
+	const calls = 100
+	var wg sync.WaitGroup
+	last := make([]int, callers)
+
+	wg.Add(callers)
+	for c := 0; c < callers; c++ {
+		go func(c int) {
+			defer wg.Done()
+			for i := 1; i <= calls; i++ {
+				i := i
+				assert.OK(act.DoAsync(func() {
+					assert.Equal(last[c], i-1)
+					last[c] = i
+				}))
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	assert.OK(act.DoSync(func() {
+		for c := 0; c < callers; c++ {
+			assert.Equal(last[c], calls)
+		}
+	}))
+}
+
+// TestInvalidArguments verifies that invalid arguments are rejected
+// in the caller's goroutine and do not terminate the Actor.
+func TestInvalidArguments(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	var nilCtx context.Context
+	ctx := context.Background()
+	repeat := func(ctx context.Context, interval time.Duration, action actor.Action) error {
+		_, err := act.RepeatWithContext(ctx, interval, action)
+		return err
+	}
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"DoAsync nil action", func() error { return act.DoAsync(nil) }},
+		{"DoAsyncWithContext nil action", func() error { return act.DoAsyncWithContext(ctx, nil) }},
+		{"DoAsyncWithContext nil context", func() error { return act.DoAsyncWithContext(nilCtx, func() {}) }},
+		{"DoSync nil action", func() error { return act.DoSync(nil) }},
+		{"DoSyncWithContext nil action", func() error { return act.DoSyncWithContext(ctx, nil) }},
+		{"DoSyncWithContext nil context", func() error { return act.DoSyncWithContext(nilCtx, func() {}) }},
+		{"DoAsyncCtx nil action", func() error { return act.DoAsyncCtx(ctx, nil) }},
+		{"DoSyncCtx nil action", func() error { return act.DoSyncCtx(ctx, nil) }},
+		{"DoSyncCtx nil context", func() error { return act.DoSyncCtx(nilCtx, func(context.Context) {}) }},
+		{"Repeat nil action", func() error { _, err := act.Repeat(time.Millisecond, nil); return err }},
+		{"Repeat zero interval", func() error { _, err := act.Repeat(0, func() {}); return err }},
+		{"RepeatWithContext nil context", func() error { return repeat(nilCtx, time.Millisecond, func() {}) }},
+		{"RepeatWithContext nil action", func() error { return repeat(ctx, time.Millisecond, nil) }},
+		{"DoThrottled nil action", func() error { return act.DoThrottled("key", time.Millisecond, nil) }},
+		{"DoWatched nil action", func() error { _, err := act.DoWatched(time.Second, nil, func() {}); return err }},
+		{"DoWatched nil handler", func() error { _, err := act.DoWatched(time.Second, func() error { return nil }, nil); return err }},
+		{"Go nil context", func() error { _, err := actor.Go(actor.WithContext(nilCtx)); return err }},
+		{"Go nil interceptor", func() error { _, err := actor.Go(actor.WithInterceptors(nil)); return err }},
+	}
+	for _, test := range tests {
+		err := test.call()
+		assert.True(errors.Is(err, actor.ErrInvalid), test.name)
+		assert.False(act.IsDone(), test.name)
+		assert.NoError(act.DoSync(func() {}), test.name)
+	}
+
+	// Nil recoverer and finalizer keep the defaults.
+	act, err = actor.Go(actor.WithRecoverer(nil), actor.WithFinalizer(nil))
+	assert.OK(err)
+	act.DoSync(func() { panic("ouch") })
+	<-act.Done()
+	assert.ErrorMatch(act.Err(), "panic during actor action: ouch")
+}
+
+// TestStoppedErrors verifies that all calls to a stopped Actor
+// return the identical canonical error.
+func TestStoppedErrors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	act.Stop()
+
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"DoAsync", func() error { return act.DoAsync(func() {}) }},
+		{"DoAsyncWithContext", func() error { return act.DoAsyncWithContext(ctx, func() {}) }},
+		{"DoAsyncCtx", func() error { return act.DoAsyncCtx(ctx, func(context.Context) {}) }},
+		{"DoSync", func() error { return act.DoSync(func() {}) }},
+		{"DoSyncWithContext", func() error { return act.DoSyncWithContext(ctx, func() {}) }},
+		{"DoSyncCtx", func() error { return act.DoSyncCtx(ctx, func(context.Context) {}) }},
+		{"DoSyncSequenced", func() error { _, err := act.DoSyncSequenced(ctx, func() {}); return err }},
+		{"DoWatched", func() error { _, err := act.DoWatched(time.Second, func() error { return nil }, func() {}); return err }},
+		{"DoThrottled", func() error { return act.DoThrottled("key", time.Second, func() {}) }},
+		{"Repeat", func() error { _, err := act.Repeat(time.Second, func() {}); return err }},
+		{"RepeatWithContext", func() error { _, err := act.RepeatWithContext(ctx, time.Second, func() {}); return err }},
+	}
+	// Check directly after stopping and after being done.
+	for _, test := range tests {
+		err := test.call()
+		assert.True(err == actor.ErrDone, test.name)
+	}
+	<-act.Done()
+	for _, test := range tests {
+		err := test.call()
+		assert.True(err == actor.ErrDone, test.name)
+	}
+}
+
+// TestStopQueuedSync verifies that synchronous callers of Actions
+// accepted but not executed before the stop receive ErrDone.
+func TestStopQueuedSync(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() {
+			errs <- act.DoSync(func() {})
+		}()
+	}
+	assert.Retry(func() bool { return act.Metrics().Queued == 10 }, 100, time.Millisecond)
+	act.Stop()
+	close(release)
+	for i := 0; i < 10; i++ {
+		assert.True(errors.Is(<-errs, actor.ErrDone))
+	}
+
+	// Callers racing with the stop are either executed or rejected.
+	act, err = actor.Go()
+	assert.OK(err)
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			executed := false
+			err := act.DoSync(func() { executed = true })
+			switch {
+			case err == nil:
+				assert.True(executed)
+			case errors.Is(err, actor.ErrDone):
+				assert.False(executed)
+			default:
+				// Only the running Action sees the context error.
+				assert.ErrorMatch(err, "actor context waiting.*")
+			}
+		}()
+	}
+	act.Stop()
+	wg.Wait()
+}
+
+// TestTryStop verifies that exactly one of concurrent TryStop calls
+// initiates the termination.
+func TestTryStop(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	const callers = 50
+	initiated := make(chan bool, callers)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func() {
+			defer wg.Done()
+			<-start
+			initiated <- act.TryStop()
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(initiated)
+	count := 0
+	for ok := range initiated {
+		if ok {
+			count++
+		}
+	}
+	assert.Equal(count, 1)
+
+	<-act.Done()
+	assert.False(act.TryStop())
+}
+
 // TestTimeout verifies timout error of a synchronous Action.
 func TestTimeout(t *testing.T) {
 	assert := asserts.NewTesting(t, asserts.FailStop)
@@ -270,4 +699,27 @@ func TestNotifierFail(t *testing.T) {
 	assert.ErrorMatch(act.Err(), "ouch:.*")
 }
 
+// BenchmarkDoAsync measures sending asynchronous Actions, showing
+// the costs features add to the hot path.
+func BenchmarkDoAsync(b *testing.B) {
+	act, _ := actor.Go()
+	defer act.Stop()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = act.DoAsync(func() {})
+	}
+}
+
+// BenchmarkDoSync measures sending synchronous Actions.
+func BenchmarkDoSync(b *testing.B) {
+	act, _ := actor.Go()
+	defer act.Stop()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = act.DoSync(func() {})
+	}
+}
+
 // EOF
@@ -0,0 +1,59 @@
+// Tideland Go Actor - Test Support
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actortest // import "tideland.dev/go/actor/actortest"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+	"time"
+)
+
+//--------------------
+// CLOCK
+//--------------------
+
+// Clock is a wall clock for tests. It runs like the real one but can
+// be stepped like by NTP. Its Now method can be passed to options like
+// actor.WithWallClock or actor.WithHLCClock.
+type Clock struct {
+	mu   sync.Mutex
+	skew time.Duration
+}
+
+// NewClock creates a Clock showing the real time.
+func NewClock() *Clock {
+	return &Clock{}
+}
+
+// Now returns the current time of the Clock. It has no monotonic
+// clock reading, so comparisons use the wall clock.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Round(0).Add(c.skew)
+}
+
+// SetWallClock steps the Clock to the time, from there on it
+// continues running.
+func (c *Clock) SetWallClock(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skew = t.Round(0).Sub(time.Now().Round(0))
+}
+
+// Step steps the Clock forward, or backward for a negative duration.
+func (c *Clock) Step(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.skew += d
+}
+
+// EOF
@@ -0,0 +1,46 @@
+// Tideland Go Actor - Test Support - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actortest_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor/actortest"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestClock verifies stepping the Clock.
+func TestClock(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	clock := actortest.NewClock()
+
+	assert.True(clock.Now().Sub(time.Now()) < time.Second)
+
+	clock.Step(time.Hour)
+	skew := clock.Now().Sub(time.Now())
+	assert.True(skew > 59*time.Minute && skew <= time.Hour)
+
+	target := time.Date(2020, time.January, 1, 12, 0, 0, 0, time.UTC)
+	clock.SetWallClock(target)
+	now := clock.Now()
+	assert.True(!now.Before(target) && now.Sub(target) < time.Second)
+	time.Sleep(5 * time.Millisecond)
+	assert.True(clock.Now().After(now))
+}
+
+// EOF
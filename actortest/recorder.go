@@ -0,0 +1,225 @@
+// Tideland Go Actor - Test Support
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package actortest supports testing code built on top of Actors. Its
+// Recorder hooks into an Actor and keeps everything it observes in
+// memory for later assertions. Its Clock can be stepped to test the
+// handling of wall clock adjustments.
+package actortest // import "tideland.dev/go/actor/actortest"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// EVENTS
+//--------------------
+
+// EventKind defines the kind of a recorded event.
+type EventKind int
+
+const (
+	// ActionEvent is recorded when an Action is about to be executed.
+	ActionEvent EventKind = iota
+
+	// PanicEvent is recorded when a panic of an Action is recovered.
+	PanicEvent
+
+	// ShutdownEvent is recorded when the Actor is finalized.
+	ShutdownEvent
+)
+
+// Event is a recorded observation.
+type Event struct {
+	Kind      EventKind
+	Time      time.Time
+	Name      string
+	Sequence  uint64
+	Reason    any
+	Err       error
+	Shutdown  actor.ShutdownSummary
+	CrashDump actor.CrashDump
+}
+
+//--------------------
+// RECORDER
+//--------------------
+
+// Recorder records the Actions, recovered panics, and the shutdown
+// of an Actor.
+type Recorder struct {
+	mu     sync.Mutex
+	events []Event
+	done   chan struct{}
+}
+
+// NewRecorder creates an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{
+		done: make(chan struct{}),
+	}
+}
+
+// Options returns the options wiring the Recorder into an Actor. They
+// install an interceptor, a crash dumper, and a summary finalizer, the
+// latter replacing a finalizer set before. The finalizer returns the
+// error of the Actor unchanged.
+func (r *Recorder) Options() []actor.Option {
+	return []actor.Option{
+		actor.WithInterceptors(r.intercept),
+		actor.WithCrashDumper(r.dump, nil),
+		actor.WithSummaryFinalizer(r.finalize),
+	}
+}
+
+// Timeline returns all recorded events in the order of their recording.
+// Panics are recorded asynchronously, so they may appear after Actions
+// executed later.
+func (r *Recorder) Timeline() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]Event, len(r.events))
+	copy(events, r.events)
+	return events
+}
+
+// Actions returns the recorded Actions.
+func (r *Recorder) Actions() []Event {
+	return r.filter(func(e Event) bool {
+		return e.Kind == ActionEvent
+	})
+}
+
+// ActionsNamed returns the recorded Actions whose runtime function
+// name contains the part, e.g. the name of the method enqueueing it.
+func (r *Recorder) ActionsNamed(part string) []Event {
+	return r.filter(func(e Event) bool {
+		return e.Kind == ActionEvent && strings.Contains(e.Name, part)
+	})
+}
+
+// Panics returns the recorded panics.
+func (r *Recorder) Panics() []Event {
+	return r.filter(func(e Event) bool {
+		return e.Kind == PanicEvent
+	})
+}
+
+// Succeeded tells if the Action with the given sequence has been
+// executed without a recovered panic.
+func (r *Recorder) Succeeded(seq uint64) bool {
+	executed := false
+	for _, e := range r.Timeline() {
+		if e.Sequence != seq {
+			continue
+		}
+		switch e.Kind {
+		case ActionEvent:
+			executed = true
+		case PanicEvent:
+			return false
+		}
+	}
+	return executed
+}
+
+// Shutdown waits up to the timeout for the finalization of the Actor
+// and returns its summary.
+func (r *Recorder) Shutdown(timeout time.Duration) (actor.ShutdownSummary, bool) {
+	select {
+	case <-r.done:
+	case <-time.After(timeout):
+		return actor.ShutdownSummary{}, false
+	}
+	for _, e := range r.Timeline() {
+		if e.Kind == ShutdownEvent {
+			return e.Shutdown, true
+		}
+	}
+	return actor.ShutdownSummary{}, false
+}
+
+// filter returns the events matching the predicate.
+func (r *Recorder) filter(match func(e Event) bool) []Event {
+	var events []Event
+	for _, e := range r.Timeline() {
+		if match(e) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+// record appends an event.
+func (r *Recorder) record(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e.Time = time.Now()
+	r.events = append(r.events, e)
+}
+
+// intercept records an Action.
+func (r *Recorder) intercept(meta *actor.RequestMeta) error {
+	r.record(Event{
+		Kind:     ActionEvent,
+		Name:     meta.ActionName(),
+		Sequence: meta.Sequence,
+	})
+	return nil
+}
+
+// dump records a recovered panic.
+func (r *Recorder) dump(dump actor.CrashDump) {
+	r.record(Event{
+		Kind:      PanicEvent,
+		Name:      dump.Action,
+		Sequence:  dump.Sequence,
+		Reason:    dump.Reason,
+		Err:       dump.Err,
+		CrashDump: dump,
+	})
+}
+
+// finalize records the shutdown.
+func (r *Recorder) finalize(sum actor.ShutdownSummary, err error) error {
+	defer close(r.done)
+	r.record(Event{
+		Kind:     ShutdownEvent,
+		Err:      err,
+		Shutdown: sum,
+	})
+	return err
+}
+
+//--------------------
+// TEST ACTOR
+//--------------------
+
+// NewTestActor starts an Actor with a wired Recorder. The Actor is
+// stopped when the test ends. Further options are applied before the
+// ones of the Recorder.
+func NewTestActor(t testing.TB, options ...actor.Option) (*actor.Actor, *Recorder) {
+	t.Helper()
+	r := NewRecorder()
+	act, err := actor.Go(append(options, r.Options()...)...)
+	if err != nil {
+		t.Fatalf("cannot start test actor: %v", err)
+	}
+	t.Cleanup(act.Stop)
+	return act, r
+}
+
+// EOF
@@ -0,0 +1,103 @@
+// Tideland Go Actor - Test Support - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actortest_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+	"tideland.dev/go/actor/actortest"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRecorderActions verifies the recording of the Actions enqueued
+// by the methods of a wrapper.
+func TestRecorderActions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, rec := actortest.NewTestActor(t)
+	account := &Account{act: act}
+
+	assert.OK(account.Deposit(10))
+	assert.OK(account.Deposit(5))
+	balance, err := account.Balance()
+	assert.OK(err)
+	assert.Equal(balance, 15)
+
+	deposits := rec.ActionsNamed("Deposit")
+	assert.Length(deposits, 2)
+	assert.True(rec.Succeeded(deposits[0].Sequence))
+	assert.Length(rec.ActionsNamed("Balance"), 1)
+	assert.Length(rec.Actions(), 3)
+}
+
+// TestRecorderPanicAndShutdown verifies the recording of a recovered
+// panic and the shutdown.
+func TestRecorderPanicAndShutdown(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, rec := actortest.NewTestActor(t, actor.WithRecoverer(func(reason any) error {
+		return nil
+	}))
+	account := &Account{act: act}
+
+	account.Fail()
+	assert.Retry(func() bool { return len(rec.Panics()) == 1 }, 100, time.Millisecond)
+	panics := rec.Panics()
+	assert.Equal(panics[0].Reason, "bankrupt")
+	assert.False(rec.Succeeded(panics[0].Sequence))
+
+	act.Stop()
+	sum, ok := rec.Shutdown(time.Second)
+	assert.True(ok)
+	assert.Equal(sum.Panics, uint64(1))
+	assert.Equal(sum.Processed, uint64(1))
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// Account is a simple wrapper around an Actor.
+type Account struct {
+	act     *actor.Actor
+	balance int
+}
+
+// Deposit adds the amount.
+func (a *Account) Deposit(amount int) error {
+	return a.act.DoAsync(func() {
+		a.balance += amount
+	})
+}
+
+// Balance returns the balance.
+func (a *Account) Balance() (int, error) {
+	var balance int
+	err := a.act.DoSync(func() {
+		balance = a.balance
+	})
+	return balance, err
+}
+
+// Fail panics inside the Actor.
+func (a *Account) Fail() {
+	a.act.DoSync(func() {
+		panic("bankrupt")
+	})
+}
+
+// EOF
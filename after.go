@@ -0,0 +1,78 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+//--------------------
+// AFTER N
+//--------------------
+
+// afterN is an Action waiting for a number of handled Actions.
+type afterN struct {
+	target   uint64
+	req      *request
+	canceled atomic.Bool
+}
+
+// DoAfterN registers an Action to be executed once after the next n
+// Actions have been handled. It is executed before any further queued
+// Action. The returned function cancels it if it has not been executed
+// yet. Deferred Actions still waiting when the Actor stops are dropped.
+func (act *Actor) DoAfterN(n int, action Action) (func(), error) {
+	if err := act.admit(context.Background(), action != nil); err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("%w: negative number of actions", ErrInvalid)
+	}
+	an := &afterN{
+		req: newActionRequest(context.Background(), action),
+	}
+	if err := act.DoAsync(func() {
+		// This Action is counted when it returns.
+		an.target = act.handled.Load() + 1 + uint64(n)
+		act.afterNs = append(act.afterNs, an)
+	}); err != nil {
+		return nil, err
+	}
+	return func() {
+		an.canceled.Store(true)
+	}, nil
+}
+
+// releaseAfterNs moves the due Actions registered with DoAfterN to
+// the pending requests, so they are executed next.
+func (act *Actor) releaseAfterNs() {
+	if len(act.afterNs) == 0 {
+		return
+	}
+	handled := act.handled.Load()
+	waiting := act.afterNs[:0]
+	for _, an := range act.afterNs {
+		switch {
+		case an.canceled.Load():
+		case handled >= an.target:
+			act.received.Add(1)
+			act.pending = append(act.pending, an.req)
+		default:
+			waiting = append(waiting, an)
+		}
+	}
+	act.afterNs = waiting
+}
+
+// EOF
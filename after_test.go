@@ -0,0 +1,72 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDoAfterN verifies that a deferred Action is executed exactly
+// after the given number of Actions.
+func TestDoAfterN(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	var order []int
+	_, err = act.DoAfterN(3, func() {
+		order = append(order, 0)
+	})
+	assert.OK(err)
+	for i := 1; i <= 5; i++ {
+		i := i
+		assert.OK(act.DoAsync(func() {
+			order = append(order, i)
+		}))
+	}
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(order, []int{1, 2, 3, 0, 4, 5})
+
+	_, err = act.DoAfterN(-1, func() {})
+	assert.ErrorMatch(err, ".*invalid argument.*")
+}
+
+// TestDoAfterNCancel verifies that a canceled deferred Action is
+// not executed.
+func TestDoAfterNCancel(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	executed := false
+	cancel, err := act.DoAfterN(2, func() {
+		executed = true
+	})
+	assert.OK(err)
+	assert.OK(act.DoSync(func() {}))
+	cancel()
+	assert.OK(act.DoSync(func() {}))
+	assert.OK(act.DoSync(func() {}))
+	assert.False(executed)
+}
+
+// EOF
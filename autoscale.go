@@ -0,0 +1,231 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// autoScaleInterval is the time between two samples of the
+	// queue utilization.
+	autoScaleInterval = 50 * time.Millisecond
+
+	// autoScaleSamples is the number of consecutive samples above
+	// or below the target needed to resize the queue.
+	autoScaleSamples = 3
+)
+
+//--------------------
+// SLOTS
+//--------------------
+
+// slots limits the number of asynchronous requests waiting while the
+// backend takes the queued ones into its backlog. Unlike the capacity
+// of the queue channel its limit can be changed.
+type slots struct {
+	mu    sync.Mutex
+	limit int
+	used  int
+	freed chan struct{}
+}
+
+// newSlots creates slots with the given limit.
+func newSlots(limit int) *slots {
+	return &slots{
+		limit: limit,
+	}
+}
+
+// acquire takes a slot if one is free. Otherwise it returns a channel
+// closed when slots are freed or added.
+func (s *slots) acquire() (bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.used < s.limit {
+		s.used++
+		return true, nil
+	}
+	if s.freed == nil {
+		s.freed = make(chan struct{})
+	}
+	return false, s.freed
+}
+
+// release frees a slot.
+func (s *slots) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.used--
+	s.notify()
+}
+
+// capacity returns the current limit.
+func (s *slots) capacity() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit
+}
+
+// resize changes the limit. A smaller one lets further requests wait
+// until enough slots are freed.
+func (s *slots) resize(limit int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if limit > s.limit {
+		s.notify()
+	}
+	s.limit = limit
+}
+
+// notify wakes up the waiting senders. The mutex has to be locked.
+func (s *slots) notify() {
+	if s.freed != nil {
+		close(s.freed)
+		s.freed = nil
+	}
+}
+
+//--------------------
+// AUTO-SCALING QUEUE
+//--------------------
+
+// autoScale contains the settings of an auto-scaling queue.
+type autoScale struct {
+	min    int
+	max    int
+	target float64
+}
+
+// newAutoScale validates the settings of an auto-scaling queue.
+func newAutoScale(min, max int, targetUtil float64, limit int) (*autoScale, error) {
+	if min < 1 || max < min {
+		return nil, fmt.Errorf("%w: auto-scaling queue bounds %d and %d", ErrInvalid, min, max)
+	}
+	if max > limit {
+		return nil, fmt.Errorf("%w: auto-scaling queue maximum %d exceeds queue capacity %d", ErrInvalid, max, limit)
+	}
+	if targetUtil <= 0 || targetUtil > 1 {
+		return nil, fmt.Errorf("%w: auto-scaling queue target utilization %v", ErrInvalid, targetUtil)
+	}
+	return &autoScale{
+		min:    min,
+		max:    max,
+		target: targetUtil,
+	}, nil
+}
+
+// SetAutoScaleQueue lets the Actor resize its queue between min and
+// max to keep the utilization near the target. While the utilization
+// is at least the target for a number of samples the capacity doubles,
+// while it is below half of the target it halves. The gap between both
+// thresholds avoids resizing back and forth. For this the backend takes
+// the queued requests into a backlog like with a sync latency budget,
+// and the senders of asynchronous requests wait for room in the current
+// capacity. The maximum cannot exceed the capacity of the queue channel
+// the Actor has been started with, see WithAutoScaleQueue. A max of
+// zero switches the scaling off and restores the capacity.
+func (act *Actor) SetAutoScaleQueue(min, max int, targetUtil float64) error {
+	if max == 0 {
+		act.autoScale.Store(nil)
+		act.slots.resize(cap(act.requests))
+		return nil
+	}
+	as, err := newAutoScale(min, max, targetUtil, cap(act.requests))
+	if err != nil {
+		return err
+	}
+	act.autoScale.Store(as)
+	capacity := act.slots.capacity()
+	switch {
+	case capacity < min:
+		act.slots.resize(min)
+	case capacity > max:
+		act.slots.resize(max)
+	}
+	act.startAutoScale()
+	return nil
+}
+
+// initSlots creates the slots of the Actor when it is started.
+func (act *Actor) initSlots() {
+	as := act.autoScale.Load()
+	if as == nil {
+		act.slots = newSlots(cap(act.requests))
+		return
+	}
+	if as.max > cap(act.requests) {
+		act.requests = make(chan *request, as.max)
+	}
+	act.slots = newSlots(as.min)
+}
+
+// startAutoScale starts sampling the queue utilization once.
+func (act *Actor) startAutoScale() {
+	act.autoScaleOnce.Do(func() {
+		go act.runAutoScale()
+	})
+}
+
+// runAutoScale samples the queue utilization and resizes the queue
+// until the Actor is done.
+func (act *Actor) runAutoScale() {
+	ticker := time.NewTicker(autoScaleInterval)
+	defer ticker.Stop()
+	above, below := 0, 0
+	for {
+		select {
+		case <-act.done:
+			return
+		case <-ticker.C:
+		}
+		as := act.autoScale.Load()
+		if as == nil {
+			above, below = 0, 0
+			continue
+		}
+		capacity := act.slots.capacity()
+		utilization := float64(act.queued()) / float64(capacity)
+		switch {
+		case utilization >= as.target:
+			above++
+			below = 0
+		case utilization < as.target/2:
+			below++
+			above = 0
+		default:
+			above, below = 0, 0
+		}
+		switch {
+		case above >= autoScaleSamples:
+			above = 0
+			if capacity *= 2; capacity > as.max {
+				capacity = as.max
+			}
+			act.slots.resize(capacity)
+		case below >= autoScaleSamples:
+			below = 0
+			if capacity /= 2; capacity < as.min {
+				capacity = as.min
+			}
+			act.slots.resize(capacity)
+		}
+	}
+}
+
+// EOF
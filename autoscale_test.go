@@ -0,0 +1,78 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestAutoScaleQueue verifies that the queue grows towards the maximum
+// under sustained load and shrinks back when idle.
+func TestAutoScaleQueue(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithAutoScaleQueue(16, 128, 0.8))
+	assert.OK(err)
+	defer act.Stop()
+	assert.Equal(act.Metrics().Capacity, 16)
+
+	// Block the backend and keep the queue full.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				if act.DoAsync(func() {}) != nil {
+					return
+				}
+			}
+		}()
+	}
+	assert.Retry(func() bool { return act.Metrics().Capacity == 128 }, 100, 20*time.Millisecond)
+	assert.True(act.Metrics().Queued <= 128)
+
+	// Idle again.
+	stop.Store(true)
+	close(release)
+	wg.Wait()
+	assert.Retry(func() bool { return act.Metrics().Capacity == 16 }, 100, 20*time.Millisecond)
+
+	// The channel has the default capacity, switching off restores it.
+	err = act.SetAutoScaleQueue(16, 1024, 0.8)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	assert.OK(act.SetAutoScaleQueue(0, 0, 0))
+	assert.Equal(act.Metrics().Capacity, 256)
+	assert.OK(act.SetAutoScaleQueue(32, 64, 0.5))
+	assert.Equal(act.Metrics().Capacity, 64)
+}
+
+// EOF
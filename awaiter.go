@@ -0,0 +1,183 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrNoPending is returned by CompletionSet.Next if no awaiters
+// are pending.
+var ErrNoPending = errors.New("no pending awaiters")
+
+//--------------------
+// AWAITER
+//--------------------
+
+// Awaiter is the handle of an asynchronously executed Action.
+type Awaiter struct {
+	req *request
+}
+
+// DoAwait sends the Action to the backend and returns an Awaiter
+// when it's queued.
+func (act *Actor) DoAwait(ctx context.Context, action Action) (*Awaiter, error) {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return nil, err
+	}
+	req := newActionRequest(ctx, action)
+	// Only awaited requests need their finishing time.
+	req.extension().awaited = true
+	if err := act.send(req); err != nil {
+		return nil, err
+	}
+	return &Awaiter{req: req}, nil
+}
+
+// Done returns a channel that is closed when the Action has been
+// executed or will not be executed anymore, e.g. because the Actor
+// stopped.
+func (a *Awaiter) Done() <-chan struct{} {
+	return a.req.done
+}
+
+// Err returns the error of the request when done, nil before.
+func (a *Awaiter) Err() error {
+	select {
+	case <-a.req.done:
+		return a.req.err
+	default:
+		return nil
+	}
+}
+
+// Wait blocks until the Action is done and returns its error.
+func (a *Awaiter) Wait() error {
+	<-a.req.done
+	return a.req.err
+}
+
+// WaitContext blocks until the Action is done or the context ends.
+// In the latter case the context error is returned, the Action itself
+// may still be executed.
+func (a *Awaiter) WaitContext(ctx context.Context) error {
+	select {
+	case <-a.req.done:
+		return a.req.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Func returns Wait as plain function, e.g. for APIs expecting
+// a func() error.
+func (a *Awaiter) Func() func() error {
+	return a.Wait
+}
+
+//--------------------
+// COMPLETION SET
+//--------------------
+
+// CompletionResult is returned by CompletionSet.Next for each
+// completed Awaiter.
+type CompletionResult struct {
+	Awaiter *Awaiter
+	Err     error
+}
+
+// CompletionSet collects Awaiters, e.g. of Actions fanned out to many
+// Actors, and returns them in the order they finish. It needs no
+// goroutine per pending Awaiter. A CompletionSet is not safe for
+// concurrent use.
+type CompletionSet struct {
+	pending []*Awaiter
+}
+
+// NewCompletionSet creates an empty CompletionSet.
+func NewCompletionSet() *CompletionSet {
+	return &CompletionSet{}
+}
+
+// Add registers Awaiters in the set.
+func (cs *CompletionSet) Add(awaiters ...*Awaiter) {
+	for _, a := range awaiters {
+		if a != nil {
+			cs.pending = append(cs.pending, a)
+		}
+	}
+}
+
+// Len returns the number of pending Awaiters.
+func (cs *CompletionSet) Len() int {
+	return len(cs.pending)
+}
+
+// Cancel drops all pending Awaiters. Their Actions are
+// executed nevertheless.
+func (cs *CompletionSet) Cancel() {
+	cs.pending = nil
+}
+
+// Next waits for the next Awaiter to finish and returns it. Already
+// finished ones are returned in the order of their finishing. If
+// none is pending ErrNoPending is returned, if the context ends
+// first its error.
+func (cs *CompletionSet) Next(ctx context.Context) (CompletionResult, error) {
+	if len(cs.pending) == 0 {
+		return CompletionResult{}, ErrNoPending
+	}
+	// Check for the earliest finished one.
+	first := -1
+	for i, a := range cs.pending {
+		select {
+		case <-a.req.done:
+			if first < 0 || a.req.ext.finished.Before(cs.pending[first].req.ext.finished) {
+				first = i
+			}
+		default:
+		}
+	}
+	if first < 0 {
+		// Wait for the first finishing one.
+		cases := make([]reflect.SelectCase, len(cs.pending)+1)
+		cases[0] = reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(ctx.Done()),
+		}
+		for i, a := range cs.pending {
+			cases[i+1] = reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(a.req.done),
+			}
+		}
+		chosen, _, _ := reflect.Select(cases)
+		if chosen == 0 {
+			return CompletionResult{}, ctx.Err()
+		}
+		first = chosen - 1
+	}
+	a := cs.pending[first]
+	cs.pending = append(cs.pending[:first], cs.pending[first+1:]...)
+	return CompletionResult{
+		Awaiter: a,
+		Err:     a.req.err,
+	}, nil
+}
+
+// EOF
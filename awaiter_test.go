@@ -0,0 +1,208 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestAwaiter verifies waiting for an Awaiter, also inside a select.
+func TestAwaiter(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	release := make(chan struct{})
+	executed := false
+	a, err := act.DoAwait(ctx, func() {
+		<-release
+		executed = true
+	})
+	assert.OK(err)
+	assert.NoError(a.Err())
+
+	// Select over Done alongside other channels.
+	ticker := time.NewTicker(5 * time.Millisecond)
+	defer ticker.Stop()
+	ticks := 0
+	waiting := true
+	for waiting {
+		select {
+		case <-a.Done():
+			waiting = false
+		case <-ticker.C:
+			ticks++
+			if ticks == 3 {
+				close(release)
+			}
+		}
+	}
+	assert.True(ticks >= 3)
+	assert.True(executed)
+	assert.NoError(a.Wait())
+
+	// Cancelable waiting.
+	release = make(chan struct{})
+	a, err = act.DoAwait(ctx, func() {
+		<-release
+	})
+	assert.OK(err)
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorMatch(a.WaitContext(tctx), "context deadline exceeded")
+	close(release)
+	assert.NoError(a.WaitContext(ctx))
+
+	// Legacy function.
+	a, err = act.DoAwait(ctx, func() {})
+	assert.OK(err)
+	wait := a.Func()
+	assert.NoError(wait())
+}
+
+// TestAwaiterStopped verifies that Awaiters of Actions queued when the
+// Actor stops return promptly with ErrDone.
+func TestAwaiterStopped(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	a, err := act.DoAwait(ctx, func() {})
+	assert.OK(err)
+	act.Stop()
+	close(release)
+	wctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	assert.True(errors.Is(a.WaitContext(wctx), actor.ErrDone))
+
+	// Awaiters of sends racing with the stop never hang.
+	act, err = actor.Go(actor.WithQueueCap(1024))
+	assert.OK(err)
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				a, err := act.DoAwait(ctx, func() {})
+				if err != nil {
+					return
+				}
+				if err := a.WaitContext(wctx); err != nil && !errors.Is(err, actor.ErrDone) {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	time.Sleep(10 * time.Millisecond)
+	act.Stop()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(err)
+	}
+}
+
+// TestCompletionSet verifies that completions are returned in the
+// order the Actions finish.
+func TestCompletionSet(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx := context.Background()
+	cs := actor.NewCompletionSet()
+	_, err := cs.Next(ctx)
+	assert.True(err == actor.ErrNoPending)
+
+	// Actors finishing in reverse order of registration.
+	const count = 4
+	awaiters := make([]*actor.Awaiter, count)
+	for i := 0; i < count; i++ {
+		act, err := actor.Go()
+		assert.OK(err)
+		defer act.Stop()
+		delay := time.Duration(count-i) * 15 * time.Millisecond
+		awaiters[i], err = act.DoAwait(ctx, func() {
+			time.Sleep(delay)
+		})
+		assert.OK(err)
+		cs.Add(awaiters[i])
+	}
+	assert.Equal(cs.Len(), count)
+
+	for i := count - 1; i >= 0; i-- {
+		result, err := cs.Next(ctx)
+		assert.OK(err)
+		assert.NoError(result.Err)
+		assert.True(result.Awaiter == awaiters[i])
+	}
+	assert.Equal(cs.Len(), 0)
+
+	// Already finished ones are returned in finish order too.
+	act, err := actor.Go()
+	assert.OK(err)
+	first, err := act.DoAwait(ctx, func() {})
+	assert.OK(err)
+	second, err := act.DoAwait(ctx, func() {})
+	assert.OK(err)
+	<-second.Done()
+	cs.Add(second, first)
+	result, err := cs.Next(ctx)
+	assert.OK(err)
+	assert.True(result.Awaiter == first)
+
+	// Context expiry and Actor stop.
+	release := make(chan struct{})
+	blocked, err := act.DoAwait(ctx, func() {
+		<-release
+	})
+	assert.OK(err)
+	queued, err := act.DoAwait(ctx, func() {})
+	assert.OK(err)
+	cs.Cancel()
+	cs.Add(queued)
+	tctx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, err = cs.Next(tctx)
+	assert.ErrorMatch(err, "context deadline exceeded")
+
+	act.Stop()
+	close(release)
+	<-blocked.Done()
+	result, err = cs.Next(ctx)
+	assert.OK(err)
+	assert.True(result.Err == actor.ErrDone)
+}
+
+// EOF
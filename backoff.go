@@ -0,0 +1,83 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"time"
+)
+
+//--------------------
+// ERROR BACKOFF
+//--------------------
+
+// errorBackoff delays the backend after consecutive failed Actions.
+type errorBackoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures int
+}
+
+// SetErrorBackoff lets the Actor wait before executing the next
+// request after an Action panicked and the recoverer let the Actor
+// continue. The delay starts with base and doubles with each further
+// consecutive failure up to max. A successful Action resets it. So a
+// continuously failing Action, e.g. a repeated broken external call,
+// cannot saturate the CPU. A base of zero or less switches it off.
+// It may also be called inside an Action.
+func (act *Actor) SetErrorBackoff(base, max time.Duration) error {
+	if max < base {
+		max = base
+	}
+	set := func() {
+		act.errorBackoff.base = base
+		act.errorBackoff.max = max
+	}
+	if act.InActor() {
+		set()
+		return nil
+	}
+	return act.DoSync(set)
+}
+
+// failed counts a failed Action.
+func (act *Actor) failed() {
+	act.errorBackoff.failures++
+}
+
+// succeeded resets the failure count after a successful Action.
+func (act *Actor) succeeded() {
+	act.errorBackoff.failures = 0
+}
+
+// awaitErrorBackoff waits the delay for the consecutive failures or
+// until the Actor is stopped.
+func (act *Actor) awaitErrorBackoff() {
+	eb := &act.errorBackoff
+	if eb.base <= 0 || eb.failures == 0 {
+		return
+	}
+	delay := eb.base
+	for i := 1; i < eb.failures && delay < eb.max; i++ {
+		delay *= 2
+	}
+	if delay > eb.max {
+		delay = eb.max
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-act.ctx.Done():
+	}
+}
+
+// EOF
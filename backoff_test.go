@@ -0,0 +1,78 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestErrorBackoff verifies that the delay between continuously
+// failing Actions grows up to the maximum and is reset by a
+// successful one.
+func TestErrorBackoff(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithRecoverer(func(reason any) error {
+		return nil
+	}))
+	assert.OK(err)
+	defer act.Stop()
+	const base = 5 * time.Millisecond
+	assert.OK(act.SetErrorBackoff(base, 4*base))
+
+	var attempts []time.Time
+	failing := func() {
+		attempts = append(attempts, time.Now())
+		panic("broken external call")
+	}
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoAsync(failing))
+	}
+	var succeeded time.Time
+	assert.OK(act.DoSync(func() {
+		succeeded = time.Now()
+	}))
+	assert.Length(attempts, 5)
+
+	// Delays are 5ms, 10ms, 20ms, and 20ms again.
+	expected := []time.Duration{base, 2 * base, 4 * base, 4 * base}
+	for i, delay := range expected {
+		assert.True(attempts[i+1].Sub(attempts[i]) >= delay)
+	}
+	assert.True(succeeded.Sub(attempts[4]) >= 4*base)
+
+	// The success resets the delay.
+	started := time.Now()
+	assert.OK(act.DoAsync(failing))
+	assert.OK(act.DoSync(func() {}))
+	assert.True(time.Since(started) < 4*base)
+
+	// Switching it off inside an Action does not deadlock.
+	assert.OK(act.DoSync(func() {
+		assert.OK(act.SetErrorBackoff(0, 0))
+	}))
+	started = time.Now()
+	assert.OK(act.DoAsync(failing))
+	assert.OK(act.DoAsync(failing))
+	assert.OK(act.DoSync(func() {}))
+	assert.True(time.Since(started) < base)
+}
+
+// EOF
@@ -0,0 +1,128 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"log"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+//--------------------
+// BLOCKING DETECTION
+//--------------------
+
+// BlockingReporter defines the signature of a function receiving the
+// report of an Action running longer than the configured threshold.
+// The stack is the one of the backend goroutine at the moment the
+// threshold has been exceeded.
+type BlockingReporter func(threshold time.Duration, stack []byte)
+
+// logBlocking is the default BlockingReporter writing to the
+// standard logger.
+func logBlocking(threshold time.Duration, stack []byte) {
+	log.Printf("actor action blocks longer than %v:\n%s", threshold, stack)
+}
+
+// execute runs a request. Depending on the configuration the execution
+// is watched by the blocking detection and the watchdog. The Action
+// itself is never interrupted.
+func (act *Actor) execute(req *request) {
+	act.leaveQueue(req)
+	req.started.Store(true)
+	act.checkReorder(req)
+	if !req.marker {
+		defer act.releaseAfterNs()
+		defer act.handled.Add(1)
+	}
+	obs := act.observability.Load()
+	if timer := act.watchBlocking(obs); timer != nil {
+		defer timer.Stop()
+	}
+	req.exempt = act.isExempt(req)
+	if timer := act.watchStuck(req); timer != nil {
+		defer timer.Stop()
+	}
+	defer act.trackExecution(req)()
+	act.current = req
+	if cal := act.calibration; !req.marker && (cal != nil || obs.HistorySize > 0) {
+		started := time.Now()
+		req.execute(act)
+		duration := time.Since(started)
+		if cal != nil {
+			cal.measure(req, duration)
+		}
+		act.recordHistory(obs, req, started, duration)
+	} else {
+		req.execute(act)
+	}
+	act.current = nil
+	if !req.marker {
+		act.succeeded()
+		act.recordCommit(req)
+		act.evaluateWatchers()
+		if !req.read {
+			act.evaluateConditions()
+		}
+		act.checkStopWhen()
+	}
+}
+
+// watchBlocking arms a timer reporting the stack of the backend
+// goroutine if the blocking threshold is exceeded. It returns nil
+// if the blocking detection is not activated.
+func (act *Actor) watchBlocking(obs *Observability) *time.Timer {
+	if obs.BlockingThreshold <= 0 {
+		return nil
+	}
+	return time.AfterFunc(obs.BlockingThreshold, func() {
+		obs.BlockingReporter(obs.BlockingThreshold, goroutineStack(act.goroutineID))
+	})
+}
+
+// currentGoroutineID returns the ID of the calling goroutine as found
+// in the header of its stack.
+func currentGoroutineID() uint64 {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	buf = bytes.TrimPrefix(buf, []byte("goroutine "))
+	if i := bytes.IndexByte(buf, ' '); i >= 0 {
+		buf = buf[:i]
+	}
+	id, _ := strconv.ParseUint(string(buf), 10, 64)
+	return id
+}
+
+// goroutineStack captures the stacks of all goroutines and returns
+// the one of the goroutine with the given ID.
+func goroutineStack(id uint64) []byte {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	header := []byte("goroutine " + strconv.FormatUint(id, 10) + " ")
+	for _, stack := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(stack, header) {
+			return stack
+		}
+	}
+	return nil
+}
+
+// EOF
@@ -0,0 +1,63 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestBlockingDetection verifies the reporting of an Action running
+// longer than the configured threshold.
+func TestBlockingDetection(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	reports := make(chan string, 1)
+	reporter := func(threshold time.Duration, stack []byte) {
+		reports <- string(stack)
+	}
+	act, err := actor.Go(actor.WithBlockingDetection(20*time.Millisecond, reporter))
+	assert.OK(err)
+	defer act.Stop()
+
+	// Fast Action must not be reported.
+	assert.OK(act.DoSync(func() {}))
+	select {
+	case <-reports:
+		assert.Fail("fast action reported")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Slow Action has to be reported, but still finishes.
+	done := false
+	assert.OK(act.DoSync(func() {
+		time.Sleep(100 * time.Millisecond)
+		done = true
+	}))
+	assert.True(done)
+	select {
+	case report := <-reports:
+		assert.Substring("TestBlockingDetection.func", report)
+		assert.Substring("time.Sleep", report)
+	case <-time.After(time.Second):
+		assert.Fail("slow action not reported")
+	}
+}
+
+// EOF
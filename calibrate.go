@@ -0,0 +1,190 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// calibrationBlocking is the targeted probability of a full
+	// queue blocking a sender.
+	calibrationBlocking = 0.001
+
+	// calibrationMaxCap limits the recommended queue capacity.
+	calibrationMaxCap = 1 << 20
+)
+
+//--------------------
+// CALIBRATION
+//--------------------
+
+// Recommendation contains the configuration recommended by Calibrate
+// for the measured workload.
+type Recommendation struct {
+	// Executed is the number of Actions measured.
+	Executed int
+
+	// ArrivalRate is the number of Actions submitted per second.
+	ArrivalRate float64
+
+	// MeanService is the mean execution time of the Actions.
+	MeanService time.Duration
+
+	// Utilization is the fraction of the time the Actor is busy.
+	Utilization float64
+
+	// Overloaded tells that Actions arrive faster than the Actor
+	// executes them, so no queue capacity will suffice.
+	Overloaded bool
+
+	// QueueCap is the capacity for WithQueueCap keeping the
+	// probability of a blocking sender below 0.1%.
+	QueueCap int
+
+	// Watchdog is the duration for WithWatchdog, the 99.9th
+	// percentile of the measured execution times.
+	Watchdog time.Duration
+
+	// ReadRatio is the fraction of reads done with DoRead, Query2,
+	// or Query3.
+	ReadRatio float64
+
+	// ConcurrentReads tells if the majority of Actions are reads,
+	// so that serving them concurrently, e.g. by a Pool of read
+	// replicas, would help.
+	ConcurrentReads bool
+}
+
+// calibration collects the measurements inside the backend.
+type calibration struct {
+	started   time.Time
+	received  uint64
+	durations []time.Duration
+	reads     int
+}
+
+// Calibrate measures the workload of the Actor during the sample
+// period and returns the recommended configuration. It only measures
+// and changes no behavior. The queue capacity is calculated for
+// a queue with exponential arrival and service times.
+func (act *Actor) Calibrate(ctx context.Context, sample time.Duration) (Recommendation, error) {
+	if err := act.admit(ctx, true); err != nil {
+		return Recommendation{}, err
+	}
+	if sample <= 0 {
+		return Recommendation{}, fmt.Errorf("%w: non-positive sample", ErrInvalid)
+	}
+	if err := act.DoSyncWithContext(ctx, func() {
+		act.calibration = &calibration{
+			started:  time.Now(),
+			received: act.received.Load(),
+		}
+	}); err != nil {
+		return Recommendation{}, err
+	}
+	timer := time.NewTimer(sample)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	var cal *calibration
+	var elapsed time.Duration
+	var arrived uint64
+	if err := act.DoSync(func() {
+		// Copy, as this request itself is measured after
+		// the caller continued.
+		cal = &calibration{
+			started:   act.calibration.started,
+			received:  act.calibration.received,
+			durations: append([]time.Duration(nil), act.calibration.durations...),
+			reads:     act.calibration.reads,
+		}
+		act.calibration = nil
+		elapsed = time.Since(cal.started)
+		// Don't count this request.
+		arrived = act.received.Load() - cal.received - 1
+	}); err != nil {
+		return Recommendation{}, err
+	}
+	if ctx.Err() != nil {
+		return Recommendation{}, ctx.Err()
+	}
+	return cal.recommend(elapsed, arrived), nil
+}
+
+// measure records the execution of a request.
+func (cal *calibration) measure(req *request, duration time.Duration) {
+	cal.durations = append(cal.durations, duration)
+	if req.read {
+		cal.reads++
+	}
+}
+
+// recommend calculates the recommendation for the measurements.
+func (cal *calibration) recommend(elapsed time.Duration, arrived uint64) Recommendation {
+	rec := Recommendation{
+		Executed: len(cal.durations),
+		QueueCap: defaultQueueCap,
+	}
+	if rec.Executed == 0 || elapsed <= 0 {
+		return rec
+	}
+	var total time.Duration
+	for _, d := range cal.durations {
+		total += d
+	}
+	rec.ArrivalRate = float64(arrived) / elapsed.Seconds()
+	rec.MeanService = total / time.Duration(rec.Executed)
+	rec.Utilization = rec.ArrivalRate * rec.MeanService.Seconds()
+	rec.ReadRatio = float64(cal.reads) / float64(rec.Executed)
+	rec.ConcurrentReads = rec.ReadRatio > 0.5
+
+	sort.Slice(cal.durations, func(i, j int) bool {
+		return cal.durations[i] < cal.durations[j]
+	})
+	p999 := int(math.Ceil(0.999*float64(rec.Executed))) - 1
+	rec.Watchdog = cal.durations[p999]
+
+	if rec.Utilization >= 1 {
+		rec.Overloaded = true
+		rec.QueueCap = calibrationMaxCap
+		return rec
+	}
+	if c := blockingCapacity(rec.Utilization, calibrationBlocking); c > rec.QueueCap {
+		rec.QueueCap = c
+	}
+	return rec
+}
+
+// blockingCapacity returns the smallest capacity of a queue with the
+// utilization rho where the probability of a full queue is at most p.
+func blockingCapacity(rho, p float64) int {
+	for k := 1; k < calibrationMaxCap; k++ {
+		blocking := (1 - rho) * math.Pow(rho, float64(k)) / (1 - math.Pow(rho, float64(k+1)))
+		if blocking <= p {
+			return k
+		}
+	}
+	return calibrationMaxCap
+}
+
+// EOF
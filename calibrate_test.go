@@ -0,0 +1,109 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestCalibrateLight verifies the recommendation for a light
+// read-mostly workload.
+func TestCalibrateLight(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	stop := workload(act, 5*time.Millisecond, time.Millisecond, func(i int) bool {
+		return i%4 != 0
+	})
+	rec, err := act.Calibrate(ctx, 200*time.Millisecond)
+	close(stop)
+	assert.OK(err)
+
+	assert.Range(rec.Executed, 10, 50)
+	assert.True(rec.MeanService >= time.Millisecond)
+	assert.True(rec.Watchdog >= rec.MeanService)
+	assert.True(rec.Utilization > 0 && rec.Utilization < 0.5)
+	assert.False(rec.Overloaded)
+	assert.Equal(rec.QueueCap, 256)
+	assert.True(rec.ReadRatio > 0.5)
+	assert.True(rec.ConcurrentReads)
+
+	_, err = act.Calibrate(ctx, 0)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestCalibrateOverloaded verifies the recommendation for a workload
+// arriving faster than it is executed.
+func TestCalibrateOverloaded(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithQueueCap(10000))
+	assert.OK(err)
+	defer act.Stop()
+
+	stop := workload(act, 500*time.Microsecond, 2*time.Millisecond, func(int) bool {
+		return false
+	})
+	rec, err := act.Calibrate(context.Background(), 100*time.Millisecond)
+	close(stop)
+	assert.OK(err)
+
+	assert.True(rec.Utilization > 1)
+	assert.True(rec.Overloaded)
+	assert.Equal(rec.ReadRatio, 0.0)
+	assert.False(rec.ConcurrentReads)
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// workload submits Actions of the given service time in the given
+// interval until stop is closed.
+func workload(act *actor.Actor, interval, service time.Duration, isRead func(int) bool) chan struct{} {
+	stop := make(chan struct{})
+	work := func() {
+		time.Sleep(service)
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+			if isRead(i) {
+				go act.DoRead(context.Background(), work)
+			} else {
+				act.DoAsync(work)
+			}
+		}
+	}()
+	return stop
+}
+
+// EOF
@@ -0,0 +1,210 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrQueueFull is returned if a ChaosPolicy rejects the admission
+// of a request as if the queue is full.
+var ErrQueueFull = errors.New("queue full")
+
+// ErrChaos is the synthetic error a ChaosPolicy returns instead of
+// the success of an Action.
+var ErrChaos = errors.New("injected chaos failure")
+
+//--------------------
+// CHAOS
+//--------------------
+
+// ChaosFault describes a kind of fault injected by a ChaosPolicy.
+type ChaosFault int
+
+// Faults injected by a ChaosPolicy.
+const (
+	// ChaosLatency delays the execution of an Action.
+	ChaosLatency ChaosFault = iota + 1
+
+	// ChaosReject rejects the admission with ErrQueueFull. Non-blocking
+	// senders like dropping Repeaters see a full queue.
+	ChaosReject
+
+	// ChaosFailure turns a success into ErrChaos.
+	ChaosFailure
+
+	// ChaosDelay delays the delivery of the result to the caller.
+	ChaosDelay
+)
+
+// String implements fmt.Stringer.
+func (f ChaosFault) String() string {
+	switch f {
+	case ChaosLatency:
+		return "latency"
+	case ChaosReject:
+		return "reject"
+	case ChaosFailure:
+		return "failure"
+	case ChaosDelay:
+		return "delay"
+	}
+	return fmt.Sprintf("ChaosFault(%d)", int(f))
+}
+
+// ChaosPolicy configures the faults injected into the requests of an
+// Actor, e.g. to validate timeout and retry settings. The rates are
+// the probabilities between 0 and 1 per request. All decisions are
+// drawn from a random source seeded with Seed, so the same sequence
+// of requests gets the same faults.
+type ChaosPolicy struct {
+	// Seed seeds the random source of the decisions.
+	Seed int64
+
+	// LatencyRate is the probability of adding Latency to
+	// the execution of an Action.
+	LatencyRate float64
+	Latency     time.Duration
+
+	// RejectRate is the probability of rejecting the admission
+	// of a request with ErrQueueFull.
+	RejectRate float64
+
+	// FailureRate is the probability of turning the success
+	// of an Action into ErrChaos.
+	FailureRate float64
+
+	// DelayRate is the probability of delaying the delivery
+	// of the result to a synchronous caller by Delay.
+	DelayRate float64
+	Delay     time.Duration
+
+	// OnFault is called for each injected fault if set. It is
+	// called in the goroutine of the injection point.
+	OnFault func(fault ChaosFault)
+}
+
+// active tells if the policy injects any faults.
+func (p ChaosPolicy) active() bool {
+	return p.LatencyRate > 0 || p.RejectRate > 0 || p.FailureRate > 0 || p.DelayRate > 0
+}
+
+// chaos injects the faults of a policy.
+type chaos struct {
+	mu     sync.Mutex
+	policy ChaosPolicy
+	rand   *rand.Rand
+}
+
+// draw tells if a fault with the rate is injected. Every injection
+// point always draws, so that the sequence stays reproducible.
+func (c *chaos) draw(rate float64) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rand.Float64() < rate
+}
+
+// report passes an injected fault to the observer.
+func (c *chaos) report(fault ChaosFault) {
+	if c.policy.OnFault != nil {
+		c.policy.OnFault(fault)
+	}
+}
+
+// SetChaos sets the policy for injecting faults at the admission,
+// execution, and completion of requests. Markers used internally are
+// not affected. A policy without any rate disables the injection,
+// which is the default.
+func (act *Actor) SetChaos(policy ChaosPolicy) error {
+	for _, rate := range []float64{policy.LatencyRate, policy.RejectRate, policy.FailureRate, policy.DelayRate} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("%w: chaos rate %v", ErrInvalid, rate)
+		}
+	}
+	if policy.Latency < 0 || policy.Delay < 0 {
+		return fmt.Errorf("%w: negative chaos duration", ErrInvalid)
+	}
+	if !policy.active() {
+		act.chaos.Store(nil)
+		return nil
+	}
+	act.chaos.Store(&chaos{
+		policy: policy,
+		rand:   rand.New(rand.NewSource(policy.Seed)),
+	})
+	return nil
+}
+
+// chaosReject tells if the admission of the request is rejected.
+func (act *Actor) chaosReject(req *request) bool {
+	c := act.chaos.Load()
+	if c == nil || req.marker {
+		return false
+	}
+	if !c.draw(c.policy.RejectRate) {
+		return false
+	}
+	c.report(ChaosReject)
+	return true
+}
+
+// chaosLatency delays the execution of the request.
+func (act *Actor) chaosLatency(req *request) {
+	c := act.chaos.Load()
+	if c == nil || req.marker {
+		return
+	}
+	if c.draw(c.policy.LatencyRate) {
+		c.report(ChaosLatency)
+		time.Sleep(c.policy.Latency)
+	}
+}
+
+// chaosComplete possibly turns the success of the executed request
+// into ErrChaos and decides if its delivery is delayed.
+func (act *Actor) chaosComplete(req *request) {
+	c := act.chaos.Load()
+	if c == nil || req.marker {
+		return
+	}
+	if c.draw(c.policy.FailureRate) && req.err == nil {
+		c.report(ChaosFailure)
+		req.err = ErrChaos
+	}
+	if c.draw(c.policy.DelayRate) {
+		c.report(ChaosDelay)
+		req.extension().delay = c.policy.Delay
+	}
+}
+
+// chaosDelay delays the delivery of the result of a done request.
+func (act *Actor) chaosDelay(req *request) error {
+	if req.ext == nil || req.ext.delay <= 0 {
+		return nil
+	}
+	select {
+	case <-time.After(req.ext.delay):
+		return nil
+	case <-req.ctx.Done():
+		return fmt.Errorf("action context waiting: %v", req.ctx.Err())
+	}
+}
+
+// EOF
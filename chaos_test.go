@@ -0,0 +1,131 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestChaosReproducible verifies that a seeded policy injects the same
+// faults into the same sequence of requests.
+func TestChaosReproducible(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	run := func(seed int64) ([]actor.ChaosFault, []error) {
+		act, err := actor.Go()
+		assert.OK(err)
+		defer act.Stop()
+		var mu sync.Mutex
+		var faults []actor.ChaosFault
+		assert.OK(act.SetChaos(actor.ChaosPolicy{
+			Seed:        seed,
+			LatencyRate: 0.2,
+			Latency:     time.Microsecond,
+			RejectRate:  0.2,
+			FailureRate: 0.2,
+			DelayRate:   0.2,
+			Delay:       time.Microsecond,
+			OnFault: func(fault actor.ChaosFault) {
+				mu.Lock()
+				defer mu.Unlock()
+				faults = append(faults, fault)
+			},
+		}))
+		var errs []error
+		for i := 0; i < 100; i++ {
+			errs = append(errs, act.DoSync(func() {}))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return faults, errs
+	}
+
+	faultsA, errsA := run(42)
+	faultsB, errsB := run(42)
+	assert.Equal(faultsA, faultsB)
+	assert.Equal(errsA, errsB)
+
+	kinds := map[actor.ChaosFault]int{}
+	for _, fault := range faultsA {
+		kinds[fault]++
+	}
+	assert.Length(kinds, 4)
+	rejected, failed := 0, 0
+	for _, err := range errsA {
+		switch {
+		case errors.Is(err, actor.ErrQueueFull):
+			rejected++
+		case errors.Is(err, actor.ErrChaos):
+			failed++
+		default:
+			assert.NoError(err)
+		}
+	}
+	assert.Equal(rejected, kinds[actor.ChaosReject])
+	assert.Equal(failed, kinds[actor.ChaosFailure])
+
+	faultsC, _ := run(4711)
+	assert.Different(faultsA, faultsC)
+}
+
+// TestChaosDisable verifies that an Actor behaves normally again
+// after disabling the chaos policy.
+func TestChaosDisable(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	assert.OK(act.SetChaos(actor.ChaosPolicy{
+		Seed:        1,
+		FailureRate: 1,
+	}))
+	err = act.DoSync(func() {})
+	assert.True(errors.Is(err, actor.ErrChaos))
+
+	// A delayed result is still bound to the caller context.
+	assert.OK(act.SetChaos(actor.ChaosPolicy{
+		Seed:      1,
+		DelayRate: 1,
+		Delay:     time.Second,
+	}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err = act.DoSyncWithContext(ctx, func() {})
+	assert.ErrorMatch(err, ".*deadline exceeded.*")
+
+	assert.OK(act.SetChaos(actor.ChaosPolicy{}))
+	counter := 0
+	for i := 0; i < 100; i++ {
+		assert.OK(act.DoSync(func() { counter++ }))
+	}
+	assert.Equal(counter, 100)
+
+	err = act.SetChaos(actor.ChaosPolicy{RejectRate: 1.5})
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	err = act.SetChaos(actor.ChaosPolicy{LatencyRate: 0.5, Latency: -time.Second})
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
@@ -0,0 +1,105 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//--------------------
+// CLOSERS
+//--------------------
+
+// Defer registers a function closing a resource owned by the Actor,
+// e.g. a file or a database connection. Like with defer the closers
+// are called in reverse order of their registration when the Actor
+// terminates, after the remaining requests are completed and before
+// the finalizer. Their errors are joined with the error of the Actor
+// and passed to the finalizer. Defer may be called from inside an
+// Action too.
+func (act *Actor) Defer(closer func() error) error {
+	if closer == nil {
+		return fmt.Errorf("%w: nil closer", ErrInvalid)
+	}
+	if act.InActor() {
+		act.closers = append(act.closers, closer)
+		return nil
+	}
+	return act.DoSync(func() {
+		act.closers = append(act.closers, closer)
+	})
+}
+
+// runClosers calls the closers in reverse order and joins their
+// errors with the error of the Actor.
+func (act *Actor) runClosers(err error) error {
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for i := len(act.closers) - 1; i >= 0; i-- {
+		if cerr := act.closers[i](); cerr != nil {
+			errs = append(errs, cerr)
+		}
+	}
+	act.closers = nil
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return joinedErrors(errs)
+}
+
+// joinedErrors combines multiple errors into one.
+type joinedErrors []error
+
+// Error implements the error interface.
+func (errs joinedErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is checks if any of the joined errors matches the target. Before
+// Go 1.20 errors.Is does not look into Unwrap returning a slice.
+func (errs joinedErrors) Is(target error) bool {
+	for _, err := range errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the joined errors matching the target like
+// errors.As does.
+func (errs joinedErrors) As(target any) bool {
+	for _, err := range errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the joined errors.
+func (errs joinedErrors) Unwrap() []error {
+	return errs
+}
+
+// EOF
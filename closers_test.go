@@ -0,0 +1,79 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDefer verifies the reverse order of the closers and the
+// joining of their errors.
+func TestDefer(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	errFile := errors.New("file close failed")
+	errDB := errors.New("db close failed")
+	closed := []string{}
+	finalized := make(chan error, 1)
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		closed = append(closed, "finalizer")
+		finalized <- err
+		return err
+	}))
+	assert.OK(err)
+
+	assert.OK(act.Defer(func() error {
+		closed = append(closed, "file")
+		return errFile
+	}))
+	assert.OK(act.DoSync(func() {
+		// Registered from inside an Action.
+		assert.OK(act.Defer(func() error {
+			closed = append(closed, "cache")
+			return nil
+		}))
+	}))
+	assert.OK(act.Defer(func() error {
+		closed = append(closed, "db")
+		return errDB
+	}))
+	assert.True(errors.Is(act.Defer(nil), actor.ErrInvalid))
+
+	act.Stop()
+	ferr := <-finalized
+	assert.Equal(closed, []string{"db", "cache", "file", "finalizer"})
+	assert.True(errors.Is(ferr, errFile))
+	assert.True(errors.Is(ferr, errDB))
+	assert.ErrorMatch(ferr, "db close failed\nfile close failed")
+	assert.True(act.Defer(func() error { return nil }) != nil)
+
+	// Without closer errors the finalizer gets none.
+	finalized = make(chan error, 1)
+	act, err = actor.Go(actor.WithFinalizer(func(err error) error {
+		finalized <- err
+		return err
+	}))
+	assert.OK(err)
+	assert.OK(act.Defer(func() error { return nil }))
+	act.Stop()
+	assert.NoError(<-finalized)
+}
+
+// EOF
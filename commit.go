@@ -0,0 +1,126 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//--------------------
+// COMMIT HOOK
+//--------------------
+
+// CommitEvent describes a mutating Action executed since the
+// last commit.
+type CommitEvent struct {
+	// Name is the runtime name of the Action function.
+	Name string
+
+	// Sequence is the apply sequence of the Action.
+	Sequence uint64
+
+	// Submitted is the time the Action has been submitted.
+	Submitted time.Time
+}
+
+// CommitHook receives the events of the mutating Actions since the
+// last commit, e.g. to persist the changed parts of the state. It is
+// called inside the backend, so it sees a consistent state.
+type CommitHook func(events []CommitEvent) error
+
+// commits collects the events for the commit hook.
+type commits struct {
+	every  int
+	hook   CommitHook
+	events []CommitEvent
+}
+
+// SetCommitHook sets a hook called inside the backend after every n
+// successfully executed Actions not sent as reads, and a final time
+// with the remaining ones when the Actor terminates. An error of the
+// hook is passed to the Recoverer like a panic, so by default the
+// Actor stops with it. An error of the final commit is joined with
+// the error of the Actor like the ones of closers. A later call
+// replaces the hook and keeps the collected events, nil removes it.
+func (act *Actor) SetCommitHook(n int, hook CommitHook) error {
+	if n < 1 {
+		return fmt.Errorf("%w: non-positive commit cadence", ErrInvalid)
+	}
+	set := func() {
+		act.commits.every = n
+		act.commits.hook = hook
+		if hook == nil {
+			act.commits.events = nil
+		}
+	}
+	if act.InActor() {
+		set()
+		return nil
+	}
+	// Set as read, so it is not committed itself.
+	if err := act.DoRead(context.Background(), set); err != nil {
+		return fmt.Errorf("setting commit hook: %w", err)
+	}
+	return nil
+}
+
+// recordCommit collects the event of the executed request and
+// commits if the cadence is reached.
+func (act *Actor) recordCommit(req *request) {
+	if act.commits.hook == nil || req.read || req.err != nil {
+		return
+	}
+	act.commits.events = append(act.commits.events, CommitEvent{
+		Name:      funcName(req.origin),
+		Sequence:  req.sequence,
+		Submitted: req.submitted,
+	})
+	if len(act.commits.events) < act.commits.every {
+		return
+	}
+	if cerr := act.commit(); cerr != nil {
+		if err := act.recoverer(cerr); err != nil {
+			act.err.Store(&err)
+			act.cancel()
+		}
+	}
+}
+
+// commit passes the collected events to the hook.
+func (act *Actor) commit() error {
+	if act.commits.hook == nil || len(act.commits.events) == 0 {
+		return nil
+	}
+	events := act.commits.events
+	act.commits.events = nil
+	if err := act.commits.hook(events); err != nil {
+		return fmt.Errorf("commit hook: %w", err)
+	}
+	return nil
+}
+
+// finalCommit commits the remaining events and joins an error
+// with the error of the Actor.
+func (act *Actor) finalCommit(err error) error {
+	cerr := act.commit()
+	switch {
+	case cerr == nil:
+		return err
+	case err == nil:
+		return cerr
+	}
+	return joinedErrors{err, cerr}
+}
+
+// EOF
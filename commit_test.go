@@ -0,0 +1,113 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestCommitHookCadence verifies the commits after every n mutating
+// Actions and the final commit of the tail.
+func TestCommitHookCadence(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	ctx := context.Background()
+
+	balance := 0
+	var commits [][]actor.CommitEvent
+	var committed []int
+	assert.OK(act.SetCommitHook(3, func(events []actor.CommitEvent) error {
+		commits = append(commits, events)
+		committed = append(committed, balance)
+		return nil
+	}))
+
+	for i := 0; i < 7; i++ {
+		assert.OK(act.DoSync(func() { balance++ }))
+		assert.OK(act.DoRead(ctx, func() {}))
+	}
+	var sizes []int
+	assert.OK(act.DoRead(ctx, func() {
+		for _, events := range commits {
+			sizes = append(sizes, len(events))
+		}
+	}))
+	assert.Equal(sizes, []int{3, 3})
+
+	act.Stop()
+	<-act.Done()
+	assert.Retry(func() bool { return act.FinalizerError() == nil && act.ShutdownCause() == nil && len(committed) == 3 }, 100, time.Millisecond)
+	assert.Equal(committed, []int{3, 6, 7})
+	assert.Length(commits[2], 1)
+	last := commits[0][0].Sequence
+	for _, events := range commits[1:] {
+		assert.True(events[0].Sequence > last)
+		last = events[0].Sequence
+	}
+	assert.Substring("TestCommitHookCadence", commits[0][0].Name)
+
+	err = act.SetCommitHook(0, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	_, err = actor.Go(actor.WithCommitHook(0, nil))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestCommitHookErrors verifies that commit errors follow the
+// Recoverer and the final one is joined with the Actor error.
+func TestCommitHookErrors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	errCommit := errors.New("disk full")
+	failing := func(events []actor.CommitEvent) error {
+		return errCommit
+	}
+
+	// By default the Actor stops.
+	act, err := actor.Go()
+	assert.OK(err)
+	assert.OK(act.SetCommitHook(1, failing))
+	act.DoSync(func() {})
+	<-act.Done()
+	assert.ErrorContains(act.Err(), "disk full")
+
+	// A continuing Recoverer keeps it working, the final
+	// commit error is joined.
+	var reasons []any
+	act, err = actor.Go(actor.WithRecoverer(func(reason any) error {
+		reasons = append(reasons, reason)
+		return nil
+	}))
+	assert.OK(err)
+	assert.OK(act.SetCommitHook(2, failing))
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	act.Stop()
+	<-act.Done()
+	assert.Retry(func() bool { return act.ShutdownCause() != nil }, 100, time.Millisecond)
+	assert.Length(reasons, 2)
+	assert.True(errors.Is(reasons[0].(error), errCommit))
+	assert.True(errors.Is(act.ShutdownCause(), errCommit))
+}
+
+// EOF
@@ -0,0 +1,91 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+//--------------------
+// CONDITIONS
+//--------------------
+
+// condition is a predicate a caller of AwaitCondition waits for.
+type condition struct {
+	predicate func() bool
+	met       chan struct{}
+	canceled  atomic.Bool
+}
+
+// AwaitCondition blocks until the predicate on the state guarded by
+// the Actor returns true. The predicate is evaluated inside the backend
+// once immediately and then after each executed Action except reads,
+// so there is no polling. Any number of callers may wait concurrently,
+// each with its own predicate. If the context is done before the
+// context error is returned, if the Actor stops its error or ErrDone.
+func (act *Actor) AwaitCondition(ctx context.Context, predicate func() bool) error {
+	if err := act.admit(ctx, predicate != nil); err != nil {
+		return err
+	}
+	cond := &condition{
+		predicate: predicate,
+		met:       make(chan struct{}),
+	}
+	if err := act.DoSyncWithContext(ctx, func() {
+		if predicate() {
+			close(cond.met)
+			return
+		}
+		act.conditions = append(act.conditions, cond)
+	}); err != nil {
+		return fmt.Errorf("registering condition: %w", err)
+	}
+	select {
+	case <-cond.met:
+		return nil
+	case <-ctx.Done():
+		cond.canceled.Store(true)
+		return ctx.Err()
+	case <-act.done:
+		cond.canceled.Store(true)
+		if err := act.Err(); err != nil {
+			return err
+		}
+		return ErrDone
+	}
+}
+
+// evaluateConditions releases the callers whose predicates are met
+// and removes the canceled ones.
+func (act *Actor) evaluateConditions() {
+	if len(act.conditions) == 0 {
+		return
+	}
+	waiting := act.conditions[:0]
+	for _, cond := range act.conditions {
+		switch {
+		case cond.canceled.Load():
+		case cond.predicate():
+			close(cond.met)
+		default:
+			waiting = append(waiting, cond)
+		}
+	}
+	for i := len(waiting); i < len(act.conditions); i++ {
+		act.conditions[i] = nil
+	}
+	act.conditions = waiting
+}
+
+// EOF
@@ -0,0 +1,129 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestAwaitCondition verifies that a waiter is released by a later
+// Action changing the state.
+func TestAwaitCondition(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	balance := 0
+	released := make(chan error, 1)
+	go func() {
+		released <- act.AwaitCondition(context.Background(), func() bool {
+			return balance >= 100
+		})
+	}()
+	for i := 0; i < 10; i++ {
+		select {
+		case <-released:
+			t.Fatal("released too early")
+		default:
+		}
+		assert.OK(act.DoSync(func() {
+			balance += 10
+		}))
+	}
+	assert.OK(<-released)
+
+	// Already met conditions return immediately.
+	assert.OK(act.AwaitCondition(context.Background(), func() bool {
+		return balance == 100
+	}))
+}
+
+// TestAwaitConditionTimeout verifies the release of a waiter when
+// its context times out.
+func TestAwaitConditionTimeout(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = act.AwaitCondition(ctx, func() bool {
+		return false
+	})
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.OK(act.DoSync(func() {}))
+}
+
+// TestAwaitConditionShutdown verifies the release of a waiter when
+// the Actor stops.
+func TestAwaitConditionShutdown(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	released := make(chan error, 1)
+	go func() {
+		released <- act.AwaitCondition(context.Background(), func() bool {
+			return false
+		})
+	}()
+	time.Sleep(10 * time.Millisecond)
+	act.Stop()
+	assert.True(errors.Is(<-released, actor.ErrDone))
+}
+
+// TestAwaitConditionConcurrent verifies many concurrent waiters with
+// different predicates.
+func TestAwaitConditionConcurrent(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	const waiters = 100
+	counter := 0
+	var wg sync.WaitGroup
+	for i := 1; i <= waiters; i++ {
+		wg.Add(1)
+		go func(target int) {
+			defer wg.Done()
+			var seen int
+			assert.OK(act.AwaitCondition(context.Background(), func() bool {
+				seen = counter
+				return counter >= target
+			}))
+			assert.True(seen >= target)
+		}(i)
+	}
+	for i := 0; i < waiters; i++ {
+		assert.OK(act.DoAsync(func() {
+			counter++
+		}))
+	}
+	wg.Wait()
+}
+
+// EOF
@@ -0,0 +1,87 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"runtime/debug"
+	"time"
+)
+
+//--------------------
+// CRASH DUMP
+//--------------------
+
+// CrashDump contains the diagnostic context of a panic recovered
+// during the execution of an Action.
+type CrashDump struct {
+	// Time is the time of the recovery.
+	Time time.Time
+
+	// Reason is the panic value.
+	Reason any
+
+	// Err is the error returned by the Recoverer. If it is not nil
+	// the Actor terminates.
+	Err error
+
+	// Action is the runtime name of the panicking Action.
+	Action string
+
+	// Sequence is the apply sequence of the panicking Action.
+	Sequence uint64
+
+	// QueueLen is the number of Actions waiting in the queue.
+	QueueLen int
+
+	// Stack is the stack of the backend goroutine at the panic.
+	Stack []byte
+
+	// Summary is the state summary returned by the configured
+	// summarizer, empty without one.
+	Summary string
+}
+
+// CrashDumper defines the signature of a function receiving crash
+// dumps, e.g. to write them somewhere durable.
+type CrashDumper func(dump CrashDump)
+
+// dumpCrash collects the crash dump inside the backend, including the
+// state summary, and passes it to the dumper in its own goroutine. It
+// is best-effort, a panicking summarizer is ignored.
+func (act *Actor) dumpCrash(reason any, err error) {
+	if act.crashDumper == nil {
+		return
+	}
+	dump := CrashDump{
+		Time:     time.Now(),
+		Reason:   reason,
+		Err:      err,
+		Action:   "unknown",
+		QueueLen: len(act.requests) + len(act.pending),
+		Stack:    debug.Stack(),
+	}
+	if act.current != nil {
+		dump.Action = funcName(act.current.origin)
+		dump.Sequence = act.current.sequence
+	}
+	if act.summarizer != nil {
+		func() {
+			defer func() {
+				_ = recover()
+			}()
+			dump.Summary = act.summarizer()
+		}()
+	}
+	go act.crashDumper(dump)
+}
+
+// EOF
@@ -0,0 +1,69 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestCrashDump verifies the crash dump of a panicking Action.
+func TestCrashDump(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dumps := make(chan actor.CrashDump, 1)
+	counter := 0
+	act, err := actor.Go(
+		actor.WithRecoverer(func(reason any) error {
+			return nil
+		}),
+		actor.WithCrashDumper(func(dump actor.CrashDump) {
+			dumps <- dump
+		}, func() string {
+			return fmt.Sprintf("counter=%d", counter)
+		}),
+	)
+	assert.OK(err)
+	defer act.Stop()
+
+	assert.OK(act.DoSync(func() {
+		counter = 42
+	}))
+	act.DoSync(func() {
+		panic("ouch")
+	})
+
+	select {
+	case dump := <-dumps:
+		assert.Equal(dump.Reason, "ouch")
+		assert.NoError(dump.Err)
+		assert.Substring("TestCrashDump.func", dump.Action)
+		assert.Equal(dump.Sequence, uint64(2))
+		assert.Equal(dump.Summary, "counter=42")
+		assert.Substring("crash_test.go", string(dump.Stack))
+	case <-time.After(time.Second):
+		assert.Fail("no crash dump")
+	}
+
+	// Actor continues after recovery.
+	assert.OK(act.DoSync(func() {}))
+}
+
+// EOF
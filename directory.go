@@ -0,0 +1,192 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrUnowned is returned if a key routed via a Directory has no owner.
+var ErrUnowned = errors.New("key has no owner")
+
+//--------------------
+// DIRECTORY
+//--------------------
+
+// RouteOptions control the routing of an Action via a Directory.
+type RouteOptions struct {
+	// Retries is the number of additional lookups if the owner
+	// stopped or handed over between lookup and dispatch.
+	Retries int
+
+	// Backoff is the pause before each retry.
+	Backoff time.Duration
+}
+
+// Directory maps keys to owning Actors and routes Actions to them.
+// The mapping itself is managed by an Actor, so owners can be assigned
+// and migrated concurrently to the routing.
+type Directory[K comparable] struct {
+	act    *Actor
+	owners map[K]*Actor
+}
+
+// NewDirectory creates an empty Directory.
+func NewDirectory[K comparable]() (*Directory[K], error) {
+	act, err := Go()
+	if err != nil {
+		return nil, err
+	}
+	return &Directory[K]{
+		act:    act,
+		owners: make(map[K]*Actor),
+	}, nil
+}
+
+// Assign sets the owner of the key. When migrating a key, assign
+// the new owner before the old one is stopped or hands over.
+func (d *Directory[K]) Assign(key K, owner *Actor) error {
+	if owner == nil {
+		return fmt.Errorf("%w: nil owner", ErrInvalid)
+	}
+	return d.act.DoSync(func() {
+		d.owners[key] = owner
+	})
+}
+
+// Unassign removes the owner of the key.
+func (d *Directory[K]) Unassign(key K) error {
+	return d.act.DoSync(func() {
+		delete(d.owners, key)
+	})
+}
+
+// Owner returns the current owner of the key or ErrUnowned.
+func (d *Directory[K]) Owner(key K) (*Actor, error) {
+	var owner *Actor
+	if err := d.act.DoSync(func() {
+		owner = d.owners[key]
+	}); err != nil {
+		return nil, err
+	}
+	if owner == nil {
+		return nil, ErrUnowned
+	}
+	return owner, nil
+}
+
+// Route looks up the owner of the key and executes the Action there
+// synchronously. If the owner has been stopped or hands over its work
+// between lookup and dispatch the lookup is retried as configured. An
+// owner migrated with MigrateTo is followed to its successor. As an
+// Action rejected by a stopped or migrated owner is never enqueued, a
+// retry cannot apply it twice. An Action forwarded during a migration
+// is executed by the successor, Route returns the MigratedError with
+// Forwarded set then.
+func (d *Directory[K]) Route(ctx context.Context, key K, action Action, opts RouteOptions) error {
+	var owner *Actor
+	for attempt := 0; ; attempt++ {
+		if owner == nil {
+			var err error
+			if owner, err = d.Owner(key); err != nil {
+				return err
+			}
+		}
+		err := owner.DoSyncWithContext(ctx, action)
+		var merr *MigratedError
+		switch {
+		case attempt >= opts.Retries:
+			return err
+		case errors.As(err, &merr) && !merr.Forwarded:
+			owner = owner.Successor()
+			continue
+		case err != ErrDone:
+			return err
+		}
+		owner = nil
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(opts.Backoff):
+		}
+	}
+}
+
+// StopAll stops all owners concurrently and waits until they are done
+// or the context is done. Each stopped owner is unassigned from its
+// keys. The returned error joins the errors of the owners and, if
+// not all stopped in time, the error of the context.
+func (d *Directory[K]) StopAll(ctx context.Context) error {
+	owners := make(map[*Actor]struct{})
+	if err := d.act.DoSync(func() {
+		for _, owner := range d.owners {
+			owners[owner] = struct{}{}
+		}
+	}); err != nil {
+		return err
+	}
+	stopped := make(chan *Actor, len(owners))
+	for owner := range owners {
+		go func(owner *Actor) {
+			owner.Stop()
+			<-owner.Done()
+			stopped <- owner
+		}(owner)
+	}
+	var errs []error
+	for range owners {
+		select {
+		case owner := <-stopped:
+			if err := d.unassignOwner(owner); err != nil {
+				errs = append(errs, err)
+			}
+			if err := owner.Err(); err != nil {
+				errs = append(errs, err)
+			}
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+			return joinedErrors(errs)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return joinedErrors(errs)
+}
+
+// unassignOwner removes the owner from all its keys.
+func (d *Directory[K]) unassignOwner(owner *Actor) error {
+	return d.act.DoSync(func() {
+		for key, o := range d.owners {
+			if o == owner {
+				delete(d.owners, key)
+			}
+		}
+	})
+}
+
+// Stop terminates the Directory. The owners are not affected.
+func (d *Directory[K]) Stop() {
+	d.act.Stop()
+}
+
+// EOF
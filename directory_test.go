@@ -0,0 +1,157 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDirectoryRoute verifies that routing concurrent to an owner
+// migration applies each Action exactly once.
+func TestDirectoryRoute(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir, err := actor.NewDirectory[string]()
+	assert.OK(err)
+	defer dir.Stop()
+
+	ctx := context.Background()
+	opts := actor.RouteOptions{Retries: 5, Backoff: time.Millisecond}
+
+	err = dir.Route(ctx, "account", func() {}, opts)
+	assert.True(err == actor.ErrUnowned)
+
+	old, err := actor.Go()
+	assert.OK(err)
+	assert.OK(dir.Assign("account", old))
+
+	// Route concurrently while migrating the owner.
+	const routers = 10
+	const routes = 20
+	var applied atomic.Int64
+	var failed atomic.Int64
+	var wg sync.WaitGroup
+	wg.Add(routers)
+	for r := 0; r < routers; r++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < routes; i++ {
+				err := dir.Route(ctx, "account", func() {
+					time.Sleep(50 * time.Microsecond)
+					applied.Add(1)
+				}, opts)
+				if err != nil {
+					// Only synchronous callers of the old owner may
+					// miss the completion of a handed over Action.
+					assert.ErrorMatch(err, "actor context waiting.*")
+					failed.Add(1)
+				}
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	next, err := actor.Go()
+	assert.OK(err)
+	defer next.Stop()
+	assert.OK(dir.Assign("account", next))
+	assert.OK(old.HandoverTo(next))
+	wg.Wait()
+
+	assert.OK(next.DoSync(func() {}))
+	assert.Equal(applied.Load(), int64(routers*routes))
+	assert.True(old.Sequence() > 0, "old owner applied some")
+	assert.True(next.Sequence() > uint64(failed.Load()), "next owner applied some")
+
+	assert.OK(dir.Unassign("account"))
+	_, err = dir.Owner("account")
+	assert.True(err == actor.ErrUnowned)
+}
+
+// TestDirectoryRouteMigrated verifies that routing follows an owner
+// migrated with MigrateTo to its successor.
+func TestDirectoryRouteMigrated(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir, err := actor.NewDirectory[string]()
+	assert.OK(err)
+	defer dir.Stop()
+
+	ctx := context.Background()
+	opts := actor.RouteOptions{Retries: 2, Backoff: time.Millisecond}
+	old, err := actor.Go()
+	assert.OK(err)
+	next, err := actor.Go()
+	assert.OK(err)
+	defer next.Stop()
+	assert.OK(dir.Assign("account", old))
+	assert.OK(old.MigrateTo(next, nil))
+
+	// The directory still names the old owner.
+	executedBy := make(chan *actor.Actor, 1)
+	assert.OK(dir.Route(ctx, "account", func() {
+		executedBy <- next
+	}, opts))
+	assert.Equal(<-executedBy, next)
+
+	var merr *actor.MigratedError
+	assert.True(errors.As(old.DoSync(func() {}), &merr))
+	assert.False(merr.Forwarded)
+	assert.Equal(merr.Successor, next)
+}
+
+// TestDirectoryStopAll verifies that all owners are stopped and
+// unassigned within the context deadline.
+func TestDirectoryStopAll(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir, err := actor.NewDirectory[int]()
+	assert.OK(err)
+	defer dir.Stop()
+
+	var owners []*actor.Actor
+	for i := 0; i < 5; i++ {
+		owner, err := actor.Go()
+		assert.OK(err)
+		owners = append(owners, owner)
+		assert.OK(dir.Assign(i, owner))
+	}
+	// One owner has two keys.
+	assert.OK(dir.Assign(5, owners[0]))
+	// One owner already failed.
+	errFailed := errors.New("failed")
+	assert.OK(owners[4].DoAsync(func() { panic(errFailed) }))
+	<-owners[4].Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err = dir.StopAll(ctx)
+	assert.True(errors.Is(err, errFailed))
+	for i, owner := range owners {
+		assert.True(owner.IsDone())
+		_, err := dir.Owner(i)
+		assert.True(err == actor.ErrUnowned)
+	}
+	_, err = dir.Owner(5)
+	assert.True(err == actor.ErrUnowned)
+}
+
+// EOF
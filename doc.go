@@ -55,6 +55,13 @@
 // The options for the constructor allow to pass a context for the Actor, the capacity
 // of the Action queue, a recoverer function in case of an Action panic and a finalizer
 // function when the Actor stops.
+//
+// Actions of one caller are executed in the order they have been sent. Actions of
+// concurrent callers interleave in the nondeterministic order they win the send
+// to the queue. If a total order by submission time is needed the option
+// WithSubmitOrdering(ByArrivalTime, window) lets the Actor buffer incoming Actions
+// for the given window and execute them sorted by their timestamps. This adds a
+// latency of up to the window to each Action.
 package actor // import "tideland.dev/go/actor"
 
 // EOF
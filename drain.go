@@ -0,0 +1,74 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//--------------------
+// DRAIN PROGRESS
+//--------------------
+
+// defaultProgressInterval is the default interval of the progress
+// reports of WaitStopped.
+const defaultProgressInterval = 100 * time.Millisecond
+
+// DrainProgress is called by WaitStopped with the number of requests
+// still to be handled and the time since waiting started.
+type DrainProgress func(remaining int, elapsed time.Duration)
+
+// WaitStopped waits until the Actor is done, e.g. in a Close method
+// after StopGraceful has been started in the background. Meanwhile
+// the optional progress is called in the interval set with
+// WithProgressInterval, so that long drains do not look hung. It
+// returns the context error if the context ends first.
+func (act *Actor) WaitStopped(ctx context.Context, progress DrainProgress) error {
+	if ctx == nil {
+		return fmt.Errorf("%w: nil context", ErrInvalid)
+	}
+	interval := act.progressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	for {
+		select {
+		case <-act.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if progress != nil {
+				progress(act.remaining(), time.Since(start))
+			}
+		}
+	}
+}
+
+// remaining returns the number of requests received but not
+// yet handled. A request may be handled before it is counted
+// as received.
+func (act *Actor) remaining() int {
+	handled := act.handled.Load()
+	received := act.received.Load()
+	if handled >= received {
+		return 0
+	}
+	return int(received - handled)
+}
+
+// EOF
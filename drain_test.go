@@ -0,0 +1,83 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWaitStopped verifies the progress reports while waiting for
+// the drain of an Actor.
+func TestWaitStopped(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithProgressInterval(5 * time.Millisecond))
+	assert.OK(err)
+
+	for i := 0; i < 100; i++ {
+		assert.OK(act.DoAsync(func() {
+			time.Sleep(time.Millisecond)
+		}))
+	}
+	go act.StopGraceful(10 * time.Second)
+
+	var reports []int
+	err = act.WaitStopped(context.Background(), func(remaining int, elapsed time.Duration) {
+		assert.True(elapsed > 0)
+		reports = append(reports, remaining)
+	})
+	assert.OK(err)
+	assert.True(act.IsDone())
+	assert.True(len(reports) > 1)
+	for i := 1; i < len(reports); i++ {
+		assert.True(reports[i] <= reports[i-1], "non-increasing")
+	}
+	assert.True(reports[0] > 0)
+}
+
+// TestWaitStoppedContext verifies that WaitStopped ends with
+// the context.
+func TestWaitStoppedContext(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	go act.StopGraceful(10 * time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = act.WaitStopped(ctx, nil)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+	assert.False(act.IsDone())
+
+	close(release)
+	assert.OK(act.WaitStopped(context.Background(), nil))
+
+	_, err = actor.Go(actor.WithProgressInterval(0))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
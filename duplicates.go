@@ -0,0 +1,93 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync"
+)
+
+//--------------------
+// CONSECUTIVE DUPLICATES
+//--------------------
+
+// DuplicateKey returns the key of a request for the detection of
+// consecutive duplicates. The sequence of the metadata is not set yet.
+// An empty key is never considered a duplicate.
+type DuplicateKey func(meta *RequestMeta) string
+
+// duplicates remembers the most recently queued request.
+type duplicates struct {
+	key     DuplicateKey
+	mu      sync.Mutex
+	lastKey string
+	last    *request
+}
+
+// SetDropConsecutiveDuplicates lets the Actor drop an asynchronous
+// request if its key equals the one of the most recently queued request
+// still waiting for its execution. So bursts of identical requests, e.g.
+// to recompute a layout after each keystroke, collapse into one. The
+// dropped requests are counted as coalesced in the Metrics. A nil key
+// function switches the dropping off.
+func (act *Actor) SetDropConsecutiveDuplicates(key DuplicateKey) {
+	if key == nil {
+		act.duplicates.Store(nil)
+		return
+	}
+	act.duplicates.Store(&duplicates{key: key})
+}
+
+// dropDuplicate checks if the request is a duplicate of the most
+// recently queued one. If so, it is completed and true is returned.
+// Otherwise the request becomes the most recent one.
+func (act *Actor) dropDuplicate(req *request) bool {
+	dups := act.duplicates.Load()
+	if dups == nil || req.marker {
+		return false
+	}
+	key := ""
+	if !req.sync {
+		key = dups.key(&RequestMeta{
+			Context:   req.ctx,
+			Submitted: req.submitted,
+			origin:    req.origin,
+		})
+	}
+	dups.mu.Lock()
+	defer dups.mu.Unlock()
+	if key != "" && key == dups.lastKey && dups.last != nil {
+		act.coalesced.Add(1)
+		act.releaseBytes(req)
+		req.finish()
+		return true
+	}
+	dups.lastKey = key
+	dups.last = req
+	return false
+}
+
+// forgetDuplicate forgets the request leaving the queue if it is the
+// most recently queued one.
+func (act *Actor) forgetDuplicate(req *request) {
+	dups := act.duplicates.Load()
+	if dups == nil {
+		return
+	}
+	dups.mu.Lock()
+	defer dups.mu.Unlock()
+	if dups.last == req {
+		dups.lastKey = ""
+		dups.last = nil
+	}
+}
+
+// EOF
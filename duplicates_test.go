@@ -0,0 +1,76 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDropConsecutiveDuplicates verifies that bursts of identical
+// requests collapse while interleaved different ones survive.
+func TestDropConsecutiveDuplicates(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	act.SetDropConsecutiveDuplicates(func(meta *actor.RequestMeta) string {
+		return meta.ActionName()
+	})
+
+	var executed []string
+	layout := func() { executed = append(executed, "layout") }
+	render := func() { executed = append(executed, "render") }
+
+	// Block the Actor, so the requests stay queued.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	for i := 0; i < 10; i++ {
+		assert.OK(act.DoAsync(layout))
+	}
+	for i := 0; i < 3; i++ {
+		assert.OK(act.DoAsync(render))
+		assert.OK(act.DoAsync(layout))
+	}
+	close(release)
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(executed, []string{"layout", "render", "layout", "render", "layout", "render", "layout"})
+	assert.Equal(act.Metrics().Coalesced, uint64(9))
+
+	// Executed requests are no duplicates anymore.
+	executed = nil
+	assert.OK(act.DoSync(func() {}))
+	assert.OK(act.DoAsync(layout))
+	assert.OK(act.DoSync(func() {}))
+	assert.OK(act.DoAsync(layout))
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(executed, []string{"layout", "layout"})
+
+	// Switched off.
+	act.SetDropConsecutiveDuplicates(nil)
+	executed = nil
+	assert.OK(act.DoAsync(layout))
+	assert.OK(act.DoAsync(layout))
+	assert.OK(act.DoSync(func() {}))
+	assert.Length(executed, 2)
+}
+
+// EOF
@@ -0,0 +1,555 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrCorruptJournal is returned if a message of a journal does not
+// match its checksum or is incomplete.
+var ErrCorruptJournal = errors.New("corrupt journal")
+
+// errTornRecord marks an incomplete last record of a journal file.
+var errTornRecord = errors.New("torn journal record")
+
+//--------------------
+// JOURNAL
+//--------------------
+
+// Journal persists the messages of a Durable before they are processed.
+type Journal interface {
+	// Append persists the message and returns its sequence number.
+	// Sequence numbers are increasing, starting with 1.
+	Append(msg []byte) (uint64, error)
+
+	// ReadFrom calls fn for all not truncated messages with a sequence
+	// number of at least seq in their order. An error returned by fn
+	// ends the reading and is returned.
+	ReadFrom(seq uint64, fn func(seq uint64, msg []byte) error) error
+
+	// Truncate marks all messages up to and including seq as
+	// processed. They will not be read anymore.
+	Truncate(seq uint64) error
+}
+
+// FileJournal is a reference Journal appending the messages to a file.
+// The sequence number of the latest processed message is kept in a
+// second file with the suffix ".commit". Once all messages are
+// processed the journal file is emptied. Each message is stored with
+// a checksum. An incomplete last message of an interrupted Append is
+// cut off when the journal is opened, any other damage is reported
+// as ErrCorruptJournal.
+type FileJournal struct {
+	mu        sync.Mutex
+	path      string
+	file      *os.File
+	size      int64
+	last      uint64
+	committed uint64
+}
+
+// NewFileJournal opens or creates the journal with the given path.
+func NewFileJournal(path string) (*FileJournal, error) {
+	j := &FileJournal{
+		path: path,
+	}
+	data, err := os.ReadFile(path + ".commit")
+	switch {
+	case err == nil && len(data) == 8:
+		j.committed = binary.BigEndian.Uint64(data)
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("cannot read journal commit: %w", err)
+	}
+	j.last = j.committed
+	j.size, err = j.scan(0, -1, func(seq uint64, msg []byte) error {
+		// Messages of a crash after the commit may be left.
+		if seq > j.last {
+			j.last = seq
+		}
+		return nil
+	})
+	if errors.Is(err, errTornRecord) {
+		if err = os.Truncate(path, j.size); err != nil {
+			return nil, fmt.Errorf("cannot cut torn journal record: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	j.file, err = os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open journal: %w", err)
+	}
+	return j, nil
+}
+
+// Append implements Journal.
+func (j *FileJournal) Append(msg []byte) (uint64, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	seq := j.last + 1
+	record := make([]byte, recordHeaderSize+len(msg))
+	binary.BigEndian.PutUint64(record[0:8], seq)
+	binary.BigEndian.PutUint32(record[8:12], uint32(len(msg)))
+	copy(record[recordHeaderSize:], msg)
+	binary.BigEndian.PutUint32(record[12:16], headerChecksum(record))
+	binary.BigEndian.PutUint32(record[16:20], recordChecksum(record))
+	if _, err := j.file.Write(record); err != nil {
+		return 0, fmt.Errorf("cannot append to journal: %w", err)
+	}
+	if err := j.file.Sync(); err != nil {
+		return 0, fmt.Errorf("cannot sync journal: %w", err)
+	}
+	j.last = seq
+	j.size += int64(len(record))
+	return seq, nil
+}
+
+// ReadFrom implements Journal.
+func (j *FileJournal) ReadFrom(seq uint64, fn func(seq uint64, msg []byte) error) error {
+	j.mu.Lock()
+	from := seq
+	if from <= j.committed {
+		from = j.committed + 1
+	}
+	size := j.size
+	j.mu.Unlock()
+	// Only read the records completely appended until now.
+	_, err := j.scan(from, size, fn)
+	if errors.Is(err, errTornRecord) {
+		return fmt.Errorf("%w: incomplete message", ErrCorruptJournal)
+	}
+	return err
+}
+
+// Truncate implements Journal. The commit is written before the
+// journal file is emptied, so a crash in between never loses the
+// sequence numbers already used.
+func (j *FileJournal) Truncate(seq uint64) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if seq <= j.committed {
+		return nil
+	}
+	empty := seq >= j.last
+	if empty {
+		// Everything is processed, so start over with an empty file
+		// but keep counting.
+		seq = j.last
+	}
+	if err := j.writeCommit(seq); err != nil {
+		return err
+	}
+	j.committed = seq
+	if empty {
+		if err := j.file.Truncate(0); err != nil {
+			return fmt.Errorf("cannot truncate journal: %w", err)
+		}
+		j.size = 0
+	}
+	return nil
+}
+
+// writeCommit atomically replaces the commit file by writing a
+// temporary one, syncing it, and renaming it.
+func (j *FileJournal) writeCommit(seq uint64) error {
+	data := make([]byte, 8)
+	binary.BigEndian.PutUint64(data, seq)
+	tmp := j.path + ".commit.tmp"
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("cannot write journal commit: %w", err)
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot write journal commit: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return fmt.Errorf("cannot sync journal commit: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("cannot write journal commit: %w", err)
+	}
+	if err := os.Rename(tmp, j.path+".commit"); err != nil {
+		return fmt.Errorf("cannot write journal commit: %w", err)
+	}
+	return nil
+}
+
+// Close closes the journal file.
+func (j *FileJournal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.file.Close()
+}
+
+// recordHeaderSize is the size of the sequence number, the message
+// length, and the checksums of the header and the whole record in
+// front of each message.
+const recordHeaderSize = 20
+
+// headerChecksum returns the checksum of the sequence number and the
+// length, so a damaged length is detected before reading the message.
+func headerChecksum(record []byte) uint32 {
+	return crc32.ChecksumIEEE(record[0:12])
+}
+
+// recordChecksum returns the checksum of a record covering the
+// sequence number, the length, and the message.
+func recordChecksum(record []byte) uint32 {
+	sum := crc32.NewIEEE()
+	sum.Write(record[0:12])
+	sum.Write(record[recordHeaderSize:])
+	return sum.Sum32()
+}
+
+// scan reads the journal file up to size bytes, all with a negative
+// size, and calls fn for each message with a sequence number of at
+// least from. It returns the size of the complete records read. Only
+// an incomplete last record with an intact header, or a partial header
+// at the end, is returned as errTornRecord. Any other damage is
+// returned as ErrCorruptJournal.
+func (j *FileJournal) scan(from uint64, size int64, fn func(seq uint64, msg []byte) error) (int64, error) {
+	file, err := os.Open(j.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("cannot open journal: %w", err)
+	}
+	defer file.Close()
+	if size < 0 {
+		info, err := file.Stat()
+		if err != nil {
+			return 0, fmt.Errorf("cannot read journal: %w", err)
+		}
+		size = info.Size()
+	}
+	r := bufio.NewReader(io.LimitReader(file, size))
+	var offset int64
+	for {
+		header := make([]byte, recordHeaderSize)
+		n, err := io.ReadFull(r, header)
+		switch {
+		case err == io.EOF:
+			return offset, nil
+		case err == io.ErrUnexpectedEOF:
+			return offset, errTornRecord
+		case err != nil:
+			return offset, fmt.Errorf("cannot read journal: %w", err)
+		}
+		if binary.BigEndian.Uint32(header[12:16]) != headerChecksum(header) {
+			return offset, fmt.Errorf("%w: checksum mismatch of header at offset %d", ErrCorruptJournal, offset)
+		}
+		seq := binary.BigEndian.Uint64(header[0:8])
+		length := int64(binary.BigEndian.Uint32(header[8:12]))
+		if length > size-offset-recordHeaderSize {
+			// The intact header tells the message has been
+			// cut by an interrupted append.
+			return offset, errTornRecord
+		}
+		record := append(header, make([]byte, length)...)
+		if _, err := io.ReadFull(r, record[n:]); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return offset, errTornRecord
+			}
+			return offset, fmt.Errorf("cannot read journal: %w", err)
+		}
+		if binary.BigEndian.Uint32(header[16:20]) != recordChecksum(record) {
+			return offset, fmt.Errorf("%w: checksum mismatch of message %d at offset %d", ErrCorruptJournal, seq, offset)
+		}
+		offset += int64(len(record))
+		if seq < from {
+			continue
+		}
+		if err := fn(seq, record[recordHeaderSize:]); err != nil {
+			return offset, err
+		}
+	}
+}
+
+//--------------------
+// DURABLE
+//--------------------
+
+// Durable processes messages with an Actor after they have been
+// persisted in a Journal. Messages not processed before a crash are
+// replayed when a new Durable is started with the same Journal. So
+// each message is processed at least once and in order; handlers
+// should be idempotent, e.g. by using idempotency keys.
+type Durable struct {
+	mu        sync.Mutex
+	act       *Actor
+	journal   Journal
+	handler   func(msg []byte) error
+	snapshots bool
+	last      uint64
+}
+
+// GoDurable starts a Durable. Before it returns all messages left in
+// the Journal are processed. A handler error is raised as panic, so
+// that the default recoverer stops the Actor with this error and the
+// message stays in the Journal for the next start. A recoverer letting
+// the Actor continue loses the message once a later one is processed.
+func GoDurable(journal Journal, handler func(msg []byte) error, options ...Option) (*Durable, error) {
+	if journal == nil || handler == nil {
+		return nil, fmt.Errorf("%w: nil journal or handler", ErrInvalid)
+	}
+	act, err := Go(options...)
+	if err != nil {
+		return nil, err
+	}
+	d := &Durable{
+		act:     act,
+		journal: journal,
+		handler: handler,
+	}
+	var rerr error
+	if err := act.DoSync(func() {
+		rerr = journal.ReadFrom(0, d.process)
+	}); err != nil {
+		act.Stop()
+		return nil, err
+	}
+	if rerr != nil {
+		act.Stop()
+		return nil, fmt.Errorf("cannot replay journal: %w", rerr)
+	}
+	return d, nil
+}
+
+// GoRecovered starts a Durable from a snapshot written by Snapshot and
+// the messages journaled after it. First restore is called with the
+// state part of the snapshot, then the newer messages are replayed in
+// order. Only after that the Durable is returned. A nil snapshot starts
+// with the whole Journal. Missing or corrupt snapshot data or a gap in
+// the journaled messages are returned as error instead of running with
+// a half recovered state. Different from GoDurable processed messages
+// stay in the Journal until the next Snapshot.
+func GoRecovered(
+	snap io.Reader,
+	restore func(r io.Reader) error,
+	journal Journal,
+	handler func(msg []byte) error,
+	options ...Option) (*Durable, error) {
+	if journal == nil || handler == nil || (snap != nil && restore == nil) {
+		return nil, fmt.Errorf("%w: nil journal, handler, or restore", ErrInvalid)
+	}
+	act, err := Go(options...)
+	if err != nil {
+		return nil, err
+	}
+	d := &Durable{
+		act:       act,
+		journal:   journal,
+		handler:   handler,
+		snapshots: true,
+	}
+	var rerr error
+	if err := act.DoSync(func() {
+		rerr = d.recover(snap, restore)
+	}); err != nil {
+		act.Stop()
+		return nil, err
+	}
+	if rerr != nil {
+		act.Stop()
+		return nil, rerr
+	}
+	return d, nil
+}
+
+// Snapshot writes the sequence number of the last processed message
+// followed by the state written by save to w. It is done inside the
+// backend, so the state is consistent with the sequence number. After
+// success the Journal is truncated up to the snapshot.
+func (d *Durable) Snapshot(w io.Writer, save func(w io.Writer) error) error {
+	if w == nil || save == nil {
+		return fmt.Errorf("%w: nil writer or save", ErrInvalid)
+	}
+	var serr error
+	if err := d.act.DoSync(func() {
+		header := make([]byte, 8)
+		binary.BigEndian.PutUint64(header, d.last)
+		if _, err := w.Write(header); err != nil {
+			serr = fmt.Errorf("cannot write snapshot: %w", err)
+			return
+		}
+		if err := save(w); err != nil {
+			serr = fmt.Errorf("cannot save snapshot: %w", err)
+			return
+		}
+		if d.last > 0 {
+			serr = d.journal.Truncate(d.last)
+		}
+	}); err != nil {
+		return err
+	}
+	return serr
+}
+
+// Send appends the message to the Journal and queues it for processing.
+// It returns when the message is persisted.
+func (d *Durable) Send(msg []byte) error {
+	if err := d.act.alive(); err != nil {
+		return err
+	}
+	// Appending and queueing have to be done in the same order.
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	seq, err := d.journal.Append(msg)
+	if err != nil {
+		return err
+	}
+	return d.act.DoAsync(func() {
+		if err := d.process(seq, msg); err != nil {
+			panic(err)
+		}
+	})
+}
+
+// Actor returns the Actor processing the messages, e.g. for
+// waiting for it or checking its error.
+func (d *Durable) Actor() *Actor {
+	return d.act
+}
+
+// Stop terminates the Durable. Queued messages stay in the Journal.
+func (d *Durable) Stop() {
+	d.act.Stop()
+}
+
+// process handles a message inside the backend and truncates the
+// Journal after success if no snapshots are used.
+func (d *Durable) process(seq uint64, msg []byte) error {
+	if err := d.handler(msg); err != nil {
+		return fmt.Errorf("durable message %d: %w", seq, err)
+	}
+	d.last = seq
+	if d.snapshots {
+		return nil
+	}
+	return d.journal.Truncate(seq)
+}
+
+// recover restores the snapshot and replays the newer messages
+// inside the backend.
+func (d *Durable) recover(snap io.Reader, restore func(r io.Reader) error) error {
+	if snap != nil {
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(snap, header); err != nil {
+			return fmt.Errorf("cannot read snapshot header: %w", err)
+		}
+		d.last = binary.BigEndian.Uint64(header)
+		if err := restore(snap); err != nil {
+			return fmt.Errorf("cannot restore snapshot at message %d: %w", d.last, err)
+		}
+	}
+	err := d.journal.ReadFrom(d.last+1, func(seq uint64, msg []byte) error {
+		if d.last > 0 && seq != d.last+1 {
+			return fmt.Errorf("journal gap: message %d follows %d", seq, d.last)
+		}
+		return d.process(seq, msg)
+	})
+	if err != nil {
+		return fmt.Errorf("cannot replay journal: %w", err)
+	}
+	return nil
+}
+
+//--------------------
+// SNAPSHOT VERSIONS
+//--------------------
+
+// SnapshotMigration converts the state data of a snapshot from the
+// version it is registered for to the next version.
+type SnapshotMigration func(old []byte) ([]byte, error)
+
+// VersionedSave wraps a save function for Snapshot so that the schema
+// version of the state is written in front of the state data.
+func VersionedSave(version int, save func(w io.Writer) error) func(w io.Writer) error {
+	return func(w io.Writer) error {
+		if save == nil {
+			return fmt.Errorf("%w: nil save", ErrInvalid)
+		}
+		header := make([]byte, 4)
+		binary.BigEndian.PutUint32(header, uint32(version))
+		if _, err := w.Write(header); err != nil {
+			return fmt.Errorf("cannot write snapshot version: %w", err)
+		}
+		return save(w)
+	}
+}
+
+// VersionedRestore wraps a restore function for GoRecovered reading
+// state data written with VersionedSave. Data of an older version is
+// passed through the migrations in order until it reaches the current
+// version, the migration of a version converts it to the next one.
+// Missing steps are reported before any migration is applied.
+func VersionedRestore(
+	current int,
+	migrations map[int]SnapshotMigration,
+	restore func(r io.Reader) error) func(r io.Reader) error {
+	return func(r io.Reader) error {
+		if restore == nil {
+			return fmt.Errorf("%w: nil restore", ErrInvalid)
+		}
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return fmt.Errorf("cannot read snapshot version: %w", err)
+		}
+		version := int(binary.BigEndian.Uint32(header))
+		if version == current {
+			return restore(r)
+		}
+		if version > current {
+			return fmt.Errorf("snapshot version %d is newer than %d", version, current)
+		}
+		var missing []string
+		for v := version; v < current; v++ {
+			if migrations[v] == nil {
+				missing = append(missing, fmt.Sprintf("%d->%d", v, v+1))
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("cannot migrate snapshot from version %d to %d: missing migrations %s",
+				version, current, strings.Join(missing, ", "))
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("cannot read snapshot state: %w", err)
+		}
+		for v := version; v < current; v++ {
+			if data, err = migrations[v](data); err != nil {
+				return fmt.Errorf("cannot migrate snapshot from version %d to %d: %w", v, v+1, err)
+			}
+		}
+		return restore(bytes.NewReader(data))
+	}
+}
+
+// EOF
@@ -0,0 +1,318 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDurableRestart verifies that messages not processed before a
+// crash are processed in order after a restart with the same journal.
+func TestDurableRestart(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	path := filepath.Join(t.TempDir(), "billing.journal")
+	journal, err := actor.NewFileJournal(path)
+	assert.OK(err)
+
+	// First run crashes while handling the fourth message. Processing
+	// waits until all messages are sent.
+	first := []string{}
+	sent := make(chan struct{})
+	d, err := actor.GoDurable(journal, func(msg []byte) error {
+		<-sent
+		if string(msg) == "msg-3" {
+			return errors.New("crash")
+		}
+		first = append(first, string(msg))
+		return nil
+	})
+	assert.OK(err)
+	for i := 0; i < 10; i++ {
+		assert.OK(d.Send([]byte(fmt.Sprintf("msg-%d", i))))
+	}
+	close(sent)
+	<-d.Actor().Done()
+	assert.ErrorMatch(d.Actor().Err(), ".*durable message 4: crash")
+	assert.Equal(first, []string{"msg-0", "msg-1", "msg-2"})
+	assert.OK(journal.Close())
+
+	// Restart from the same journal processes the rest in order.
+	journal, err = actor.NewFileJournal(path)
+	assert.OK(err)
+	second := []string{}
+	d, err = actor.GoDurable(journal, func(msg []byte) error {
+		second = append(second, string(msg))
+		return nil
+	})
+	assert.OK(err)
+	assert.OK(d.Send([]byte("msg-10")))
+	assert.OK(d.Actor().DoSync(func() {}))
+	expected := []string{}
+	for i := 3; i <= 10; i++ {
+		expected = append(expected, fmt.Sprintf("msg-%d", i))
+	}
+	assert.Equal(second, expected)
+	d.Stop()
+	assert.OK(journal.Close())
+
+	// All processed, so a further restart has nothing to replay.
+	journal, err = actor.NewFileJournal(path)
+	assert.OK(err)
+	replayed := 0
+	d, err = actor.GoDurable(journal, func(msg []byte) error {
+		replayed++
+		return nil
+	})
+	assert.OK(err)
+	assert.Equal(replayed, 0)
+	d.Stop()
+	assert.OK(journal.Close())
+}
+
+// TestDurableRecovered verifies the cold start from a snapshot and
+// the messages journaled after it.
+func TestDurableRecovered(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ledger.journal")
+	journal, err := actor.NewFileJournal(path)
+	assert.OK(err)
+
+	// Ledger state with JSON snapshots.
+	ledger := map[string]int{}
+	handler := func(msg []byte) error {
+		ledger[string(msg[:1])] += int(msg[1] - '0')
+		return nil
+	}
+	save := func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(ledger)
+	}
+	restore := func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&ledger)
+	}
+	traffic := func(d *actor.Durable, from, to int) {
+		for i := from; i < to; i++ {
+			assert.OK(d.Send([]byte(fmt.Sprintf("%c%d", 'a'+i%3, i%10))))
+		}
+	}
+
+	// Control run never crashing.
+	control := map[string]int{}
+	for i := 0; i < 30; i++ {
+		control[string(rune('a'+i%3))] += i % 10
+	}
+
+	d, err := actor.GoRecovered(nil, nil, journal, handler)
+	assert.OK(err)
+	traffic(d, 0, 10)
+	var snap bytes.Buffer
+	assert.OK(d.Snapshot(&snap, save))
+	traffic(d, 10, 30)
+	assert.OK(d.Actor().DoSync(func() {}))
+	d.Stop()
+	assert.OK(journal.Close())
+
+	// Crash loses the in-memory state, recovery restores it.
+	ledger = map[string]int{}
+	journal, err = actor.NewFileJournal(path)
+	assert.OK(err)
+	d, err = actor.GoRecovered(bytes.NewReader(snap.Bytes()), restore, journal, handler)
+	assert.OK(err)
+	assert.OK(d.Actor().DoSync(func() {
+		assert.Equal(ledger, control)
+	}))
+	traffic(d, 30, 31)
+	d.Stop()
+	assert.OK(journal.Close())
+
+	// Corrupt snapshot and missing journal messages fail the start.
+	journal, err = actor.NewFileJournal(path)
+	assert.OK(err)
+	_, err = actor.GoRecovered(bytes.NewReader(snap.Bytes()[:4]), restore, journal, handler)
+	assert.ErrorMatch(err, "cannot read snapshot header.*")
+	_, err = actor.GoRecovered(bytes.NewReader([]byte("garbage!{")), restore, journal, handler)
+	assert.ErrorMatch(err, "cannot restore snapshot.*")
+	var old bytes.Buffer
+	assert.OK(binary.Write(&old, binary.BigEndian, uint64(3)))
+	assert.OK(json.NewEncoder(&old).Encode(map[string]int{}))
+	_, err = actor.GoRecovered(&old, restore, journal, handler)
+	assert.ErrorMatch(err, "cannot replay journal: journal gap: message 11 follows 3")
+	assert.OK(journal.Close())
+}
+
+// TestFileJournalCrashedTruncate verifies that a crash between the
+// commit and the truncation of the journal file neither reuses the
+// sequence numbers nor replays processed messages.
+func TestFileJournalCrashedTruncate(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	path := filepath.Join(t.TempDir(), "crash.journal")
+	journal, err := actor.NewFileJournal(path)
+	assert.OK(err)
+	for i := 0; i < 3; i++ {
+		_, err := journal.Append([]byte{byte('a' + i)})
+		assert.OK(err)
+	}
+	data, err := os.ReadFile(path)
+	assert.OK(err)
+	assert.OK(journal.Truncate(3))
+	assert.OK(journal.Close())
+
+	// The truncation of the journal file got lost.
+	assert.OK(os.WriteFile(path, data, 0o600))
+	journal, err = actor.NewFileJournal(path)
+	assert.OK(err)
+	defer journal.Close()
+	assert.OK(journal.ReadFrom(0, func(seq uint64, msg []byte) error {
+		t.Fatalf("processed message %d replayed", seq)
+		return nil
+	}))
+	seq, err := journal.Append([]byte("d"))
+	assert.OK(err)
+	assert.Equal(seq, uint64(4))
+}
+
+// TestFileJournalDamage verifies that a torn last message is cut
+// off while a corrupt message is reported.
+func TestFileJournalDamage(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	path := filepath.Join(t.TempDir(), "damage.journal")
+	journal, err := actor.NewFileJournal(path)
+	assert.OK(err)
+	for i := 0; i < 3; i++ {
+		_, err := journal.Append([]byte("message"))
+		assert.OK(err)
+	}
+	assert.OK(journal.Close())
+	data, err := os.ReadFile(path)
+	assert.OK(err)
+
+	// Interrupted append of the third message.
+	assert.OK(os.WriteFile(path, data[:len(data)-3], 0o600))
+	journal, err = actor.NewFileJournal(path)
+	assert.OK(err)
+	var seqs []uint64
+	assert.OK(journal.ReadFrom(0, func(seq uint64, msg []byte) error {
+		seqs = append(seqs, seq)
+		return nil
+	}))
+	assert.Equal(seqs, []uint64{1, 2})
+	seq, err := journal.Append([]byte("message"))
+	assert.OK(err)
+	assert.Equal(seq, uint64(3))
+	assert.OK(journal.Close())
+
+	// Flipped bit in the second message.
+	record := len(data) / 3
+	damaged := append([]byte{}, data...)
+	damaged[2*record-1] ^= 0x01
+	assert.OK(os.WriteFile(path, damaged, 0o600))
+	_, err = actor.NewFileJournal(path)
+	assert.True(errors.Is(err, actor.ErrCorruptJournal))
+	assert.ErrorMatch(err, "corrupt journal: checksum mismatch of message 2 at offset 27")
+
+	// Damaged length of the second message must not be taken as
+	// torn record and cut off.
+	damaged = append([]byte{}, data...)
+	damaged[record+8] ^= 0x01
+	assert.OK(os.WriteFile(path, damaged, 0o600))
+	_, err = actor.NewFileJournal(path)
+	assert.True(errors.Is(err, actor.ErrCorruptJournal))
+	assert.ErrorMatch(err, "corrupt journal: checksum mismatch of header at offset 27")
+	info, err := os.Stat(path)
+	assert.OK(err)
+	assert.Equal(info.Size(), int64(len(data)))
+}
+
+// TestDurableSnapshotVersions verifies the migration of a snapshot
+// written with an older state version.
+func TestDurableSnapshotVersions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	journal, err := actor.NewFileJournal(filepath.Join(t.TempDir(), "account.journal"))
+	assert.OK(err)
+	defer journal.Close()
+	handler := func(msg []byte) error { return nil }
+
+	// Version 1 of the state writes the snapshot.
+	type accountV1 struct {
+		Owner   string
+		Balance int
+	}
+	d, err := actor.GoRecovered(nil, nil, journal, handler)
+	assert.OK(err)
+	v1 := accountV1{Owner: "alice", Balance: 42}
+	var snap bytes.Buffer
+	assert.OK(d.Snapshot(&snap, actor.VersionedSave(1, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(v1)
+	})))
+	d.Stop()
+
+	// Version 2 renames the balance, version 3 adds the currency.
+	type accountV3 struct {
+		Owner    string
+		Amount   int
+		Currency string
+	}
+	migrate := func(change func(fields map[string]any)) actor.SnapshotMigration {
+		return func(old []byte) ([]byte, error) {
+			fields := map[string]any{}
+			if err := json.Unmarshal(old, &fields); err != nil {
+				return nil, err
+			}
+			change(fields)
+			return json.Marshal(fields)
+		}
+	}
+	migrations := map[int]actor.SnapshotMigration{
+		1: migrate(func(fields map[string]any) {
+			fields["Amount"] = fields["Balance"]
+			delete(fields, "Balance")
+		}),
+		2: migrate(func(fields map[string]any) {
+			fields["Currency"] = "EUR"
+		}),
+	}
+	var v3 accountV3
+	restore := func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(&v3)
+	}
+	d, err = actor.GoRecovered(bytes.NewReader(snap.Bytes()), actor.VersionedRestore(3, migrations, restore), journal, handler)
+	assert.OK(err)
+	d.Stop()
+	assert.Equal(v3, accountV3{Owner: "alice", Amount: 42, Currency: "EUR"})
+
+	// Gaps and newer versions fail the start.
+	_, err = actor.GoRecovered(bytes.NewReader(snap.Bytes()), actor.VersionedRestore(4, migrations, restore), journal, handler)
+	assert.ErrorMatch(err, ".*cannot migrate snapshot from version 1 to 4: missing migrations 3->4")
+	delete(migrations, 1)
+	_, err = actor.GoRecovered(bytes.NewReader(snap.Bytes()), actor.VersionedRestore(4, migrations, restore), journal, handler)
+	assert.ErrorMatch(err, ".*missing migrations 1->2, 3->4")
+	_, err = actor.GoRecovered(bytes.NewReader(snap.Bytes()), actor.VersionedRestore(0, migrations, restore), journal, handler)
+	assert.ErrorMatch(err, ".*snapshot version 1 is newer than 0")
+}
+
+// EOF
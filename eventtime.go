@@ -0,0 +1,137 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrLateEvent is the error of an Action submitted with DoEventTime
+// which is dropped because it arrived too late.
+var ErrLateEvent = errors.New("late event")
+
+//--------------------
+// EVENT TIME
+//--------------------
+
+// LatePolicy defines how an Actor handles late events.
+type LatePolicy int
+
+const (
+	// LateDrop drops late events. It is the default.
+	LateDrop LatePolicy = iota
+
+	// LateProcess executes late events immediately, even if this
+	// breaks the event time order.
+	LateProcess
+)
+
+// DoEventTime sends an Action for the given event time to the backend.
+// With WithEventTime the backend holds it until the event time plus the
+// watermark delay has passed and executes the held Actions ordered by
+// their event times. So Actions arriving at most the delay after their
+// event time are executed in event time order. Others are late if an
+// Action with a later event time has already been executed and are
+// handled according to the LatePolicy. Event times have to be taken
+// from the same clock as the Actor's. Events still held when the Actor
+// stops are dropped. Without WithEventTime the Actions are executed in
+// the order of their arrival.
+func (act *Actor) DoEventTime(eventTime time.Time, action Action) error {
+	if err := act.admit(context.Background(), action != nil); err != nil {
+		return err
+	}
+	if eventTime.IsZero() {
+		return fmt.Errorf("%w: zero event time", ErrInvalid)
+	}
+	req := newActionRequest(context.Background(), action)
+	req.extension().eventTime = eventTime
+	return act.sendAsync(req)
+}
+
+// dispatch executes the request or holds it if it is an event
+// to be ordered by its event time.
+func (act *Actor) dispatch(req *request) {
+	if req.ext == nil || req.ext.eventTime.IsZero() || act.eventDelay <= 0 {
+		act.execute(req)
+		return
+	}
+	if req.ext.eventTime.Before(act.watermark) {
+		// Late, the order cannot be kept anymore.
+		if act.latePolicy == LateProcess {
+			act.execute(req)
+			return
+		}
+		act.leaveQueue(req)
+		act.dropped++
+		req.err = ErrLateEvent
+		req.finish()
+		return
+	}
+	// Insert behind events with an equal event time.
+	i := sort.Search(len(act.events), func(i int) bool {
+		return act.events[i].ext.eventTime.After(req.ext.eventTime)
+	})
+	act.events = append(act.events, nil)
+	copy(act.events[i+1:], act.events[i:])
+	act.events[i] = req
+	act.scheduleEvents()
+}
+
+// eventDue returns the channel signaling that held events are due.
+// It is nil if no events are held.
+func (act *Actor) eventDue() <-chan time.Time {
+	if act.eventTimer == nil || len(act.events) == 0 {
+		return nil
+	}
+	return act.eventTimer.C
+}
+
+// releaseEvents executes the held events which are due.
+func (act *Actor) releaseEvents() {
+	now := time.Now()
+	for len(act.events) > 0 && !act.events[0].ext.eventTime.Add(act.eventDelay).After(now) {
+		req := act.events[0]
+		act.events = act.events[1:]
+		act.watermark = req.ext.eventTime
+		act.execute(req)
+	}
+	act.scheduleEvents()
+}
+
+// scheduleEvents sets the timer for the earliest held event.
+func (act *Actor) scheduleEvents() {
+	if len(act.events) == 0 {
+		return
+	}
+	d := time.Until(act.events[0].ext.eventTime.Add(act.eventDelay))
+	if act.eventTimer == nil {
+		act.eventTimer = time.NewTimer(d)
+		return
+	}
+	if !act.eventTimer.Stop() {
+		select {
+		case <-act.eventTimer.C:
+		default:
+		}
+	}
+	act.eventTimer.Reset(d)
+}
+
+// EOF
@@ -0,0 +1,89 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestEventTimeOrder verifies that out-of-order events within the
+// watermark delay are executed in event time order.
+func TestEventTimeOrder(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithEventTime(20*time.Millisecond, actor.LateDrop))
+	assert.OK(err)
+	defer act.Stop()
+
+	base := time.Now()
+	offsets := []int{3, 1, 4, 0, 2, 2}
+	executed := []int{}
+	for _, offset := range offsets {
+		offset := offset
+		assert.OK(act.DoEventTime(base.Add(time.Duration(offset)*time.Millisecond), func() {
+			executed = append(executed, offset)
+		}))
+	}
+	assert.OK(act.DoSync(func() {
+		assert.Length(executed, 0)
+	}))
+	time.Sleep(50 * time.Millisecond)
+	assert.OK(act.DoSync(func() {
+		assert.Equal(executed, []int{0, 1, 2, 2, 3, 4})
+	}))
+
+	// Too late, a later event has been executed already.
+	assert.OK(act.DoEventTime(base, func() {
+		executed = append(executed, -1)
+	}))
+	assert.OK(act.DoSync(func() {
+		assert.Length(executed, 6)
+	}))
+
+	assert.True(errors.Is(act.DoEventTime(time.Time{}, func() {}), actor.ErrInvalid))
+}
+
+// TestEventTimeLateProcess verifies the immediate execution of
+// late events.
+func TestEventTimeLateProcess(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithEventTime(5*time.Millisecond, actor.LateProcess))
+	assert.OK(err)
+	defer act.Stop()
+
+	base := time.Now()
+	executed := []string{}
+	assert.OK(act.DoEventTime(base.Add(time.Millisecond), func() {
+		executed = append(executed, "first")
+	}))
+	time.Sleep(20 * time.Millisecond)
+	assert.OK(act.DoEventTime(base, func() {
+		executed = append(executed, "late")
+	}))
+	assert.OK(act.DoSync(func() {
+		assert.Equal(executed, []string{"first", "late"})
+	}))
+
+	_, err = actor.Go(actor.WithEventTime(0, actor.LateDrop))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
@@ -0,0 +1,207 @@
+// Tideland Go Actor - Banking Example
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+// Package banking shows how to wrap state with an Actor. An Account
+// serializes deposits and withdrawals, a SavingsAccount additionally
+// accrues interest with a Repeater. When closed, the balance is stored
+// by the finalizer after the last Action has been executed.
+package banking // import "tideland.dev/go/actor/examples/banking"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+var (
+	// ErrInvalidAmount is returned for non-positive amounts.
+	ErrInvalidAmount = errors.New("invalid amount")
+
+	// ErrInsufficientFunds is returned if a withdrawal exceeds the
+	// balance.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+)
+
+//--------------------
+// STORE
+//--------------------
+
+// Store persists the balance of an account when it is closed.
+type Store interface {
+	Save(id string, balance int64) error
+}
+
+// MemoryStore is a simple Store keeping the balances in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	balances map[string]int64
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		balances: make(map[string]int64),
+	}
+}
+
+// Save implements Store.
+func (s *MemoryStore) Save(id string, balance int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[id] = balance
+	return nil
+}
+
+// Balance returns the stored balance of an account.
+func (s *MemoryStore) Balance(id string) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	balance, ok := s.balances[id]
+	return balance, ok
+}
+
+//--------------------
+// ACCOUNT
+//--------------------
+
+// Account is a bank account with a balance in cents.
+type Account struct {
+	id      string
+	balance int64
+	act     *actor.Actor
+	closed  chan struct{}
+}
+
+// NewAccount opens an account with the given balance. Its balance is
+// saved to the store when the account is closed.
+func NewAccount(id string, balance int64, store Store) (*Account, error) {
+	a := &Account{
+		id:      id,
+		balance: balance,
+		closed:  make(chan struct{}),
+	}
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		// The backend has stopped, so the balance can be accessed.
+		defer close(a.closed)
+		if serr := store.Save(a.id, a.balance); serr != nil {
+			return fmt.Errorf("saving account %q: %v", a.id, serr)
+		}
+		return err
+	}))
+	if err != nil {
+		return nil, err
+	}
+	a.act = act
+	return a, nil
+}
+
+// ID returns the ID of the account.
+func (a *Account) ID() string {
+	return a.id
+}
+
+// Deposit adds the amount to the balance.
+func (a *Account) Deposit(ctx context.Context, amount int64) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	return a.act.DoSyncWithContext(ctx, func() {
+		a.balance += amount
+	})
+}
+
+// Withdraw subtracts the amount from the balance if it is covered.
+func (a *Account) Withdraw(ctx context.Context, amount int64) error {
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	var werr error
+	if err := a.act.DoSyncWithContext(ctx, func() {
+		if a.balance < amount {
+			werr = ErrInsufficientFunds
+			return
+		}
+		a.balance -= amount
+	}); err != nil {
+		return err
+	}
+	return werr
+}
+
+// Balance returns the current balance.
+func (a *Account) Balance(ctx context.Context) (int64, error) {
+	var balance int64
+	if err := a.act.DoRead(ctx, func() {
+		balance = a.balance
+	}); err != nil {
+		return 0, err
+	}
+	return balance, nil
+}
+
+// Close executes the queued Actions and waits until the balance is
+// saved. It returns the error of saving.
+func (a *Account) Close(grace time.Duration) error {
+	a.act.StopGraceful(grace)
+	<-a.closed
+	return a.act.Err()
+}
+
+//--------------------
+// SAVINGS ACCOUNT
+//--------------------
+
+// SavingsAccount is an Account accruing interest in a fixed interval.
+type SavingsAccount struct {
+	*Account
+	accrual *actor.Repeater
+}
+
+// NewSavingsAccount opens a savings account. The interest rate in basis
+// points is applied to the balance in each interval.
+func NewSavingsAccount(
+	id string,
+	balance int64,
+	store Store,
+	basisPoints int64,
+	interval time.Duration) (*SavingsAccount, error) {
+	a, err := NewAccount(id, balance, store)
+	if err != nil {
+		return nil, err
+	}
+	accrual, err := a.act.StartRepeater(interval, func() {
+		a.balance += a.balance * basisPoints / 10000
+	})
+	if err != nil {
+		a.Close(0)
+		return nil, err
+	}
+	return &SavingsAccount{
+		Account: a,
+		accrual: accrual,
+	}, nil
+}
+
+// Close stops the interest accrual and closes the account.
+func (s *SavingsAccount) Close(grace time.Duration) error {
+	s.accrual.Stop()
+	return s.Account.Close(grace)
+}
+
+// EOF
@@ -0,0 +1,124 @@
+// Tideland Go Actor - Banking Example - Integration Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package banking_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+	"tideland.dev/go/actor/examples/banking"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestConcurrentTransactions verifies that concurrent deposits and
+// withdrawals are serialized and never overdraw the account.
+func TestConcurrentTransactions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx := context.Background()
+	store := banking.NewMemoryStore()
+	acc, err := banking.NewAccount("checking", 0, store)
+	assert.OK(err)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	withdrawn := int64(0)
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				assert.OK(acc.Deposit(ctx, 10))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				err := acc.Withdraw(ctx, 15)
+				if errors.Is(err, banking.ErrInsufficientFunds) {
+					continue
+				}
+				assert.OK(err)
+				mu.Lock()
+				withdrawn += 15
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	balance, err := acc.Balance(ctx)
+	assert.OK(err)
+	assert.True(balance >= 0)
+	assert.Equal(balance, 50*20*10-withdrawn)
+	assert.ErrorMatch(acc.Deposit(ctx, 0), ".*invalid amount.*")
+	assert.OK(acc.Close(time.Second))
+}
+
+// TestClosePersists verifies that closing executes the queued Actions
+// and stores the final balance.
+func TestClosePersists(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx := context.Background()
+	store := banking.NewMemoryStore()
+	acc, err := banking.NewAccount("checking", 100, store)
+	assert.OK(err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.OK(acc.Deposit(ctx, 5))
+		}()
+	}
+	wg.Wait()
+	assert.OK(acc.Close(time.Second))
+
+	balance, ok := store.Balance("checking")
+	assert.True(ok)
+	assert.Equal(balance, int64(150))
+
+	// A closed account rejects further transactions.
+	assert.True(errors.Is(acc.Deposit(ctx, 5), actor.ErrDone))
+}
+
+// TestSavingsAccount verifies the interest accrual and that the
+// accrued balance is stored when closing.
+func TestSavingsAccount(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx := context.Background()
+	store := banking.NewMemoryStore()
+	acc, err := banking.NewSavingsAccount("savings", 10000, store, 100, 5*time.Millisecond)
+	assert.OK(err)
+
+	assert.Retry(func() bool {
+		balance, err := acc.Balance(ctx)
+		return err == nil && balance >= 10201
+	}, 100, 10*time.Millisecond)
+	assert.OK(acc.Withdraw(ctx, 1000))
+	assert.OK(acc.Close(time.Second))
+
+	stored, ok := store.Balance("savings")
+	assert.True(ok)
+	assert.True(stored >= 9201)
+}
+
+// EOF
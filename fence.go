@@ -0,0 +1,43 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+)
+
+//--------------------
+// FENCE
+//--------------------
+
+// Fence enqueues a marker and returns when it has been reached. So all
+// Actions the calling goroutine enqueued before have been executed,
+// while Actions of other goroutines enqueued later are not waited for.
+// Events held back by WithEventTime are not covered.
+func (act *Actor) Fence(ctx context.Context) error {
+	if err := act.admit(ctx, true); err != nil {
+		return err
+	}
+	marker := newMarker()
+	marker.ctx = ctx
+	if err := act.send(marker); err != nil {
+		return err
+	}
+	select {
+	case <-marker.done:
+		return marker.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// EOF
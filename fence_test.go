@@ -0,0 +1,78 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestFence verifies that a Fence waits for the Actions enqueued
+// before by the same goroutine while another one floods the Actor.
+func TestFence(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithQueueCap(1024))
+	assert.OK(err)
+	defer act.Stop()
+
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	flooded := 0
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for !stop.Load() {
+			if act.DoAsync(func() { flooded++ }) != nil {
+				return
+			}
+		}
+	}()
+
+	ctx := context.Background()
+	counter := 0
+	for round := 1; round <= 5; round++ {
+		for i := 0; i < 100; i++ {
+			assert.OK(act.DoAsync(func() { counter++ }))
+		}
+		assert.OK(act.Fence(ctx))
+		// The Fence synchronizes with the backend.
+		assert.Equal(counter, round*100)
+	}
+	stop.Store(true)
+	wg.Wait()
+
+	// Fences are not counted as received Actions.
+	qctx, qcancel := context.WithTimeout(ctx, time.Second)
+	defer qcancel()
+	assert.OK(actor.Quiesce(qctx, act))
+
+	// A done context ends waiting.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() { <-release }))
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorMatch(act.Fence(tctx), ".*deadline exceeded.*")
+	close(release)
+}
+
+// EOF
@@ -0,0 +1,137 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrInvalidTransition is wrapped by the TransitionError of a state
+// transition not allowed by an FSM.
+var ErrInvalidTransition = errors.New("invalid transition")
+
+// TransitionError describes a state transition not allowed by an FSM.
+type TransitionError[St comparable] struct {
+	From St
+	To   St
+}
+
+// Error implements the error interface.
+func (e *TransitionError[St]) Error() string {
+	return fmt.Sprintf("%v from %v to %v", ErrInvalidTransition, e.From, e.To)
+}
+
+// Unwrap returns ErrInvalidTransition.
+func (e *TransitionError[St]) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+//--------------------
+// FSM
+//--------------------
+
+// TransitionEvent is passed to the observer of an FSM after each
+// performed transition.
+type TransitionEvent[St comparable] struct {
+	From St
+	To   St
+}
+
+// FSM is a finite state machine whose state is owned by an Actor, e.g.
+// an order moving from created to paid to shipped. The allowed edges
+// are validated inside the Actor, so concurrent conflicting transitions
+// are serialized and only the valid ones are performed.
+type FSM[St comparable] struct {
+	act         *Actor
+	state       St
+	transitions map[St]map[St]struct{}
+	observer    func(event TransitionEvent[St])
+}
+
+// NewFSM creates an FSM in the initial state using the Actor. The
+// transitions map each state to the states reachable from it. The
+// optional observer is called inside the Actor after each performed
+// transition, so it sees them in their order.
+func NewFSM[St comparable](
+	act *Actor,
+	initial St,
+	transitions map[St][]St,
+	observer func(event TransitionEvent[St])) (*FSM[St], error) {
+	if act == nil || len(transitions) == 0 {
+		return nil, fmt.Errorf("%w: nil actor or no transitions", ErrInvalid)
+	}
+	fsm := &FSM[St]{
+		act:         act,
+		state:       initial,
+		transitions: make(map[St]map[St]struct{}, len(transitions)),
+		observer:    observer,
+	}
+	for from, tos := range transitions {
+		edges := make(map[St]struct{}, len(tos))
+		for _, to := range tos {
+			edges[to] = struct{}{}
+		}
+		fsm.transitions[from] = edges
+	}
+	return fsm, nil
+}
+
+// Transition validates the edge from the current state to the given
+// one inside the Actor. If it is allowed the optional apply function
+// changes the data belonging to the state and the FSM moves to the new
+// state. An invalid edge returns a TransitionError, an error of apply
+// is returned without moving.
+func (fsm *FSM[St]) Transition(ctx context.Context, to St, apply func() error) error {
+	var err error
+	if aerr := fsm.act.DoSyncWithContext(ctx, func() {
+		from := fsm.state
+		if _, ok := fsm.transitions[from][to]; !ok {
+			err = &TransitionError[St]{From: from, To: to}
+			return
+		}
+		if apply != nil {
+			if err = apply(); err != nil {
+				return
+			}
+		}
+		fsm.state = to
+		if fsm.observer != nil {
+			fsm.observer(TransitionEvent[St]{From: from, To: to})
+		}
+	}); aerr != nil {
+		return aerr
+	}
+	return err
+}
+
+// State returns the current state. It must only be called inside the
+// Actor, e.g. as Projection for WatchFields. Other callers use Current.
+func (fsm *FSM[St]) State() St {
+	return fsm.state
+}
+
+// Current returns the current state read via the Actor.
+func (fsm *FSM[St]) Current(ctx context.Context) (St, error) {
+	var state St
+	err := fsm.act.DoRead(ctx, func() {
+		state = fsm.state
+	})
+	return state, err
+}
+
+// EOF
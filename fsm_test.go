@@ -0,0 +1,168 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// orderStates are the transitions of an order used in the tests.
+var orderStates = map[string][]string{
+	"created": {"paid", "cancelled"},
+	"paid":    {"shipped", "cancelled"},
+}
+
+// TestFSMTransitions verifies valid chains and invalid edges.
+func TestFSMTransitions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	ctx := context.Background()
+	amount := 0
+	fsm, err := actor.NewFSM(act, "created", orderStates, nil)
+	assert.OK(err)
+
+	assert.OK(fsm.Transition(ctx, "paid", func() error {
+		amount = 100
+		return nil
+	}))
+	assert.OK(fsm.Transition(ctx, "shipped", nil))
+	state, err := fsm.Current(ctx)
+	assert.OK(err)
+	assert.Equal(state, "shipped")
+
+	// Shipped is final.
+	err = fsm.Transition(ctx, "cancelled", func() error {
+		amount = 0
+		return nil
+	})
+	assert.True(errors.Is(err, actor.ErrInvalidTransition))
+	var terr *actor.TransitionError[string]
+	assert.True(errors.As(err, &terr))
+	assert.Equal(terr.From, "shipped")
+	assert.Equal(terr.To, "cancelled")
+	assert.ErrorMatch(err, "invalid transition from shipped to cancelled")
+
+	// A failing apply does not move.
+	fsm, err = actor.NewFSM(act, "created", orderStates, nil)
+	assert.OK(err)
+	errDeclined := errors.New("declined")
+	assert.True(errors.Is(fsm.Transition(ctx, "paid", func() error {
+		return errDeclined
+	}), errDeclined))
+	state, err = fsm.Current(ctx)
+	assert.OK(err)
+	assert.Equal(state, "created")
+
+	act.DoSync(func() {
+		assert.Equal(amount, 100)
+	})
+
+	_, err = actor.NewFSM[string](nil, "created", orderStates, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestFSMConflicts verifies that of concurrent conflicting
+// transitions only one is performed.
+func TestFSMConflicts(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	ctx := context.Background()
+	fsm, err := actor.NewFSM(act, "created", orderStates, nil)
+	assert.OK(err)
+
+	const callers = 20
+	var mu sync.Mutex
+	performed := 0
+	var wg sync.WaitGroup
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		to := "paid"
+		if i%2 == 0 {
+			to = "cancelled"
+		}
+		go func() {
+			defer wg.Done()
+			err := fsm.Transition(ctx, to, nil)
+			if err == nil {
+				mu.Lock()
+				performed++
+				mu.Unlock()
+				return
+			}
+			assert.True(errors.Is(err, actor.ErrInvalidTransition))
+		}()
+	}
+	wg.Wait()
+	// Either cancelled directly, or paid and then cancelled.
+	assert.True(performed == 1 || performed == 2)
+	state, err := fsm.Current(ctx)
+	assert.OK(err)
+	assert.True(state == "cancelled" || (performed == 1 && state == "paid"))
+}
+
+// TestFSMEvents verifies the order of the emitted transition events
+// and the observation via WatchFields.
+func TestFSMEvents(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	ctx := context.Background()
+	var events []actor.TransitionEvent[string]
+	fsm, err := actor.NewFSM(act, "created", orderStates, func(event actor.TransitionEvent[string]) {
+		events = append(events, event)
+	})
+	assert.OK(err)
+	changes, unsubscribe, err := act.WatchFields(map[string]actor.Projection{
+		"state": func() any { return fsm.State() },
+	}, 10)
+	assert.OK(err)
+	defer unsubscribe()
+
+	// Receive each change before the next one to avoid coalescing.
+	assert.OK(fsm.Transition(ctx, "paid", nil))
+	change := <-changes
+	assert.Equal(change.Old, "created")
+	assert.Equal(change.New, "paid")
+	assert.NotNil(fsm.Transition(ctx, "created", nil))
+	assert.OK(fsm.Transition(ctx, "shipped", nil))
+	change = <-changes
+	assert.Equal(change.Old, "paid")
+	assert.Equal(change.New, "shipped")
+
+	act.DoSync(func() {
+		assert.Equal(events, []actor.TransitionEvent[string]{
+			{From: "created", To: "paid"},
+			{From: "paid", To: "shipped"},
+		})
+	})
+}
+
+// EOF
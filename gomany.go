@@ -0,0 +1,94 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//--------------------
+// GO MANY
+//--------------------
+
+// Factory prepares the start of the i-th Actor of GoMany, e.g. by
+// loading its initial state, and returns the options for Go.
+type Factory func(ctx context.Context, i int) ([]Option, error)
+
+// GoMany starts n Actors with the options returned by the factory,
+// running at most parallel factories at the same time. The Actors are
+// returned in the order of their index. The first failing factory or
+// start, as well as the cancellation of the context, cancels the
+// context passed to the other factories. In that case all Actors
+// already started are stopped and waited for before the first error
+// is returned, so none is left running.
+func GoMany(ctx context.Context, n, parallel int, factory Factory) ([]*Actor, error) {
+	if ctx == nil || n < 0 || parallel <= 0 || factory == nil {
+		return nil, fmt.Errorf("%w: nil context or factory, or invalid count", ErrInvalid)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	acts := make([]*Actor, n)
+	var ferr error
+	var failOnce sync.Once
+	fail := func(err error) {
+		failOnce.Do(func() {
+			ferr = err
+			cancel()
+		})
+	}
+	inFlight := make(chan struct{}, parallel)
+	var wg sync.WaitGroup
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case inFlight <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			if ctx.Err() != nil {
+				return
+			}
+			options, err := factory(ctx, i)
+			if err != nil {
+				fail(fmt.Errorf("cannot prepare actor %d: %w", i, err))
+				return
+			}
+			act, err := Go(options...)
+			if err != nil {
+				fail(fmt.Errorf("cannot start actor %d: %w", i, err))
+				return
+			}
+			acts[i] = act
+		}(i)
+	}
+	wg.Wait()
+	if err := ctx.Err(); err != nil {
+		fail(err)
+	}
+	if ferr == nil {
+		return acts, nil
+	}
+	for _, act := range acts {
+		if act != nil {
+			act.Stop()
+			<-act.Done()
+		}
+	}
+	return nil, ferr
+}
+
+// EOF
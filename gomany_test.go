@@ -0,0 +1,103 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestGoMany verifies the bounded parallelism of the factories.
+func TestGoMany(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var inFlight atomic.Int64
+	var maxInFlight atomic.Int64
+	factory := func(ctx context.Context, i int) ([]actor.Option, error) {
+		current := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			highest := maxInFlight.Load()
+			if current <= highest || maxInFlight.CompareAndSwap(highest, current) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+		return []actor.Option{actor.WithUserData(i)}, nil
+	}
+
+	acts, err := actor.GoMany(context.Background(), 100, 8, factory)
+	assert.OK(err)
+	assert.Length(acts, 100)
+	assert.True(maxInFlight.Load() <= 8)
+	assert.True(maxInFlight.Load() > 1)
+	for i, act := range acts {
+		assert.Equal(act.UserData(), i)
+		act.Stop()
+	}
+
+	_, err = actor.GoMany(context.Background(), 10, 0, factory)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestGoManyFailure verifies that a failing factory and a canceled
+// context stop all Actors already started.
+func TestGoManyFailure(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var started atomic.Int64
+	var stopped atomic.Int64
+	errBroken := errors.New("broken database")
+	factory := func(ctx context.Context, i int) ([]actor.Option, error) {
+		if i == 50 {
+			return nil, errBroken
+		}
+		started.Add(1)
+		return []actor.Option{actor.WithFinalizer(func(err error) error {
+			stopped.Add(1)
+			return err
+		})}, nil
+	}
+
+	acts, err := actor.GoMany(context.Background(), 100, 4, factory)
+	assert.True(errors.Is(err, errBroken))
+	assert.ErrorContains(err, "actor 50")
+	assert.Nil(acts)
+	assert.True(started.Load() > 0)
+	assert.Equal(stopped.Load(), started.Load())
+
+	// Cancellation while the factories wait.
+	started.Store(0)
+	stopped.Store(0)
+	ctx, cancel := context.WithCancel(context.Background())
+	slow := func(fctx context.Context, i int) ([]actor.Option, error) {
+		if i == 10 {
+			cancel()
+		}
+		return factory(fctx, i)
+	}
+	acts, err = actor.GoMany(ctx, 100, 4, slow)
+	assert.True(errors.Is(err, context.Canceled))
+	assert.Nil(acts)
+	assert.Equal(stopped.Load(), started.Load())
+}
+
+// EOF
@@ -0,0 +1,69 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+)
+
+//--------------------
+// GROUP
+//--------------------
+
+// Group is a group of goroutines like errgroup.Group from
+// golang.org/x/sync.
+type Group interface {
+	Go(f func() error)
+}
+
+// RunIn returns a function for Group.Go running an Actor as member of
+// the group. It starts the Actor with the context of the group, e.g.
+// by passing it WithContext, and waits until the Actor is done. Then
+// it returns the error of the Actor, which is nil after a clean stop.
+// So the cancellation of the group stops the Actor and a failing Actor
+// cancels the group.
+func RunIn(ctx context.Context, start func(ctx context.Context) (*Actor, error)) func() error {
+	return func() error {
+		act, err := start(ctx)
+		if err != nil {
+			return err
+		}
+		select {
+		case <-act.Done():
+		case <-ctx.Done():
+			act.Stop()
+			<-act.Done()
+		}
+		return act.Err()
+	}
+}
+
+// GroupStopper adds a member to the group stopping the Actors when the
+// context of the group is canceled. The member ends when all Actors
+// are done.
+func GroupStopper(ctx context.Context, g Group, actors ...*Actor) {
+	g.Go(func() error {
+		for _, act := range actors {
+			select {
+			case <-act.Done():
+			case <-ctx.Done():
+				for _, act := range actors {
+					act.Stop()
+				}
+				<-act.Done()
+			}
+		}
+		return nil
+	})
+}
+
+// EOF
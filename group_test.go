@@ -0,0 +1,142 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRunInFailure verifies that a failing Actor cancels the other
+// members of the group.
+func TestRunInFailure(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	g, ctx := newGroup(context.Background())
+
+	var act *actor.Actor
+	started := make(chan struct{})
+	g.Go(actor.RunIn(ctx, func(ctx context.Context) (*actor.Actor, error) {
+		var err error
+		act, err = actor.Go(actor.WithContext(ctx))
+		close(started)
+		return act, err
+	}))
+	sibling := make(chan error, 1)
+	g.Go(func() error {
+		<-ctx.Done()
+		sibling <- ctx.Err()
+		return nil
+	})
+
+	<-started
+	assert.OK(act.DoAsync(func() {
+		panic("failure")
+	}))
+	err := g.Wait()
+	assert.ErrorMatch(err, ".*panic during actor action.*")
+	assert.True(errors.Is(<-sibling, context.Canceled))
+}
+
+// TestRunInCancel verifies that canceling the group stops the Actor
+// cleanly.
+func TestRunInCancel(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	parent, cancel := context.WithCancel(context.Background())
+	g, ctx := newGroup(parent)
+
+	var act *actor.Actor
+	started := make(chan struct{})
+	g.Go(actor.RunIn(ctx, func(ctx context.Context) (*actor.Actor, error) {
+		var err error
+		act, err = actor.Go(actor.WithContext(ctx))
+		close(started)
+		return act, err
+	}))
+	<-started
+	cancel()
+	assert.NoError(g.Wait())
+	assert.True(act.IsDone())
+	assert.NoError(act.Err())
+}
+
+// TestGroupStopper verifies that canceling the group stops the
+// Actors started outside of it.
+func TestGroupStopper(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	parent, cancel := context.WithCancel(context.Background())
+	g, ctx := newGroup(parent)
+
+	actA, err := actor.Go()
+	assert.OK(err)
+	actB, err := actor.Go()
+	assert.OK(err)
+	actor.GroupStopper(ctx, g, actA, actB)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.False(actA.IsDone())
+	cancel()
+	assert.NoError(g.Wait())
+	assert.True(actA.IsDone())
+	assert.True(actB.IsDone())
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// group mimics errgroup.Group created with WithContext.
+type group struct {
+	wg     sync.WaitGroup
+	cancel func()
+	once   sync.Once
+	err    error
+}
+
+// newGroup creates a group and its context.
+func newGroup(ctx context.Context) (*group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{cancel: cancel}, ctx
+}
+
+// Go runs the function as member of the group.
+func (g *group) Go(f func() error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for all members and returns the first error.
+func (g *group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
+
+// EOF
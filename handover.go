@@ -0,0 +1,193 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrMigrated is returned by all calls to an Actor whose
+// responsibility has been migrated to a successor.
+var ErrMigrated = errors.New("actor migrated")
+
+// MigratedError is returned by the calls to a migrated Actor. It wraps
+// ErrMigrated and names the successor. If Forwarded is set the request
+// has been forwarded to the successor and is executed there. Otherwise
+// it has been rejected and can be sent again. To stay behind the
+// forwarded requests it has to be sent to the Actor returned by
+// Successor of the migrated one, which waits until they are forwarded.
+type MigratedError struct {
+	Successor *Actor
+	Forwarded bool
+}
+
+// Error implements the error interface.
+func (e *MigratedError) Error() string {
+	if e.Forwarded {
+		return ErrMigrated.Error() + ": request forwarded to successor"
+	}
+	return ErrMigrated.Error()
+}
+
+// Unwrap returns ErrMigrated.
+func (e *MigratedError) Unwrap() error {
+	return ErrMigrated
+}
+
+//--------------------
+// HANDOVER
+//--------------------
+
+// HandoverTo stops the Actor and re-submits its pending requests in
+// their order to the next Actor, e.g. a freshly configured one during
+// a configuration reload. An Action currently executed is finished
+// first. New requests are rejected as soon as the handover starts.
+//
+// Asynchronous Actions simply continue on the next Actor. Synchronous
+// callers are still waiting on the stopped Actor, so they receive its
+// context error even though their Action will be executed by the next
+// one. If this matters they have to retry or to be stopped before.
+func (act *Actor) HandoverTo(next *Actor) error {
+	if next == nil || next == act {
+		return fmt.Errorf("%w: invalid handover target", ErrInvalid)
+	}
+	act.handingOver.Store(true)
+	act.stopping.Store(true)
+	act.Stop()
+	<-act.Done()
+	return act.forwardPending(next, "handover")
+}
+
+// migration describes the migration of an Actor to its successor.
+type migration struct {
+	successor *Actor
+	forwarded chan struct{}
+}
+
+// MigrateTo moves the responsibility of the Actor to the successor
+// without losing or reordering queued work. First the Actor executes
+// its queued Actions normally. Then it executes the transfer on the
+// successor while its own backend waits, so both states are pinned,
+// e.g. to move the state from one to the other. Afterwards it stops
+// and forwards the requests arrived during the migration in their
+// order to the successor. Further calls return a MigratedError,
+// Successor tells where to send them. If the transfer fails the Actor
+// continues and the error is returned. Forwarded Actions are executed
+// by the successor, so they have to look up the state they work on
+// instead of binding the one of the Actor. Synchronous callers of
+// forwarded requests receive a MigratedError with Forwarded set.
+func (act *Actor) MigrateTo(successor *Actor, transfer Action) error {
+	if successor == nil || successor == act {
+		return fmt.Errorf("%w: invalid migration target", ErrInvalid)
+	}
+	if err := act.alive(); err != nil {
+		return err
+	}
+	m := &migration{
+		successor: successor,
+		forwarded: make(chan struct{}),
+	}
+	var migrated bool
+	var terr error
+	req := newActionRequest(context.Background(), func() {
+		if transfer != nil {
+			if terr = successor.DoSync(transfer); terr != nil {
+				return
+			}
+		}
+		migrated = true
+		act.migration.Store(m)
+		act.handingOver.Store(true)
+		act.stopping.Store(true)
+		act.cancel()
+	})
+	req.marker = true
+	if err := act.send(req); err != nil {
+		return err
+	}
+	<-req.done
+	switch {
+	case terr != nil:
+		return fmt.Errorf("migration transfer: %w", terr)
+	case !migrated && req.err != nil:
+		return req.err
+	case !migrated:
+		return ErrDone
+	}
+	defer close(m.forwarded)
+	<-act.Done()
+	return act.forwardPending(successor, "migration")
+}
+
+// Successor returns the Actor the responsibility has been migrated
+// to with MigrateTo or nil. During the migration it waits until the
+// pending requests are forwarded, so that requests sent to the
+// successor afterwards are queued behind them.
+func (act *Actor) Successor() *Actor {
+	m := act.migration.Load()
+	if m == nil {
+		return nil
+	}
+	<-m.forwarded
+	return m.successor
+}
+
+// forwardPending sends the pending requests of the stopped Actor
+// to the next one.
+func (act *Actor) forwardPending(next *Actor, what string) error {
+	// Sends admitted before the stop either enqueue
+	// or fail due to it.
+	for act.sending.Load() > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	var ferr error
+	for _, req := range act.drainPending() {
+		if err := next.send(req); err != nil && ferr == nil {
+			ferr = fmt.Errorf("%s: %w", what, err)
+		}
+	}
+	return ferr
+}
+
+// drainPending extracts the requests not yet executed by the stopped
+// backend in their order. It must only be called by the terminating
+// backend or after the Actor is done.
+func (act *Actor) drainPending() []*request {
+	reqs := append(act.events, act.pending...)
+	act.events = nil
+	act.pending = nil
+	act.pendingLen.Store(0)
+	if act.eventTimer != nil {
+		act.eventTimer.Stop()
+	}
+	for _, req := range reqs {
+		act.leaveQueue(req)
+	}
+	for {
+		select {
+		case req := <-act.requests:
+			act.leaveQueue(req)
+			reqs = append(reqs, req)
+		default:
+			return reqs
+		}
+	}
+}
+
+// EOF
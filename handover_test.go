@@ -0,0 +1,179 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestHandoverTo verifies that queued Actions are executed in order
+// by the next Actor after a handover.
+func TestHandoverTo(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	old, err := actor.Go()
+	assert.OK(err)
+	next, err := actor.Go()
+	assert.OK(err)
+	defer next.Stop()
+
+	// Block the old Actor and queue work behind it.
+	release := make(chan struct{})
+	assert.OK(old.DoAsync(func() {
+		<-release
+	}))
+	executed := []int{}
+	for i := 0; i < 5; i++ {
+		i := i
+		assert.OK(old.DoAsync(func() {
+			executed = append(executed, i)
+		}))
+	}
+
+	handedOver := make(chan error)
+	go func() {
+		handedOver <- old.HandoverTo(next)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	assert.OK(<-handedOver)
+
+	assert.True(old.IsDone())
+	assert.ErrorMatch(old.DoAsync(func() {}), "actor is done")
+	assert.OK(next.DoSync(func() {
+		assert.Equal(executed, []int{0, 1, 2, 3, 4})
+	}))
+
+	err = next.HandoverTo(nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestMigrateTo verifies that concurrent writes during a migration are
+// processed exactly once and in order across both Actors.
+func TestMigrateTo(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	source, err := actor.Go()
+	assert.OK(err)
+	successor, err := actor.Go()
+	assert.OK(err)
+	defer successor.Stop()
+
+	const writers = 4
+	const writes = 500
+	srcLog := []int{}
+	dstLog := []int{}
+	// Forwarded Actions are executed by the successor,
+	// so they look up the active state.
+	active := &srcLog
+
+	var wg sync.WaitGroup
+	wg.Add(writers)
+	for w := 0; w < writers; w++ {
+		go func(w int) {
+			defer wg.Done()
+			act := source
+			for i := 0; i < writes; i++ {
+				value := w*writes + i
+				for {
+					err := act.DoAsync(func() {
+						*active = append(*active, value)
+					})
+					if errors.Is(err, actor.ErrMigrated) {
+						act = act.Successor()
+						continue
+					}
+					assert.OK(err)
+					break
+				}
+			}
+		}(w)
+	}
+
+	time.Sleep(time.Millisecond)
+	assert.OK(source.MigrateTo(successor, func() {
+		// Executed by the successor while the source waits.
+		dstLog = append(dstLog, srcLog...)
+		srcLog = nil
+		active = &dstLog
+	}))
+	wg.Wait()
+
+	assert.True(errors.Is(source.DoAsync(func() {}), actor.ErrMigrated))
+	assert.Equal(source.Successor(), successor)
+	assert.Nil(successor.Successor())
+	assert.OK(successor.DoSync(func() {
+		assert.Length(dstLog, writers*writes)
+		last := make([]int, writers)
+		for w := range last {
+			last[w] = -1
+		}
+		for _, value := range dstLog {
+			w, i := value/writes, value%writes
+			assert.Equal(i, last[w]+1)
+			last[w] = i
+		}
+	}))
+}
+
+// TestMigrateToForwardedSync verifies that a synchronous caller of
+// a request forwarded during the migration receives ErrMigrated.
+func TestMigrateToForwardedSync(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	source, err := actor.Go()
+	assert.OK(err)
+	successor, err := actor.Go()
+	assert.OK(err)
+	defer successor.Stop()
+
+	inTransfer := make(chan struct{})
+	queued := make(chan struct{})
+	migrated := make(chan error, 1)
+	go func() {
+		migrated <- source.MigrateTo(successor, func() {
+			close(inTransfer)
+			<-queued
+		})
+	}()
+
+	// Queue a synchronous request while the source waits for the transfer.
+	<-inTransfer
+	executed := make(chan bool, 1)
+	result := make(chan error, 1)
+	go func() {
+		result <- source.DoSync(func() {
+			executed <- true
+		})
+	}()
+	assert.Retry(func() bool { return source.Metrics().Queued > 0 }, 100, time.Millisecond)
+	close(queued)
+	assert.OK(<-migrated)
+
+	err = <-result
+	assert.True(errors.Is(err, actor.ErrMigrated))
+	var merr *actor.MigratedError
+	assert.True(errors.As(err, &merr))
+	assert.True(merr.Forwarded)
+	assert.Equal(merr.Successor, successor)
+	assert.True(<-executed)
+}
+
+// EOF
@@ -0,0 +1,111 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+//--------------------
+// HYBRID LOGICAL CLOCK
+//--------------------
+
+// HLCTimestamp is a hybrid logical timestamp combining the wall clock
+// in nanoseconds with a logical counter. The timestamps of one Actor
+// increase strictly monotonic, even if its wall clock steps back.
+type HLCTimestamp struct {
+	Wall    int64
+	Logical uint32
+}
+
+// Before returns true if the timestamp is before the other one.
+func (ts HLCTimestamp) Before(other HLCTimestamp) bool {
+	if ts.Wall != other.Wall {
+		return ts.Wall < other.Wall
+	}
+	return ts.Logical < other.Logical
+}
+
+// IsZero returns true if the timestamp is not set.
+func (ts HLCTimestamp) IsZero() bool {
+	return ts.Wall == 0 && ts.Logical == 0
+}
+
+// String implements the fmt.Stringer interface.
+func (ts HLCTimestamp) String() string {
+	return fmt.Sprintf("%d.%d", ts.Wall, ts.Logical)
+}
+
+// timestampKey is the context key of the HLCTimestamp.
+type timestampKey struct{}
+
+// Timestamp returns the HLCTimestamp the Actor stamped the executed
+// Action with. It is found in the context passed to ContextActions
+// and to the interceptors if SetHLC is enabled.
+func Timestamp(ctx context.Context) (HLCTimestamp, bool) {
+	ts, ok := ctx.Value(timestampKey{}).(HLCTimestamp)
+	return ts, ok
+}
+
+// hlc generates the hybrid logical timestamps inside the backend.
+type hlc struct {
+	now  func() time.Time
+	last HLCTimestamp
+}
+
+// SetHLC enables or disables the stamping of each executed Action
+// except reads with a hybrid logical timestamp.
+func (act *Actor) SetHLC(enabled bool) {
+	act.hlcEnabled.Store(enabled)
+}
+
+// LastTimestamp returns the HLCTimestamp of the latest stamped Action.
+func (act *Actor) LastTimestamp() HLCTimestamp {
+	if ts := act.lastTimestamp.Load(); ts != nil {
+		return *ts
+	}
+	return HLCTimestamp{}
+}
+
+// stamp adds the next HLCTimestamp to the context if enabled.
+func (act *Actor) stamp(ctx context.Context, req *request) context.Context {
+	if req.read || !act.hlcEnabled.Load() {
+		return ctx
+	}
+	ts := act.hlc.next()
+	act.lastTimestamp.Store(&ts)
+	return context.WithValue(ctx, timestampKey{}, ts)
+}
+
+// next returns the next timestamp. If the wall clock did not advance
+// or stepped back the logical counter is incremented instead.
+func (c *hlc) next() HLCTimestamp {
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	wall := now().UnixNano()
+	switch {
+	case wall > c.last.Wall:
+		c.last = HLCTimestamp{Wall: wall}
+	case c.last.Logical == math.MaxUint32:
+		c.last = HLCTimestamp{Wall: c.last.Wall + 1}
+	default:
+		c.last.Logical++
+	}
+	return c.last
+}
+
+// EOF
@@ -0,0 +1,111 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestHLC verifies that the hybrid logical timestamps stay monotonic
+// when the wall clock stands still or steps back.
+func TestHLC(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var mu sync.Mutex
+	wall := time.Unix(1000, 0)
+	setWall := func(t time.Time) {
+		mu.Lock()
+		defer mu.Unlock()
+		wall = t
+	}
+	act, err := actor.Go(actor.WithHLCClock(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return wall
+	}))
+	assert.OK(err)
+	defer act.Stop()
+
+	stamped := func() actor.HLCTimestamp {
+		var ts actor.HLCTimestamp
+		var ok bool
+		assert.OK(act.DoSyncCtx(context.Background(), func(ctx context.Context) {
+			ts, ok = actor.Timestamp(ctx)
+		}))
+		assert.True(ok)
+		return ts
+	}
+
+	// Disabled by default.
+	assert.OK(act.DoSyncCtx(context.Background(), func(ctx context.Context) {
+		_, ok := actor.Timestamp(ctx)
+		assert.False(ok)
+	}))
+	act.SetHLC(true)
+
+	first := stamped()
+	assert.Equal(first, actor.HLCTimestamp{Wall: wall.UnixNano()})
+	second := stamped()
+	assert.Equal(second, actor.HLCTimestamp{Wall: wall.UnixNano(), Logical: 1})
+
+	// The clock steps back, the timestamps still increase.
+	setWall(time.Unix(500, 0))
+	third := stamped()
+	assert.True(second.Before(third))
+	assert.Equal(third, actor.HLCTimestamp{Wall: time.Unix(1000, 0).UnixNano(), Logical: 2})
+	assert.Equal(act.LastTimestamp(), third)
+
+	// Reads are not stamped.
+	assert.OK(act.DoRead(context.Background(), func() {}))
+	assert.Equal(act.LastTimestamp(), third)
+
+	// The clock advances again.
+	setWall(time.Unix(2000, 0))
+	fourth := stamped()
+	assert.Equal(fourth, actor.HLCTimestamp{Wall: time.Unix(2000, 0).UnixNano()})
+	assert.True(third.Before(fourth))
+}
+
+// TestHLCOutbox verifies that the timestamp is available for the
+// intents of an Outbox.
+func TestHLCOutbox(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	published := make(chan any, 1)
+	act, err := actor.Go(
+		actor.WithHLC(),
+		actor.WithOutbox(func(seq uint64, payload any) error {
+			published <- payload
+			return nil
+		}, time.Millisecond),
+	)
+	assert.OK(err)
+	defer act.Stop()
+
+	assert.OK(act.DoOutbox(func(out *actor.Outbox) {
+		out.Add(out.Timestamp())
+	}))
+	ts := (<-published).(actor.HLCTimestamp)
+	assert.False(ts.IsZero())
+	assert.Equal(ts, act.LastTimestamp())
+}
+
+// EOF
@@ -0,0 +1,117 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"time"
+)
+
+//--------------------
+// IDEMPOTENCY
+//--------------------
+
+// applied is the result of an Action applied with an idempotency key.
+type applied struct {
+	err     error
+	expires time.Time
+}
+
+// idempotency records the applied keys inside the backend.
+type idempotency struct {
+	keys       map[string]applied
+	nextExpiry time.Time
+	once       map[string]struct{}
+}
+
+// DoIdempotent executes the action synchronously only if no Action
+// with the same key has been applied during the last ttl. Otherwise
+// the recorded result of the former one is returned without executing
+// the action again. So duplicates of commands delivered at least once
+// are applied only once. The keys are checked inside the backend.
+func (act *Actor) DoIdempotent(key string, ttl time.Duration, action func() error) error {
+	if key == "" || ttl <= 0 || action == nil {
+		return fmt.Errorf("%w: idempotency key, ttl, or action", ErrInvalid)
+	}
+	var aerr error
+	if err := act.DoSync(func() {
+		now := time.Now()
+		act.idempotency.expire(now)
+		if a, ok := act.idempotency.keys[key]; ok {
+			aerr = a.err
+			return
+		}
+		aerr = action()
+		act.idempotency.record(key, applied{aerr, now.Add(ttl)})
+	}); err != nil {
+		return err
+	}
+	return aerr
+}
+
+// DoOnce executes the action synchronously only if no Action with
+// the same key has been executed by DoOnce before during the lifetime
+// of the Actor, e.g. for an initialization triggered from multiple
+// code paths. It returns if the action ran and its error. A failed
+// action counts as ran too.
+func (act *Actor) DoOnce(key string, action func() error) (bool, error) {
+	if key == "" || action == nil {
+		return false, fmt.Errorf("%w: once key or action", ErrInvalid)
+	}
+	var ran bool
+	var aerr error
+	if err := act.DoSync(func() {
+		if _, ok := act.idempotency.once[key]; ok {
+			return
+		}
+		if act.idempotency.once == nil {
+			act.idempotency.once = make(map[string]struct{})
+		}
+		act.idempotency.once[key] = struct{}{}
+		ran = true
+		aerr = action()
+	}); err != nil {
+		return false, err
+	}
+	return ran, aerr
+}
+
+// record records the result of an applied key.
+func (idem *idempotency) record(key string, a applied) {
+	if idem.keys == nil {
+		idem.keys = make(map[string]applied)
+	}
+	idem.keys[key] = a
+	if idem.nextExpiry.IsZero() || a.expires.Before(idem.nextExpiry) {
+		idem.nextExpiry = a.expires
+	}
+}
+
+// expire removes the expired keys. The keys are only scanned if the
+// earliest expiry passed.
+func (idem *idempotency) expire(now time.Time) {
+	if idem.nextExpiry.IsZero() || now.Before(idem.nextExpiry) {
+		return
+	}
+	idem.nextExpiry = time.Time{}
+	for key, a := range idem.keys {
+		if !now.Before(a.expires) {
+			delete(idem.keys, key)
+			continue
+		}
+		if idem.nextExpiry.IsZero() || a.expires.Before(idem.nextExpiry) {
+			idem.nextExpiry = a.expires
+		}
+	}
+}
+
+// EOF
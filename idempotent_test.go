@@ -0,0 +1,109 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDoIdempotent verifies that an Action with a known key is applied
+// only once during the ttl and its result is returned again.
+func TestDoIdempotent(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	balance := 0
+	deposit := func() error {
+		balance += 10
+		return nil
+	}
+	assert.OK(act.DoIdempotent("msg-1", time.Hour, deposit))
+	assert.OK(act.DoIdempotent("msg-1", time.Hour, deposit))
+	assert.OK(act.DoIdempotent("msg-2", time.Hour, deposit))
+	assert.Equal(balance, 20)
+
+	// Errors are recorded too.
+	errDenied := errors.New("denied")
+	denied := 0
+	deny := func() error {
+		denied++
+		return errDenied
+	}
+	assert.True(errors.Is(act.DoIdempotent("msg-3", time.Hour, deny), errDenied))
+	assert.True(errors.Is(act.DoIdempotent("msg-3", time.Hour, deny), errDenied))
+	assert.Equal(denied, 1)
+
+	// Expired keys are applied again.
+	assert.OK(act.DoIdempotent("msg-4", 10*time.Millisecond, deposit))
+	time.Sleep(20 * time.Millisecond)
+	assert.OK(act.DoIdempotent("msg-4", 10*time.Millisecond, deposit))
+	assert.Equal(balance, 40)
+
+	assert.ErrorMatch(act.DoIdempotent("", time.Hour, deposit), ".*invalid argument.*")
+}
+
+// TestDoOnce verifies that concurrent calls with the same key execute
+// the action exactly once.
+func TestDoOnce(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	initialized := 0
+	var ran atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := act.DoOnce("init", func() error {
+				initialized++
+				return nil
+			})
+			assert.OK(err)
+			if ok {
+				ran.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(ran.Load(), int64(1))
+	assert.OK(act.DoSync(func() {
+		assert.Equal(initialized, 1)
+	}))
+
+	// Failed actions count as ran.
+	errInit := errors.New("init failed")
+	ok, err := act.DoOnce("other", func() error { return errInit })
+	assert.True(ok)
+	assert.True(errors.Is(err, errInit))
+	ok, err = act.DoOnce("other", func() error { return nil })
+	assert.False(ok)
+	assert.OK(err)
+}
+
+// EOF
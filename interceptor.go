@@ -0,0 +1,59 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"time"
+)
+
+//--------------------
+// INTERCEPTOR
+//--------------------
+
+// RequestMeta contains the metadata of a request passed to the
+// interceptors before its Action is executed.
+type RequestMeta struct {
+	// Context is the context of the request. Interceptors may replace
+	// it with a derived one, e.g. carrying a request-scoped logger.
+	// Actions sent via DoSyncCtx or DoAsyncCtx receive the final one.
+	Context context.Context
+
+	// Submitted is the time the request has been submitted.
+	Submitted time.Time
+
+	// Sequence is the apply sequence the Actor stamped the request
+	// with. It increases monotonically in the order of execution. An
+	// Action skipped by an interceptor leaves a gap.
+	Sequence uint64
+
+	// WatchdogExempt tells if the Action is exempted from the
+	// watchdog, as decided when it has been dequeued.
+	WatchdogExempt bool
+
+	origin any
+}
+
+// ActionName returns the runtime name of the Action function.
+func (meta *RequestMeta) ActionName() string {
+	return funcName(meta.origin)
+}
+
+// Interceptor defines the signature of a function called in the
+// backend before each Action in the order of their configuration.
+// Returning an error skips the Action, a synchronous caller will
+// receive the error. As interceptors run in the backend goroutine
+// they may read and change the state guarded by the Actor like
+// Actions, serialized with them.
+type Interceptor func(meta *RequestMeta) error
+
+// EOF
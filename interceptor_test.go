@@ -0,0 +1,130 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// loggerKey is the context key used by the tests.
+type loggerKey struct{}
+
+// TestInterceptorContext verifies that interceptors can augment the
+// request context read by the Action.
+func TestInterceptorContext(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	first := func(meta *actor.RequestMeta) error {
+		meta.Context = context.WithValue(meta.Context, loggerKey{}, "first")
+		return nil
+	}
+	second := func(meta *actor.RequestMeta) error {
+		value := meta.Context.Value(loggerKey{}).(string)
+		meta.Context = context.WithValue(meta.Context, loggerKey{}, value+"+second")
+		return nil
+	}
+	act, err := actor.Go(actor.WithInterceptors(first, second))
+	assert.OK(err)
+	defer act.Stop()
+
+	var logger any
+	assert.OK(act.DoSyncCtx(context.Background(), func(ctx context.Context) {
+		logger = ctx.Value(loggerKey{})
+	}))
+	assert.Equal(logger, "first+second")
+
+	// Plain Actions are intercepted too.
+	called := false
+	assert.OK(act.DoSync(func() {
+		called = true
+	}))
+	assert.True(called)
+}
+
+// TestInterceptorError verifies that an interceptor error skips the Action.
+func TestInterceptorError(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	denied := errors.New("denied")
+	deny := func(meta *actor.RequestMeta) error {
+		if meta.Context.Value(loggerKey{}) == nil {
+			return denied
+		}
+		return nil
+	}
+	act, err := actor.Go(actor.WithInterceptors(deny))
+	assert.OK(err)
+	defer act.Stop()
+
+	called := false
+	err = act.DoSyncCtx(context.Background(), func(ctx context.Context) {
+		called = true
+	})
+	assert.True(errors.Is(err, denied))
+	assert.False(called)
+
+	ctx := context.WithValue(context.Background(), loggerKey{}, "ok")
+	assert.OK(act.DoSyncCtx(ctx, func(ctx context.Context) {
+		called = true
+	}))
+	assert.True(called)
+}
+
+// TestInterceptorState verifies that interceptors may change the
+// state guarded by the Actor serialized with the Actions.
+func TestInterceptorState(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	intercepted := 0
+	act, err := actor.Go(actor.WithInterceptors(func(meta *actor.RequestMeta) error {
+		intercepted++
+		return nil
+	}))
+	assert.OK(err)
+	defer act.Stop()
+
+	executed := 0
+	consistent := true
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				assert.OK(act.DoAsync(func() {
+					// The interceptor ran directly before.
+					executed++
+					if intercepted != executed {
+						consistent = false
+					}
+				}))
+			}
+		}()
+	}
+	wg.Wait()
+	var seen int
+	assert.OK(act.DoSync(func() {
+		seen = intercepted
+	}))
+	assert.Equal(seen, 1001)
+	assert.True(consistent)
+}
+
+// EOF
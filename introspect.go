@@ -0,0 +1,88 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+//--------------------
+// QUEUE INTROSPECTION
+//--------------------
+
+// QueuedActionInfo describes an Action waiting in the queue. The apply
+// sequence is assigned only when the execution starts, so it is not
+// part of it.
+type QueuedActionInfo struct {
+	// Name is the runtime name of the Action function.
+	Name string
+
+	// Submitted is the time the Action has been submitted.
+	Submitted time.Time
+}
+
+// queueIndex mirrors the queued requests, as a channel cannot be
+// read without consuming it.
+type queueIndex struct {
+	mu       sync.Mutex
+	requests map[*request]struct{}
+}
+
+// add registers a request about to be queued.
+func (qi *queueIndex) add(req *request) {
+	if qi == nil {
+		return
+	}
+	qi.mu.Lock()
+	defer qi.mu.Unlock()
+	qi.requests[req] = struct{}{}
+}
+
+// remove unregisters a request taken from the queue or
+// failed to be queued.
+func (qi *queueIndex) remove(req *request) {
+	if qi == nil {
+		return
+	}
+	qi.mu.Lock()
+	defer qi.mu.Unlock()
+	delete(qi.requests, req)
+}
+
+// DumpQueue returns information about the Actions waiting in the queue
+// in the order of their submission, e.g. to see what a hanging Actor
+// was about to do. It is read-only and best-effort, the queue may
+// change concurrently. It needs the option WithQueueIntrospection,
+// otherwise it returns nil.
+func (act *Actor) DumpQueue() []QueuedActionInfo {
+	qi := act.queueIndex
+	if qi == nil {
+		return nil
+	}
+	qi.mu.Lock()
+	infos := make([]QueuedActionInfo, 0, len(qi.requests))
+	for req := range qi.requests {
+		infos = append(infos, QueuedActionInfo{
+			Name:      funcName(req.origin),
+			Submitted: req.submitted,
+		})
+	}
+	qi.mu.Unlock()
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].Submitted.Before(infos[j].Submitted)
+	})
+	return infos
+}
+
+// EOF
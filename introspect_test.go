@@ -0,0 +1,77 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDumpQueue verifies dumping the queued Actions without
+// consuming them.
+func TestDumpQueue(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithQueueIntrospection())
+	assert.OK(err)
+	defer act.Stop()
+
+	// Block the Actor and queue named Actions.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	assert.OK(act.DoAsync(chargeFee))
+	assert.OK(act.DoAsync(sendInvoice))
+	assert.OK(act.DoAsync(chargeFee))
+
+	dump := act.DumpQueue()
+	assert.Length(dump, 3)
+	assert.Match(dump[0].Name, ".*chargeFee$")
+	assert.Match(dump[1].Name, ".*sendInvoice$")
+	assert.Match(dump[2].Name, ".*chargeFee$")
+	assert.False(dump[0].Submitted.Before(start))
+
+	// Dumping did not consume them.
+	assert.Length(act.DumpQueue(), 3)
+	close(release)
+	assert.OK(act.DoSync(func() {}))
+	assert.Length(act.DumpQueue(), 0)
+
+	// Without the option there's nothing to dump.
+	plain, err := actor.Go()
+	assert.OK(err)
+	defer plain.Stop()
+	assert.OK(plain.DoAsync(chargeFee))
+	assert.Nil(plain.DumpQueue())
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// chargeFee is a named Action for the queue dump.
+func chargeFee() {}
+
+// sendInvoice is a named Action for the queue dump.
+func sendInvoice() {}
+
+// EOF
@@ -0,0 +1,47 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"runtime/pprof"
+	"sort"
+)
+
+//--------------------
+// GOROUTINE LABELS
+//--------------------
+
+// labelSet converts the labels into pprof label pairs sorted by key.
+func labelSet(labels map[string]string) pprof.LabelSet {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, 0, 2*len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key, labels[key])
+	}
+	return pprof.Labels(pairs...)
+}
+
+// applyLabels sets the goroutine labels of the Actor for the calling
+// goroutine, if any are configured.
+func (act *Actor) applyLabels() {
+	if act.labels == nil {
+		return
+	}
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), *act.labels))
+}
+
+// EOF
@@ -0,0 +1,63 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"strings"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestGoroutineLabels verifies that the goroutine labels of an Actor
+// are visible in the goroutine profile.
+func TestGoroutineLabels(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithGoroutineLabels(map[string]string{
+		"actor": "labeled-account",
+		"tier":  "gold",
+	}))
+	assert.OK(err)
+	defer func() {
+		// Let the live Actors settle for the following tests.
+		act.Stop()
+		<-act.Done()
+	}()
+	stop, err := act.Repeat(time.Hour, func() {})
+	assert.OK(err)
+	defer stop()
+
+	// Profile while the backend is inside an Action. The backend and
+	// the Repeater goroutine are labeled.
+	labels := `# labels: {"actor":"labeled-account", "tier":"gold"}`
+	assert.Retry(func() bool {
+		var profile bytes.Buffer
+		assert.OK(act.DoSync(func() {
+			assert.OK(pprof.Lookup("goroutine").WriteTo(&profile, 1))
+		}))
+		return strings.Count(profile.String(), labels) == 2
+	}, 100, time.Millisecond)
+
+	_, err = actor.Go(actor.WithGoroutineLabels(nil))
+	assert.ErrorMatch(err, ".*invalid argument.*")
+}
+
+// EOF
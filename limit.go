@@ -0,0 +1,76 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrTooManyActors is returned by Go if starting the Actor would
+// exceed the maximum number of live Actors.
+var ErrTooManyActors = errors.New("too many actors")
+
+//--------------------
+// LIMIT
+//--------------------
+
+var (
+	// liveActors counts the Actors started and not yet done.
+	liveActors atomic.Int64
+
+	// maxActors is the maximum number of live Actors. Zero
+	// or less means unlimited.
+	maxActors atomic.Int64
+)
+
+// SetMaxActors sets the maximum number of live Actors in the process.
+// If it is reached Go returns ErrTooManyActors. A value of zero or
+// less removes the limit, which is the default. Already running
+// Actors are not affected.
+func SetMaxActors(n int) {
+	maxActors.Store(int64(n))
+}
+
+// LiveActors returns the number of Actors started and not yet done.
+func LiveActors() int {
+	return int(liveActors.Load())
+}
+
+// acquireActor registers a new live Actor if the limit allows it.
+func acquireActor() error {
+	live := liveActors.Add(1)
+	if limit := maxActors.Load(); limit > 0 && live > limit {
+		liveActors.Add(-1)
+		return ErrTooManyActors
+	}
+	return nil
+}
+
+// releaseActor unregisters a live Actor.
+func releaseActor() {
+	liveActors.Add(-1)
+}
+
+// release unregisters the Actor as live one only once, as it is done
+// by the terminating backend as well as by a failing start.
+func (act *Actor) release() {
+	if act.released.CompareAndSwap(false, true) {
+		releaseActor()
+	}
+}
+
+// EOF
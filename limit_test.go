@@ -0,0 +1,62 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestMaxActors verifies the limitation of live Actors.
+func TestMaxActors(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	live := actor.LiveActors()
+	actor.SetMaxActors(live + 3)
+	defer actor.SetMaxActors(0)
+
+	// Start Actors up to the limit.
+	acts := []*actor.Actor{}
+	for i := 0; i < 3; i++ {
+		act, err := actor.Go()
+		assert.OK(err)
+		acts = append(acts, act)
+	}
+	assert.Equal(actor.LiveActors(), live+3)
+
+	act, err := actor.Go()
+	assert.ErrorMatch(err, "too many actors")
+	assert.Nil(act)
+
+	// Free one and try again.
+	acts[0].Stop()
+	<-acts[0].Done()
+	assert.Equal(actor.LiveActors(), live+2)
+
+	act, err = actor.Go()
+	assert.OK(err)
+	acts[0] = act
+
+	for _, act := range acts {
+		act.Stop()
+		<-act.Done()
+	}
+	assert.Equal(actor.LiveActors(), live)
+}
+
+// EOF
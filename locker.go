@@ -0,0 +1,97 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrLockTimeout is the panic reason passed to the Recoverer if a lock
+// taken via the Locker is held longer than the configured timeout.
+var ErrLockTimeout = errors.New("actor locker held too long")
+
+//--------------------
+// LOCKER
+//--------------------
+
+// actorLocker implements sync.Locker by parking the backend.
+type actorLocker struct {
+	act *Actor
+}
+
+// Locker returns a sync.Locker for migrating mutex protected code to
+// the Actor step by step. Lock parks the backend with a special Action
+// and returns when it is reached, so the caller has exclusive access
+// to the state otherwise only touched by Actions. Unlock releases the
+// backend again. All Lockers of an Actor are mutually exclusive, also
+// after the Actor is done.
+//
+// This is a deliberately ugly escape hatch. While locked the Actor
+// executes nothing, and a forgotten Unlock blocks it forever. So it
+// should only be used during a migration, ideally together with
+// WithLockerTimeout. Never call Lock from inside an Action, it
+// deadlocks.
+func (act *Actor) Locker() sync.Locker {
+	return &actorLocker{act: act}
+}
+
+// Lock implements sync.Locker. If the lock is held longer than the
+// timeout set with WithLockerTimeout the Actor stops with
+// ErrLockTimeout, even if the Recoverer returns nil.
+func (l *actorLocker) Lock() {
+	act := l.act
+	act.lockMu.Lock()
+	granted := make(chan struct{})
+	release := make(chan struct{})
+	err := act.DoAsync(func() {
+		close(granted)
+		var timeout <-chan time.Time
+		if act.lockTimeout > 0 {
+			timer := time.NewTimer(act.lockTimeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+		select {
+		case <-release:
+		case <-act.ctx.Done():
+		case <-timeout:
+			panic(ErrLockTimeout)
+		}
+	})
+	if err == nil {
+		// Wait until parked or done without having reached it.
+		select {
+		case <-granted:
+		case <-act.Done():
+		}
+	}
+	act.lockRelease = release
+}
+
+// Unlock implements sync.Locker.
+func (l *actorLocker) Unlock() {
+	act := l.act
+	if act.lockRelease == nil {
+		panic("actor: unlock of unlocked locker")
+	}
+	close(act.lockRelease)
+	act.lockRelease = nil
+	act.lockMu.Unlock()
+}
+
+// EOF
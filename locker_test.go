@@ -0,0 +1,115 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestLocker verifies that legacy code using the Locker and Actions
+// access the same state exclusively.
+func TestLocker(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	counter := 0
+	locker := act.Locker()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			locker.Lock()
+			counter++
+			locker.Unlock()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.OK(act.DoAsync(func() {
+				counter++
+			}))
+		}
+	}()
+	wg.Wait()
+
+	assert.OK(act.DoSync(func() {}))
+	locker.Lock()
+	assert.Equal(counter, 200)
+	locker.Unlock()
+}
+
+// TestLockerTimeout verifies the detection of a forgotten Unlock.
+func TestLockerTimeout(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithLockerTimeout(50 * time.Millisecond))
+	assert.OK(err)
+
+	locker := act.Locker()
+	locker.Lock()
+
+	select {
+	case <-act.Done():
+	case <-time.After(time.Second):
+		assert.Fail("forgotten unlock not detected")
+	}
+	assert.True(errors.Is(act.Err(), actor.ErrLockTimeout))
+
+	// Locker still works as mutex after the Actor is done.
+	locker.Unlock()
+	locker.Lock()
+	locker.Unlock()
+}
+
+// TestLockerTimeoutRecoverer verifies that the Actor stops on a lock
+// timeout even if the Recoverer wants to continue.
+func TestLockerTimeoutRecoverer(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	recovered := make(chan any, 1)
+	act, err := actor.Go(
+		actor.WithLockerTimeout(50*time.Millisecond),
+		actor.WithRecoverer(func(reason any) error {
+			recovered <- reason
+			return nil
+		}),
+	)
+	assert.OK(err)
+
+	locker := act.Locker()
+	locker.Lock()
+
+	select {
+	case <-act.Done():
+	case <-time.After(time.Second):
+		assert.Fail("lock timeout did not stop the Actor")
+	}
+	assert.True(<-recovered == actor.ErrLockTimeout)
+	assert.True(errors.Is(act.Err(), actor.ErrLockTimeout))
+	locker.Unlock()
+}
+
+// EOF
@@ -75,6 +75,26 @@ func TestPerformance(t *testing.T) {
 	assert.NoError(act.Err())
 }
 
+// BenchmarkSubmitAfterStop measures the rejection of calls
+// to a stopped Actor.
+func BenchmarkSubmitAfterStop(b *testing.B) {
+	act, err := actor.Go()
+	if err != nil {
+		b.Fatal(err)
+	}
+	act.Stop()
+	<-act.Done()
+	action := func() {}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if act.DoAsync(action) != actor.ErrDone {
+			b.Fatal("unexpected error")
+		}
+	}
+}
+
 //--------------------
 // TEST ACTOR
 //--------------------
@@ -0,0 +1,158 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"time"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrTooManyPanics is the error of an Actor stopped because it
+// recovered more panics than allowed with WithMaxPanics.
+var ErrTooManyPanics = errors.New("too many panics")
+
+//--------------------
+// METRICS
+//--------------------
+
+// Metrics contains the counters of an Actor.
+type Metrics struct {
+	// Queued is the number of Actions waiting in the queue.
+	Queued int
+
+	// Capacity is the capacity of the queue, changing with
+	// SetAutoScaleQueue.
+	Capacity int
+
+	// Executed is the number of Actions started to execute.
+	Executed uint64
+
+	// Panicked is the number of recovered panics.
+	Panicked uint64
+
+	// Reordered is the number of Actions executed before an Action
+	// which arrived in the queue earlier. It is always zero for the
+	// default ordering, other orderings or event times reorder.
+	Reordered uint64
+
+	// Coalesced is the number of asynchronous Actions dropped as
+	// consecutive duplicates.
+	Coalesced uint64
+
+	// OccupancyRequestSeconds is the integral of the number of queued
+	// Actions over the time, e.g. two Actions queued for three seconds
+	// add six. It shows which Actors hold work the longest.
+	OccupancyRequestSeconds float64
+
+	// MaxResidency is the longest time an Action has been queued.
+	MaxResidency time.Duration
+
+	// Tracked is the number of live goroutines started with
+	// TrackedGo.
+	Tracked int64
+
+	// Classes contains the counters per priority class if set
+	// with SetPriorityClasses.
+	Classes map[PriorityClass]ClassMetrics
+}
+
+// Metrics returns the current counters of the Actor.
+func (act *Actor) Metrics() Metrics {
+	occupancy, residency := act.occupancy.requestSeconds()
+	var classes map[PriorityClass]ClassMetrics
+	if p := act.priority.Load(); p != nil {
+		classes = p.snapshot()
+	}
+	return Metrics{
+		Queued:                  act.queued(),
+		Capacity:                act.slots.capacity(),
+		Executed:                act.Sequence(),
+		Panicked:                act.panics.Load(),
+		Reordered:               act.reorders.Load(),
+		Coalesced:               act.coalesced.Load(),
+		OccupancyRequestSeconds: occupancy,
+		MaxResidency:            residency,
+		Tracked:                 act.tracked.live.Load(),
+		Classes:                 classes,
+	}
+}
+
+// queued returns the number of requests waiting in the queue and in
+// the pending ones the backend took from it, e.g. to prioritize them.
+// It may be called from any goroutine.
+func (act *Actor) queued() int {
+	return len(act.requests) + int(act.pendingLen.Load())
+}
+
+// Uptime returns the time the Actor has been running since it has
+// been started. After it is done the uptime is frozen at the time it
+// terminated.
+func (act *Actor) Uptime() time.Duration {
+	if stopped := act.stopped.Load(); stopped != nil {
+		return stopped.Sub(act.started)
+	}
+	return time.Since(act.started)
+}
+
+// PublishExpvar publishes the Metrics of the Actor as expvar variable
+// with the given name, so they are shown as JSON by the /debug/vars
+// endpoint. The Metrics are only retrieved when the variable is read.
+// Publishing an already used name returns an error.
+func PublishExpvar(name string, act *Actor) error {
+	if name == "" || act == nil {
+		return fmt.Errorf("%w: expvar name or actor", ErrInvalid)
+	}
+	if expvar.Get(name) != nil {
+		return fmt.Errorf("%w: expvar %q already published", ErrInvalid, name)
+	}
+	expvar.Publish(name, expvar.Func(func() any {
+		return act.Metrics()
+	}))
+	return nil
+}
+
+// arrive stamps a request taken from the queue with its arrival.
+func (act *Actor) arrive(req *request) {
+	act.arrivals++
+	req.arrival = act.arrivals
+	act.classify(req)
+}
+
+// checkReorder counts the request if an earlier arrived one has
+// already been executed.
+func (act *Actor) checkReorder(req *request) {
+	if req.marker || req.arrival == 0 {
+		return
+	}
+	if req.arrival < act.lastArrival {
+		act.reorders.Add(1)
+		return
+	}
+	act.lastArrival = req.arrival
+}
+
+// checkPanics returns ErrTooManyPanics if the recovered panics
+// reached the maximum.
+func (act *Actor) checkPanics(panics uint64, reason any) error {
+	if act.maxPanics == 0 || panics < act.maxPanics {
+		return nil
+	}
+	return fmt.Errorf("%w: %d, last %v", ErrTooManyPanics, panics, reason)
+}
+
+// EOF
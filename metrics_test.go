@@ -0,0 +1,168 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestMetrics verifies the counters of an Actor.
+func TestMetrics(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(
+		actor.WithQueueCap(1024),
+		actor.WithRecoverer(func(reason any) error {
+			return nil
+		}),
+	)
+	assert.OK(err)
+	defer act.Stop()
+
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	act.DoSync(func() {
+		panic("ouch")
+	})
+	assert.OK(act.DoSync(func() {}))
+
+	m := act.Metrics()
+	assert.Equal(m.Queued, 0)
+	assert.Equal(m.Capacity, 1024)
+	assert.Equal(m.Executed, uint64(7))
+	assert.Equal(m.Panicked, uint64(1))
+}
+
+// TestUptime verifies that the uptime increases while running
+// and freezes after the stop.
+func TestUptime(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	first := act.Uptime()
+	time.Sleep(10 * time.Millisecond)
+	second := act.Uptime()
+	assert.True(second >= first+10*time.Millisecond)
+
+	act.Stop()
+	<-act.Done()
+	frozen := act.Uptime()
+	assert.True(frozen >= second)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(act.Uptime(), frozen)
+}
+
+// TestMaxPanics verifies that an Actor stops after the maximum
+// number of recovered panics.
+func TestMaxPanics(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	recovered := 0
+	act, err := actor.Go(
+		actor.WithRecoverer(func(reason any) error {
+			recovered++
+			return nil
+		}),
+		actor.WithMaxPanics(3),
+	)
+	assert.OK(err)
+
+	for i := 0; i < 5; i++ {
+		act.DoAsync(func() {
+			panic("always")
+		})
+	}
+	<-act.Done()
+
+	assert.True(errors.Is(act.Err(), actor.ErrTooManyPanics))
+	assert.Equal(act.Metrics().Panicked, uint64(3))
+	assert.Equal(recovered, 3)
+	assert.True(errors.Is(act.DoSync(func() {}), actor.ErrTooManyPanics))
+
+	_, err = actor.Go(actor.WithMaxPanics(-1))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestReordered verifies the counting of Actions executed out of
+// their arrival order.
+func TestReordered(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+
+	// Default ordering never reorders, even with concurrent callers.
+	act, err := actor.Go()
+	assert.OK(err)
+	var wg sync.WaitGroup
+	wg.Add(5)
+	for p := 0; p < 5; p++ {
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				act.DoAsync(func() {})
+			}
+		}()
+	}
+	wg.Wait()
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(act.Metrics().Reordered, uint64(0))
+	act.Stop()
+
+	// Event times reorder.
+	act, err = actor.Go(actor.WithEventTime(10*time.Millisecond, actor.LateDrop))
+	assert.OK(err)
+	defer act.Stop()
+	base := time.Now()
+	for _, offset := range []time.Duration{3, 2, 1} {
+		assert.OK(act.DoEventTime(base.Add(offset*time.Millisecond), func() {}))
+	}
+	assert.Retry(func() bool { return act.Metrics().Executed == 3 }, 100, time.Millisecond)
+	assert.Equal(act.Metrics().Reordered, uint64(2))
+}
+
+// TestPublishExpvar verifies the publishing of the Metrics as
+// expvar variable.
+func TestPublishExpvar(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	// Unique name, as expvars cannot be unpublished.
+	name := fmt.Sprintf("actor-test-%d", time.Now().UnixNano())
+	assert.OK(actor.PublishExpvar(name, act))
+	assert.ErrorMatch(actor.PublishExpvar(name, act), ".*already published.*")
+
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	v := expvar.Get(name)
+	assert.NotNil(v)
+	var m actor.Metrics
+	assert.OK(json.Unmarshal([]byte(v.String()), &m))
+	assert.True(m.Executed >= 5)
+	assert.Equal(m.Capacity, act.Metrics().Capacity)
+}
+
+// EOF
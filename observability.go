@@ -0,0 +1,145 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//--------------------
+// OBSERVABILITY
+//--------------------
+
+// Observability contains the observability settings of an Actor. They
+// can be changed at runtime with SetObservability.
+type Observability struct {
+	// BlockingThreshold activates the blocking detection if positive.
+	BlockingThreshold time.Duration
+
+	// BlockingReporter receives the reports of the blocking detection.
+	// A nil reporter writes them to the standard logger.
+	BlockingReporter BlockingReporter
+
+	// HistorySize is the number of executed Actions kept for History.
+	// Zero switches the history off and releases its buffer.
+	HistorySize int
+}
+
+// HistoryEntry describes an executed Action kept in the history.
+type HistoryEntry struct {
+	Sequence uint64
+	Started  time.Time
+	Duration time.Duration
+}
+
+// history is the ring buffer of the executed Actions.
+type history struct {
+	mu      sync.Mutex
+	entries []HistoryEntry
+	next    int
+	filled  bool
+}
+
+// resize changes the size of the buffer keeping the latest entries.
+func (h *history) resize(size int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if size == len(h.entries) {
+		return
+	}
+	if size == 0 {
+		h.entries = nil
+		h.next = 0
+		h.filled = false
+		return
+	}
+	latest := h.latest()
+	if len(latest) > size {
+		latest = latest[len(latest)-size:]
+	}
+	h.entries = make([]HistoryEntry, size)
+	h.next = copy(h.entries, latest) % size
+	h.filled = len(latest) == size
+}
+
+// record adds an entry if the buffer exists.
+func (h *history) record(entry HistoryEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.entries) == 0 {
+		return
+	}
+	h.entries[h.next] = entry
+	h.next = (h.next + 1) % len(h.entries)
+	if h.next == 0 {
+		h.filled = true
+	}
+}
+
+// latest returns a copy of the entries, the oldest first. The
+// mutex has to be held.
+func (h *history) latest() []HistoryEntry {
+	if !h.filled {
+		return append([]HistoryEntry(nil), h.entries[:h.next]...)
+	}
+	latest := make([]HistoryEntry, 0, len(h.entries))
+	latest = append(latest, h.entries[h.next:]...)
+	return append(latest, h.entries[:h.next]...)
+}
+
+// SetObservability atomically replaces the observability settings of
+// the Actor without restarting it. Each Action runs with the settings
+// valid when its execution started.
+func (act *Actor) SetObservability(obs Observability) error {
+	if obs.BlockingThreshold < 0 {
+		return fmt.Errorf("%w: negative blocking threshold", ErrInvalid)
+	}
+	if obs.HistorySize < 0 {
+		return fmt.Errorf("%w: negative history size", ErrInvalid)
+	}
+	if obs.BlockingReporter == nil {
+		obs.BlockingReporter = logBlocking
+	}
+	act.history.resize(obs.HistorySize)
+	act.observability.Store(&obs)
+	return nil
+}
+
+// Observability returns the current observability settings.
+func (act *Actor) Observability() Observability {
+	return *act.observability.Load()
+}
+
+// History returns the latest executed Actions, the oldest first. It
+// is empty if the history is switched off.
+func (act *Actor) History() []HistoryEntry {
+	act.history.mu.Lock()
+	defer act.history.mu.Unlock()
+	return act.history.latest()
+}
+
+// recordHistory adds the executed request to the history if it is
+// switched on in the settings of the request.
+func (act *Actor) recordHistory(obs *Observability, req *request, started time.Time, duration time.Duration) {
+	if obs.HistorySize == 0 {
+		return
+	}
+	act.history.record(HistoryEntry{
+		Sequence: req.sequence,
+		Started:  started,
+		Duration: duration,
+	})
+}
+
+// EOF
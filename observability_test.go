@@ -0,0 +1,99 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSetObservabilityHistory verifies that only Actions executed after
+// switching the history on are recorded and that switching it off
+// releases the buffer.
+func TestSetObservabilityHistory(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	assert.Length(act.History(), 0)
+
+	obs := act.Observability()
+	obs.HistorySize = 3
+	assert.OK(act.SetObservability(obs))
+	first := act.Sequence()
+	for i := 0; i < 2; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	history := act.History()
+	assert.Length(history, 2)
+	assert.Equal(history[0].Sequence, first+1)
+	assert.Equal(history[1].Sequence, first+2)
+
+	// The ring keeps the latest ones.
+	for i := 0; i < 4; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	history = act.History()
+	assert.Length(history, 3)
+	assert.Equal(history[2].Sequence, act.Sequence())
+	assert.Equal(history[0].Sequence, act.Sequence()-2)
+
+	obs.HistorySize = 0
+	assert.OK(act.SetObservability(obs))
+	assert.Length(act.History(), 0)
+	assert.OK(act.DoSync(func() {}))
+	assert.Length(act.History(), 0)
+
+	obs.HistorySize = -1
+	assert.True(errors.Is(act.SetObservability(obs), actor.ErrInvalid))
+}
+
+// TestSetObservabilityBlocking verifies switching the blocking detection
+// on at runtime.
+func TestSetObservabilityBlocking(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	reports := make(chan time.Duration, 10)
+	act, err := actor.Go(actor.WithHistory(10))
+	assert.OK(err)
+	defer act.Stop()
+
+	assert.OK(act.DoSync(func() { time.Sleep(20 * time.Millisecond) }))
+	assert.Length(reports, 0)
+
+	obs := act.Observability()
+	obs.BlockingThreshold = 5 * time.Millisecond
+	obs.BlockingReporter = func(threshold time.Duration, stack []byte) {
+		reports <- threshold
+	}
+	assert.OK(act.SetObservability(obs))
+	assert.OK(act.DoSync(func() { time.Sleep(20 * time.Millisecond) }))
+	assert.Equal(<-reports, 5*time.Millisecond)
+
+	history := act.History()
+	assert.Length(history, 2)
+	assert.True(history[1].Duration >= 20*time.Millisecond)
+}
+
+// EOF
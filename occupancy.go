@@ -0,0 +1,127 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//--------------------
+// OCCUPANCY
+//--------------------
+
+// occupancy integrates the number of queued requests over the time
+// with atomic counters. The requests taken from the queue add their
+// residency, kept in whole request-seconds plus request-nanoseconds,
+// so the integral does not overflow for long running Actors with
+// large queues. The still queued ones add the time since they have
+// been submitted when the integral is read.
+type occupancy struct {
+	now          func() time.Time
+	base         time.Time
+	queued       atomic.Int64
+	enqSeconds   atomic.Uint64
+	enqNanos     atomic.Uint64
+	seconds      atomic.Int64
+	nanos        atomic.Int64
+	maxResidency atomic.Int64
+}
+
+// ResetOccupancy resets the occupancy integral and the maximum
+// residency time returned by Metrics, e.g. to measure per period.
+func (act *Actor) ResetOccupancy() {
+	occ := &act.occupancy
+	seconds, nanos := occ.pending(occ.clock())
+	occ.seconds.Store(-seconds)
+	occ.nanos.Store(-nanos)
+	occ.maxResidency.Store(0)
+}
+
+// enqueued records a request entering the queue at its submission.
+func (occ *occupancy) enqueued(req *request) {
+	req.enqueued = true
+	at := occ.since(req.submitted)
+	occ.queued.Add(1)
+	occ.enqSeconds.Add(uint64(at / time.Second))
+	occ.enqNanos.Add(uint64(at % time.Second))
+}
+
+// dequeued records a request leaving the queue.
+func (occ *occupancy) dequeued(req *request) {
+	if !req.enqueued {
+		return
+	}
+	req.enqueued = false
+	at := occ.since(req.submitted)
+	occ.queued.Add(-1)
+	occ.enqSeconds.Add(-uint64(at / time.Second))
+	occ.enqNanos.Add(-uint64(at % time.Second))
+	residency := occ.since(occ.clock()) - at
+	if residency <= 0 {
+		return
+	}
+	occ.seconds.Add(int64(residency / time.Second))
+	if n := occ.nanos.Add(int64(residency % time.Second)); n >= int64(time.Second) {
+		occ.nanos.Add(-int64(time.Second))
+		occ.seconds.Add(1)
+	}
+	for {
+		max := occ.maxResidency.Load()
+		if int64(residency) <= max || occ.maxResidency.CompareAndSwap(max, int64(residency)) {
+			return
+		}
+	}
+}
+
+// pending returns the occupancy of the still queued requests as
+// request-seconds and request-nanoseconds. The sums may wrap around,
+// their difference is exact.
+func (occ *occupancy) pending(now time.Time) (int64, int64) {
+	queued := uint64(occ.queued.Load())
+	at := occ.since(now)
+	seconds := int64(queued*uint64(at/time.Second) - occ.enqSeconds.Load())
+	nanos := int64(queued*uint64(at%time.Second) - occ.enqNanos.Load())
+	return seconds, nanos
+}
+
+// requestSeconds returns the occupancy integral up to now and the
+// maximum residency time.
+func (occ *occupancy) requestSeconds() (float64, time.Duration) {
+	seconds, nanos := occ.pending(occ.clock())
+	seconds += occ.seconds.Load()
+	nanos += occ.nanos.Load()
+	total := float64(seconds) + float64(nanos)/float64(time.Second)
+	if total < 0 {
+		// Counters read while requests are moving.
+		total = 0
+	}
+	return total, time.Duration(occ.maxResidency.Load())
+}
+
+// since returns the time passed from the base to t, at least zero.
+func (occ *occupancy) since(t time.Time) time.Duration {
+	if d := t.Sub(occ.base); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// clock returns the current time.
+func (occ *occupancy) clock() time.Time {
+	if occ.now != nil {
+		return occ.now()
+	}
+	return time.Now()
+}
+
+// EOF
@@ -0,0 +1,106 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestOccupancy verifies the occupancy integral and the maximum
+// residency for a scripted timeline.
+func TestOccupancy(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	now := time.Unix(1000, 0)
+	occ := &occupancy{
+		now:  func() time.Time { return now },
+		base: now,
+	}
+	at := func(d time.Duration) {
+		now = time.Unix(1000, 0).Add(d)
+	}
+	r1 := newRequest(context.Background(), func(context.Context) {})
+	r2 := newRequest(context.Background(), func(context.Context) {})
+	r1.submitted = now
+
+	occ.enqueued(r1)
+	at(time.Second)
+	r2.submitted = now
+	occ.enqueued(r2)
+	at(3 * time.Second)
+	occ.dequeued(r1)
+	at(4500 * time.Millisecond)
+	occ.dequeued(r2)
+	at(10 * time.Second)
+
+	// 1 request for 1s, 2 for 2s, 1 for 1.5s.
+	seconds, residency := occ.requestSeconds()
+	assert.Equal(seconds, 6.5)
+	assert.Equal(residency, 3500*time.Millisecond)
+
+	// Dequeuing twice has no effect.
+	occ.dequeued(r2)
+	seconds, _ = occ.requestSeconds()
+	assert.Equal(seconds, 6.5)
+}
+
+// TestOccupancyOverflow verifies that large queues over long times do
+// not overflow the integral.
+func TestOccupancyOverflow(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	now := time.Unix(1000, 0)
+	occ := &occupancy{
+		now:  func() time.Time { return now },
+		base: now,
+	}
+	occ.queued.Store(1 << 30)
+	// About 1e18 request-seconds, far beyond int64 nanoseconds.
+	now = now.Add(1000*time.Hour + 500*time.Millisecond)
+	seconds, _ := occ.requestSeconds()
+	assert.Equal(seconds, float64(1<<30)*(3600000.5))
+}
+
+// TestResetOccupancy verifies the occupancy in the Metrics of an
+// Actor and its reset.
+func TestResetOccupancy(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	assert.OK(act.DoAsync(func() {}))
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	assert.OK(act.DoSync(func() {}))
+
+	m := act.Metrics()
+	assert.True(m.OccupancyRequestSeconds >= 0.02)
+	assert.True(m.MaxResidency >= 20*time.Millisecond)
+
+	act.ResetOccupancy()
+	m = act.Metrics()
+	assert.True(m.OccupancyRequestSeconds < 0.01)
+	assert.Equal(m.MaxResidency, time.Duration(0))
+}
+
+// EOF
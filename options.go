@@ -13,6 +13,9 @@ package actor // import "tideland.dev/go/actor"
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"time"
 )
 
 //--------------------
@@ -20,16 +23,41 @@ import (
 //--------------------
 
 // Option defines the signature of an option setting function.
+//
+// Options configure an Actor once when it is started by Go. Settings
+// which may also be changed while the Actor is running additionally
+// have a Set method, e.g. WithChaos and SetChaos. These methods apply
+// to the requests executed afterwards and may be called inside an
+// Action too. Settings without a Set method are fixed for the lifetime
+// of the Actor.
 type Option func(act *Actor) error
 
-// WithContext sets the context for the actor.
+// WithContext sets the context for the actor. A nil context
+// is invalid.
 func WithContext(ctx context.Context) Option {
 	return func(act *Actor) error {
+		if ctx == nil {
+			return fmt.Errorf("%w: nil context", ErrInvalid)
+		}
 		act.ctx = ctx
 		return nil
 	}
 }
 
+// WithoutContextWrap lets the Actor use its context directly instead
+// of deriving a cancelable one from it, which saves the registration
+// at the parent for very many Actors. The Actor is stopped by Stop and
+// its variants. A canceled parent context still stops it, but only
+// when the backend sees it between two Actions, not while one is
+// executed. Until then Actions and tracked goroutines do not see the
+// cancellation via the Actor context.
+func WithoutContextWrap() Option {
+	return func(act *Actor) error {
+		act.noContextWrap = true
+		return nil
+	}
+}
+
 // WithQueueCap defines the channel capacity for actions sent to an Actor.
 func WithQueueCap(c int) Option {
 	return func(act *Actor) error {
@@ -41,8 +69,122 @@ func WithQueueCap(c int) Option {
 	}
 }
 
+// WithSubmitOrdering defines how the requests of concurrent callers
+// are ordered. In case of ByArrivalTime the window is the time the
+// backend buffers incoming requests before executing them sorted by
+// their submission time. A window of zero or less uses the default
+// of 5 milliseconds.
+func WithSubmitOrdering(ordering SubmitOrdering, window time.Duration) Option {
+	return func(act *Actor) error {
+		if ordering != ByChannel && ordering != ByArrivalTime {
+			return fmt.Errorf("invalid submit ordering: %d", ordering)
+		}
+		act.ordering = ordering
+		act.orderingWindow = window
+		return nil
+	}
+}
+
+// WithBlockingDetection activates a debug mode watching each Action.
+// If one runs longer than the threshold the stack of the backend
+// goroutine is passed to the reporter. The Action is not interrupted.
+// A nil reporter writes the report to the standard logger. The
+// detection can be changed at runtime with SetObservability.
+func WithBlockingDetection(threshold time.Duration, reporter BlockingReporter) Option {
+	return func(act *Actor) error {
+		obs := Observability{}
+		if current := act.observability.Load(); current != nil {
+			obs = *current
+		}
+		obs.BlockingThreshold = threshold
+		obs.BlockingReporter = reporter
+		return act.SetObservability(obs)
+	}
+}
+
+// WithHistory keeps the given number of executed Actions for History.
+// The size can be changed at runtime with SetObservability.
+func WithHistory(size int) Option {
+	return func(act *Actor) error {
+		obs := Observability{}
+		if current := act.observability.Load(); current != nil {
+			obs = *current
+		}
+		obs.HistorySize = size
+		return act.SetObservability(obs)
+	}
+}
+
+// WithInterceptors adds interceptors called before each Action.
+func WithInterceptors(interceptors ...Interceptor) Option {
+	return func(act *Actor) error {
+		for _, interceptor := range interceptors {
+			if interceptor == nil {
+				return fmt.Errorf("%w: nil interceptor", ErrInvalid)
+			}
+		}
+		act.interceptors = append(act.interceptors, interceptors...)
+		return nil
+	}
+}
+
+// WithWatchdog considers the Actor as stuck if an Action runs longer
+// than the duration. In this case onStuck is called once per stuck
+// Action, e.g. to raise an alert. Other than a timeout the Action is
+// not aborted.
+func WithWatchdog(d time.Duration, onStuck func()) Option {
+	return func(act *Actor) error {
+		if d > 0 && onStuck == nil {
+			return fmt.Errorf("%w: nil watchdog handler", ErrInvalid)
+		}
+		act.watchdog = d
+		act.onStuck = onStuck
+		return nil
+	}
+}
+
+// WithLockerTimeout sets the maximum time a lock taken via the Locker
+// may be held. If it is exceeded the parking Action panics with
+// ErrLockTimeout, which is passed to the Recoverer. The Actor always
+// stops then, with ErrLockTimeout if the Recoverer returns nil, as the
+// lock holder still believes to have exclusive access.
+func WithLockerTimeout(timeout time.Duration) Option {
+	return func(act *Actor) error {
+		act.lockTimeout = timeout
+		return nil
+	}
+}
+
+// WithQueueIntrospection lets the Actor keep an index of the queued
+// Actions for DumpQueue. It costs a mutex operation per queueing and
+// execution.
+func WithQueueIntrospection() Option {
+	return func(act *Actor) error {
+		act.queueIndex = &queueIndex{
+			requests: make(map[*request]struct{}),
+		}
+		return nil
+	}
+}
+
+// WithAutoScaleQueue lets the Actor resize its queue like
+// SetAutoScaleQueue, starting with the minimum. The queue channel
+// gets the maximum as capacity if it is larger.
+func WithAutoScaleQueue(min, max int, targetUtil float64) Option {
+	return func(act *Actor) error {
+		as, err := newAutoScale(min, max, targetUtil, math.MaxInt)
+		if err != nil {
+			return err
+		}
+		act.autoScale.Store(as)
+		return nil
+	}
+}
+
 // WithRecoverer sets a function for recovering from a panic
-// during executing an action.
+// during executing an action. A nil recoverer keeps the default
+// returning the panic as error. A panic with ErrLockTimeout stops
+// the Actor whatever the recoverer returns, see WithLockerTimeout.
 func WithRecoverer(recoverer Recoverer) Option {
 	return func(act *Actor) error {
 		act.recoverer = recoverer
@@ -50,8 +192,302 @@ func WithRecoverer(recoverer Recoverer) Option {
 	}
 }
 
+// WithEventTime lets the Actor execute the Actions submitted with
+// DoEventTime in the order of their event times. The watermark delay
+// is the time an event may arrive after its event time and still be
+// executed in order. Late events are handled according to the policy.
+func WithEventTime(delay time.Duration, late LatePolicy) Option {
+	return func(act *Actor) error {
+		if delay <= 0 {
+			return fmt.Errorf("%w: non-positive watermark delay", ErrInvalid)
+		}
+		act.eventDelay = delay
+		act.latePolicy = late
+		return nil
+	}
+}
+
+// WithMaxPendingBytes limits the estimated memory of the queued
+// asynchronous Actions to n bytes, independent of their number. The
+// sizer estimates the memory of each Action. If the limit would be
+// exceeded the sending waits like for a full queue.
+func WithMaxPendingBytes(n int64, sizer Sizer) Option {
+	return func(act *Actor) error {
+		if n <= 0 || sizer == nil {
+			return fmt.Errorf("%w: invalid pending bytes limit", ErrInvalid)
+		}
+		act.pendingBytes = &pendingBytes{
+			max:   n,
+			sizer: sizer,
+		}
+		return nil
+	}
+}
+
+// WithQuota limits the number of queued requests per quota key, e.g.
+// per tenant, so that one cannot consume the whole queue. The key is
+// determined by the function based on the request context. Keys without
+// an own limit use the default limit, a limit of zero or less means
+// unlimited. A request of a key at its limit is rejected with
+// ErrQuotaExceeded, requests of other keys are still queued.
+func WithQuota(key QuotaKey, limits map[string]int, defaultLimit int) Option {
+	return func(act *Actor) error {
+		if key == nil {
+			return fmt.Errorf("%w: nil quota key", ErrInvalid)
+		}
+		act.quota = &quota{
+			key:          key,
+			limits:       limits,
+			defaultLimit: defaultLimit,
+			counts:       make(map[string]int),
+		}
+		return nil
+	}
+}
+
+// WithOutbox sets the Publisher for the intents added by Actions sent
+// with DoOutbox. A failed publishing is retried after the backoff,
+// which doubles with each further failure.
+func WithOutbox(publish Publisher, backoff time.Duration) Option {
+	return func(act *Actor) error {
+		if publish == nil || backoff <= 0 {
+			return fmt.Errorf("%w: invalid outbox", ErrInvalid)
+		}
+		act.dispatcher = &dispatcher{
+			publish: publish,
+			backoff: backoff,
+			notify:  make(chan struct{}, 1),
+		}
+		return nil
+	}
+}
+
+// WithDrainReads lets the Actor admit reads done with DoRead, Query2,
+// or Query3 while StopGraceful drains the queue. Writes are rejected
+// with ErrDone as usual. Once the drain completes all are rejected.
+func WithDrainReads() Option {
+	return func(act *Actor) error {
+		act.drainReads = true
+		return nil
+	}
+}
+
+// WithProgressInterval sets the interval of the progress reports
+// of WaitStopped. It defaults to 100 milliseconds.
+func WithProgressInterval(interval time.Duration) Option {
+	return func(act *Actor) error {
+		if interval <= 0 {
+			return fmt.Errorf("%w: non-positive progress interval", ErrInvalid)
+		}
+		act.progressInterval = interval
+		return nil
+	}
+}
+
+// WithMaxPanics stops the Actor with ErrTooManyPanics after n
+// recovered panics, even if the Recoverer continues. This keeps
+// a pathological Action from endlessly crashing the Actor. Zero
+// means unlimited.
+func WithMaxPanics(n int) Option {
+	return func(act *Actor) error {
+		if n < 0 {
+			return fmt.Errorf("%w: negative maximum panics", ErrInvalid)
+		}
+		act.maxPanics = uint64(n)
+		return nil
+	}
+}
+
+// WithSummaryFinalizer sets a finalizer receiving a ShutdownSummary
+// in addition to the error. It replaces a finalizer set with
+// WithFinalizer.
+func WithSummaryFinalizer(finalizer SummaryFinalizer) Option {
+	return func(act *Actor) error {
+		act.summaryFinalizer = finalizer
+		return nil
+	}
+}
+
+// WithCrashDumper sets a function receiving a CrashDump whenever a
+// panic of an Action is recovered. It is called in its own goroutine.
+// The optional summarizer is called inside the backend before, so it
+// sees the actual state, and its result becomes the summary of the
+// dump.
+func WithCrashDumper(dumper CrashDumper, summarizer func() string) Option {
+	return func(act *Actor) error {
+		act.crashDumper = dumper
+		act.summarizer = summarizer
+		return nil
+	}
+}
+
+// WithGoroutineLabels sets pprof labels for the backend goroutine of
+// the Actor and the goroutines of its Repeaters. So goroutine and CPU
+// profiles can attribute the stacks to individual Actors, e.g. by a
+// name.
+func WithGoroutineLabels(labels map[string]string) Option {
+	return func(act *Actor) error {
+		if len(labels) == 0 {
+			return fmt.Errorf("%w: no goroutine labels", ErrInvalid)
+		}
+		set := labelSet(labels)
+		act.labels = &set
+		return nil
+	}
+}
+
+// WithHLCClock sets the wall clock used for the hybrid logical
+// timestamps enabled with SetHLC. It defaults to time.Now.
+func WithHLCClock(now func() time.Time) Option {
+	return func(act *Actor) error {
+		if now == nil {
+			return fmt.Errorf("%w: nil clock", ErrInvalid)
+		}
+		act.hlc.now = now
+		return nil
+	}
+}
+
+// WithWallClock sets the wall clock DoAt schedules its Actions by.
+// It defaults to time.Now.
+func WithWallClock(now func() time.Time) Option {
+	return func(act *Actor) error {
+		if now == nil {
+			return fmt.Errorf("%w: nil clock", ErrInvalid)
+		}
+		act.wallClock = now
+		return nil
+	}
+}
+
+// WithScheduleCadence sets the interval DoAt re-evaluates the wall
+// clock in at least. It defaults to one second.
+func WithScheduleCadence(cadence time.Duration) Option {
+	return func(act *Actor) error {
+		if cadence <= 0 {
+			return fmt.Errorf("%w: non-positive schedule cadence", ErrInvalid)
+		}
+		act.scheduleCadence = cadence
+		return nil
+	}
+}
+
+// WithShutdownTimeout sets the time the termination of the Actor waits
+// for the goroutines started with TrackedGo. It defaults to five
+// seconds.
+func WithShutdownTimeout(timeout time.Duration) Option {
+	return func(act *Actor) error {
+		if timeout <= 0 {
+			return fmt.Errorf("%w: non-positive shutdown timeout", ErrInvalid)
+		}
+		act.shutdownTimeout = timeout
+		return nil
+	}
+}
+
+// WithUserData attaches arbitrary data to the Actor, e.g. a registry
+// key or a connection handle used by generic management code. It is
+// returned by UserData and never passed through Actions. If the data
+// is changed after the start, its synchronization is up to the user.
+func WithUserData(data any) Option {
+	return func(act *Actor) error {
+		act.userData = data
+		return nil
+	}
+}
+
+// WithErrorBackoff sets the delay after panicking Actions like
+// SetErrorBackoff.
+func WithErrorBackoff(base, max time.Duration) Option {
+	return func(act *Actor) error {
+		if max < base {
+			max = base
+		}
+		act.errorBackoff.base = base
+		act.errorBackoff.max = max
+		return nil
+	}
+}
+
+// WithHLC enables the stamping of the Actions with hybrid logical
+// timestamps like SetHLC.
+func WithHLC() Option {
+	return func(act *Actor) error {
+		act.SetHLC(true)
+		return nil
+	}
+}
+
+// WithChaos sets the policy for injecting faults like SetChaos.
+func WithChaos(policy ChaosPolicy) Option {
+	return func(act *Actor) error {
+		return act.SetChaos(policy)
+	}
+}
+
+// WithPriorityClasses sets the weights of the priority classes like
+// SetPriorityClasses.
+func WithPriorityClasses(weights map[PriorityClass]int) Option {
+	return func(act *Actor) error {
+		return act.SetPriorityClasses(weights)
+	}
+}
+
+// WithSyncLatencyBudget sets the time synchronous requests may wait
+// in the queue like SetSyncLatencyBudget.
+func WithSyncLatencyBudget(d time.Duration) Option {
+	return func(act *Actor) error {
+		act.SetSyncLatencyBudget(d)
+		return nil
+	}
+}
+
+// WithDropConsecutiveDuplicates sets the key for dropping duplicate
+// asynchronous requests like SetDropConsecutiveDuplicates.
+func WithDropConsecutiveDuplicates(key DuplicateKey) Option {
+	return func(act *Actor) error {
+		act.SetDropConsecutiveDuplicates(key)
+		return nil
+	}
+}
+
+// WithCommitHook sets the hook for batched commits like SetCommitHook.
+func WithCommitHook(n int, hook CommitHook) Option {
+	return func(act *Actor) error {
+		if n < 1 {
+			return fmt.Errorf("%w: non-positive commit cadence", ErrInvalid)
+		}
+		act.commits.every = n
+		act.commits.hook = hook
+		return nil
+	}
+}
+
+// WithOverflowSpill lets DoCommand write the Commands to a file in the
+// directory while the queue is full. They are replayed in order when
+// the queue has room again, later Commands are spilled as long as
+// spilled ones are pending. The replay progress is persisted after
+// each executed Command, so Commands not executed when the Actor
+// stops are replayed by the next Actor using the same directory. A
+// Command interrupted between its execution and the recording is
+// replayed again, so they are delivered at least once.
+func WithOverflowSpill(dir string, encode CommandEncoder, decode CommandDecoder) Option {
+	return func(act *Actor) error {
+		if dir == "" || encode == nil || decode == nil {
+			return fmt.Errorf("%w: spill directory, encoder, or decoder", ErrInvalid)
+		}
+		act.spillConfig = &spillConfig{
+			dir:    dir,
+			encode: encode,
+			decode: decode,
+		}
+		return nil
+	}
+}
+
 // WithFinalizer sets a function for finalizing the
-// work of a Loop.
+// work of an Actor. A nil finalizer keeps the default
+// returning the Actor error unchanged.
 func WithFinalizer(finalizer Finalizer) Option {
 	return func(act *Actor) error {
 		act.finalizer = finalizer
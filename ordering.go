@@ -0,0 +1,79 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"time"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// defaultOrderingWindow is the default time the backend
+	// buffers requests before processing them ordered.
+	defaultOrderingWindow = 5 * time.Millisecond
+)
+
+//--------------------
+// SUBMIT ORDERING
+//--------------------
+
+// SubmitOrdering defines how the backend orders the requests
+// of concurrent callers.
+type SubmitOrdering int
+
+const (
+	// ByChannel processes the requests in the order they win the
+	// send to the request queue. When multiple goroutines submit
+	// concurrently this order is nondeterministic. It is the default.
+	ByChannel SubmitOrdering = iota
+
+	// ByArrivalTime processes the requests in the order of their
+	// submission timestamps. To achieve this the backend buffers
+	// incoming requests for a short window and sorts them. So each
+	// Action gets an added latency of up to this window.
+	ByArrivalTime
+)
+
+// orderRequests collects further requests following the first
+// one during the ordering window and returns them sorted by their
+// submission time.
+func (act *Actor) orderRequests(first *request) []*request {
+	reqs := []*request{first}
+	timer := time.NewTimer(act.orderingWindow)
+	defer timer.Stop()
+	for {
+		select {
+		case <-act.ctx.Done():
+			return sortRequests(reqs)
+		case <-timer.C:
+			return sortRequests(reqs)
+		case req := <-act.requests:
+			act.arrive(req)
+			reqs = append(reqs, req)
+		}
+	}
+}
+
+// sortRequests sorts the requests by their submission time. Equal
+// timestamps keep their order of arrival.
+func sortRequests(reqs []*request) []*request {
+	sort.SliceStable(reqs, func(i, j int) bool {
+		return reqs[i].submitted.Before(reqs[j].submitted)
+	})
+	return reqs
+}
+
+// EOF
@@ -0,0 +1,73 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSubmitOrderingByArrivalTime verifies that requests of concurrent
+// callers are processed in the order of their submission timestamps.
+func TestSubmitOrderingByArrivalTime(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := Go(WithSubmitOrdering(ByArrivalTime, 100*time.Millisecond))
+	assert.OK(err)
+	defer act.Stop()
+
+	// Submit from several goroutines with timestamps contrary
+	// to the order of their start.
+	const callers = 10
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	order := []int{}
+	base := time.Now()
+
+	wg.Add(callers)
+	for i := 0; i < callers; i++ {
+		go func(n int) {
+			defer wg.Done()
+			req := newRequest(context.Background(), func(context.Context) {
+				mu.Lock()
+				defer mu.Unlock()
+				order = append(order, n)
+			})
+			req.submitted = base.Add(time.Duration(callers-n) * time.Millisecond)
+			assert.NoError(act.send(req))
+			assert.NoError(act.wait(req))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Length(order, callers)
+	for i := 0; i < callers; i++ {
+		assert.Equal(order[i], callers-1-i)
+	}
+}
+
+// TestSubmitOrderingInvalid verifies the rejection of an invalid ordering.
+func TestSubmitOrderingInvalid(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := Go(WithSubmitOrdering(SubmitOrdering(42), 0))
+	assert.ErrorMatch(err, "invalid submit ordering: 42")
+	assert.Nil(act)
+}
+
+// EOF
@@ -0,0 +1,170 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// maxBackoffFactor limits the growth of the outbox retry backoff.
+	maxBackoffFactor = 32
+)
+
+//--------------------
+// OUTBOX
+//--------------------
+
+// Publisher delivers an intent added to an Outbox to an external
+// system, e.g. a message broker. The sequence is the apply sequence
+// of the Action which added it. Returning an error lets the
+// dispatcher retry it after a backoff.
+type Publisher func(seq uint64, payload any) error
+
+// Outbox collects the intents of an Action sent with DoOutbox.
+type Outbox struct {
+	intents []any
+	stamp   HLCTimestamp
+}
+
+// Timestamp returns the HLCTimestamp of the Action if SetHLC is
+// enabled, so it can be added to the intents.
+func (out *Outbox) Timestamp() HLCTimestamp {
+	return out.stamp
+}
+
+// Add adds an intent to be published after the Action completed.
+func (out *Outbox) Add(payload any) {
+	out.intents = append(out.intents, payload)
+}
+
+// intent is a stored intent waiting to be published.
+type intent struct {
+	seq     uint64
+	payload any
+}
+
+// dispatcher publishes the intents in their order.
+type dispatcher struct {
+	publish Publisher
+	backoff time.Duration
+	mu      sync.Mutex
+	intents []intent
+	notify  chan struct{}
+}
+
+// DoOutbox sends an Action receiving an Outbox to the backend and
+// returns when it's queued. The intents added by the Action are stored
+// with its apply sequence when it completed without a panic and then
+// published in the background by the Publisher set with WithOutbox.
+// So publishing neither blocks the Actor nor happens before the state
+// change. The intents are published strictly in order, a failing one
+// is retried with a growing backoff and holds the later ones back.
+// When the Actor is done the dispatcher stops at the first failure.
+func (act *Actor) DoOutbox(action func(out *Outbox)) error {
+	if err := act.admit(context.Background(), action != nil); err != nil {
+		return err
+	}
+	if act.dispatcher == nil {
+		return fmt.Errorf("%w: no outbox configured", ErrInvalid)
+	}
+	var req *request
+	req = newRequest(context.Background(), func(ctx context.Context) {
+		out := &Outbox{}
+		out.stamp, _ = Timestamp(ctx)
+		action(out)
+		act.dispatcher.store(req.sequence, out.intents)
+	})
+	req.origin = action
+	return act.sendAsync(req)
+}
+
+// OutboxLen returns the number of intents waiting to be published.
+func (act *Actor) OutboxLen() int {
+	if act.dispatcher == nil {
+		return 0
+	}
+	act.dispatcher.mu.Lock()
+	defer act.dispatcher.mu.Unlock()
+	return len(act.dispatcher.intents)
+}
+
+// store appends the intents of a completed Action.
+func (d *dispatcher) store(seq uint64, payloads []any) {
+	if len(payloads) == 0 {
+		return
+	}
+	d.mu.Lock()
+	for _, payload := range payloads {
+		d.intents = append(d.intents, intent{seq, payload})
+	}
+	d.mu.Unlock()
+	select {
+	case d.notify <- struct{}{}:
+	default:
+	}
+}
+
+// first returns the oldest stored intent.
+func (d *dispatcher) first() (intent, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.intents) == 0 {
+		return intent{}, false
+	}
+	return d.intents[0], true
+}
+
+// truncate removes the oldest intent after its publishing.
+func (d *dispatcher) truncate() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.intents = d.intents[1:]
+}
+
+// run publishes the stored intents until the Actor is done.
+func (d *dispatcher) run(done <-chan struct{}) {
+	factor := time.Duration(1)
+	for {
+		in, ok := d.first()
+		if !ok {
+			select {
+			case <-d.notify:
+				continue
+			case <-done:
+				return
+			}
+		}
+		if err := d.publish(in.seq, in.payload); err != nil {
+			select {
+			case <-done:
+				return
+			case <-time.After(factor * d.backoff):
+			}
+			if factor < maxBackoffFactor {
+				factor *= 2
+			}
+			continue
+		}
+		factor = 1
+		d.truncate()
+	}
+}
+
+// EOF
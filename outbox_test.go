@@ -0,0 +1,103 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestOutbox verifies the ordered publishing of intents with retries
+// not blocking the Actor.
+func TestOutbox(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var mu sync.Mutex
+	published := []string{}
+	seqs := []uint64{}
+	var failures atomic.Int32
+	failures.Store(3)
+	var committed atomic.Bool
+	publish := func(seq uint64, payload any) error {
+		if payload == "slow" {
+			assert.True(committed.Load(), "published before commit")
+		}
+		if failures.Add(-1) >= 0 {
+			return errors.New("broker unavailable")
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, payload.(string))
+		seqs = append(seqs, seq)
+		return nil
+	}
+	act, err := actor.Go(actor.WithOutbox(publish, 10*time.Millisecond))
+	assert.OK(err)
+	defer act.Stop()
+
+	balance := 0
+	assert.OK(act.DoOutbox(func(out *actor.Outbox) {
+		out.Add("slow")
+		time.Sleep(20 * time.Millisecond)
+		committed.Store(true)
+	}))
+	for i := 0; i < 3; i++ {
+		assert.OK(act.DoOutbox(func(out *actor.Outbox) {
+			balance++
+			out.Add("deposited")
+			out.Add("notified")
+		}))
+	}
+	// The failing publisher does not block the Actor.
+	start := time.Now()
+	assert.OK(act.DoSync(func() {
+		assert.Equal(balance, 3)
+	}))
+	assert.True(time.Since(start) < 50*time.Millisecond)
+
+	assert.Retry(func() bool { return act.OutboxLen() == 0 }, 100, 10*time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(published, []string{
+		"slow",
+		"deposited", "notified",
+		"deposited", "notified",
+		"deposited", "notified",
+	})
+	assert.Equal(seqs, []uint64{1, 2, 2, 3, 3, 4, 4})
+}
+
+// TestOutboxInvalid verifies the rejection of outbox Actions without
+// a configured outbox.
+func TestOutboxInvalid(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	err = act.DoOutbox(func(out *actor.Outbox) {})
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	_, err = actor.Go(actor.WithOutbox(nil, time.Second))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
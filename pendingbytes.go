@@ -0,0 +1,113 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sync"
+)
+
+//--------------------
+// PENDING BYTES
+//--------------------
+
+// Sizer estimates the memory of a queued asynchronous Action, e.g.
+// of the buffers it captures. It receives the Action or ContextAction.
+type Sizer func(action any) int64
+
+// pendingBytes limits the estimated memory of queued asynchronous
+// Actions.
+type pendingBytes struct {
+	max   int64
+	sizer Sizer
+	mu    sync.Mutex
+	bytes int64
+	freed chan struct{}
+}
+
+// PendingBytes returns the estimated memory of the queued asynchronous
+// Actions if WithMaxPendingBytes is set.
+func (act *Actor) PendingBytes() int64 {
+	if act.pendingBytes == nil {
+		return 0
+	}
+	act.pendingBytes.mu.Lock()
+	defer act.pendingBytes.mu.Unlock()
+	return act.pendingBytes.bytes
+}
+
+// sendAsync sends an asynchronous request. With WithMaxPendingBytes it
+// first waits like for a full queue until its estimated memory fits.
+func (act *Actor) sendAsync(req *request) error {
+	if act.pendingBytes == nil {
+		return act.send(req)
+	}
+	if err := act.reserveBytes(req); err != nil {
+		return err
+	}
+	if err := act.send(req); err != nil {
+		act.releaseBytes(req)
+		return err
+	}
+	return nil
+}
+
+// reserveBytes waits until the memory of the request fits into the
+// limit. A single request exceeding it is accepted if nothing else is
+// pending.
+func (act *Actor) reserveBytes(req *request) error {
+	pb := act.pendingBytes
+	size := pb.sizer(req.origin)
+	if size <= 0 {
+		return nil
+	}
+	for {
+		pb.mu.Lock()
+		if pb.bytes == 0 || pb.bytes+size <= pb.max {
+			pb.bytes += size
+			req.extension().size = size
+			pb.mu.Unlock()
+			return nil
+		}
+		if pb.freed == nil {
+			pb.freed = make(chan struct{})
+		}
+		freed := pb.freed
+		pb.mu.Unlock()
+		select {
+		case <-freed:
+		case <-req.ctx.Done():
+			return fmt.Errorf("action context sending: %v", req.ctx.Err())
+		case <-act.ctx.Done():
+			return act.alive()
+		}
+	}
+}
+
+// releaseBytes releases the memory reserved by a request when it
+// leaves the queue.
+func (act *Actor) releaseBytes(req *request) {
+	if req.ext == nil || req.ext.size == 0 || act.pendingBytes == nil {
+		return
+	}
+	pb := act.pendingBytes
+	pb.mu.Lock()
+	defer pb.mu.Unlock()
+	pb.bytes -= req.ext.size
+	req.ext.size = 0
+	if pb.freed != nil {
+		close(pb.freed)
+		pb.freed = nil
+	}
+}
+
+// EOF
@@ -0,0 +1,87 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestMaxPendingBytes verifies that the estimated memory of queued
+// Actions limits the queueing independent of the queue length.
+func TestMaxPendingBytes(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	const mb = 1 << 20
+	act, err := actor.Go(actor.WithMaxPendingBytes(3*mb, func(action any) int64 {
+		return mb
+	}))
+	assert.OK(err)
+	defer act.Stop()
+
+	// Block the Actor, so the large Actions stay queued.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	assert.Retry(func() bool { return act.PendingBytes() == 0 }, 100, time.Millisecond)
+
+	processed := 0
+	large := func() actor.Action {
+		buf := make([]byte, mb)
+		return func() {
+			processed += len(buf) / mb
+		}
+	}
+	for i := 0; i < 3; i++ {
+		assert.OK(act.DoAsync(large()))
+	}
+	assert.Equal(act.PendingBytes(), int64(3*mb))
+
+	// The limit is reached with three queued Actions.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err = act.DoAsyncWithContext(ctx, large())
+	assert.ErrorMatch(err, ".*deadline exceeded")
+	assert.Equal(act.PendingBytes(), int64(3*mb))
+	assert.Equal(act.Metrics().Queued, 3)
+
+	// Waiting senders continue when room is made.
+	sent := make(chan error)
+	go func() {
+		sent <- act.DoAsync(large())
+	}()
+	select {
+	case <-sent:
+		t.Fatal("sent despite limit")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	assert.OK(<-sent)
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(processed, 4)
+	assert.Equal(act.PendingBytes(), int64(0))
+
+	_, err = actor.Go(actor.WithMaxPendingBytes(0, nil))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
@@ -0,0 +1,159 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+//--------------------
+// POOL
+//--------------------
+
+// PoolMetrics contains aggregated metrics of the members of a Pool.
+type PoolMetrics struct {
+	// Members is the number of Actors in the Pool.
+	Members int
+
+	// Queued is the number of Actions waiting in the queues
+	// of all members.
+	Queued int
+
+	// Executed is the number of Actions all members started
+	// to execute.
+	Executed uint64
+
+	// Panicked is the number of panics recovered by all members.
+	Panicked uint64
+
+	// Reordered is the number of Actions all members executed
+	// out of their arrival order.
+	Reordered uint64
+
+	// Coalesced is the number of asynchronous Actions all members
+	// dropped as consecutive duplicates.
+	Coalesced uint64
+}
+
+// Pool distributes Actions across identical Actors for workloads which
+// can be handled by any member. Each Action is sent to the member with
+// the shortest queue, ties are broken round-robin. So different from a
+// single Actor the Actions of a Pool are not serialized with each other.
+type Pool struct {
+	members []*Actor
+	next    atomic.Uint64
+}
+
+// NewPool starts a Pool of size Actors, all with the same options.
+func NewPool(size int, options ...Option) (*Pool, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("%w: pool size %d", ErrInvalid, size)
+	}
+	p := &Pool{
+		members: make([]*Actor, size),
+	}
+	for i := range p.members {
+		act, err := Go(options...)
+		if err != nil {
+			p.Stop()
+			return nil, err
+		}
+		p.members[i] = act
+	}
+	return p, nil
+}
+
+// DoAsync sends the Action to the least loaded member and returns
+// when it's queued.
+func (p *Pool) DoAsync(action Action) error {
+	return p.DoAsyncWithContext(context.Background(), action)
+}
+
+// DoAsyncWithContext sends the Action to the least loaded member and
+// returns when it's queued.
+func (p *Pool) DoAsyncWithContext(ctx context.Context, action Action) error {
+	return p.pick().DoAsyncWithContext(ctx, action)
+}
+
+// DoSync executes the Action on the least loaded member and returns
+// when it's done.
+func (p *Pool) DoSync(action Action) error {
+	return p.DoSyncWithContext(context.Background(), action)
+}
+
+// DoSyncWithContext executes the Action on the least loaded member
+// and returns when it's done.
+func (p *Pool) DoSyncWithContext(ctx context.Context, action Action) error {
+	return p.pick().DoSyncWithContext(ctx, action)
+}
+
+// Members returns the Actors of the Pool.
+func (p *Pool) Members() []*Actor {
+	members := make([]*Actor, len(p.members))
+	copy(members, p.members)
+	return members
+}
+
+// Metrics returns the aggregated metrics of all members.
+func (p *Pool) Metrics() PoolMetrics {
+	metrics := PoolMetrics{
+		Members: len(p.members),
+	}
+	for _, act := range p.members {
+		if act == nil {
+			continue
+		}
+		m := act.Metrics()
+		metrics.Queued += m.Queued
+		metrics.Executed += m.Executed
+		metrics.Panicked += m.Panicked
+		metrics.Reordered += m.Reordered
+		metrics.Coalesced += m.Coalesced
+	}
+	return metrics
+}
+
+// StuckActions returns the stuck Actions of all members.
+func (p *Pool) StuckActions() []ActionInfo {
+	var infos []ActionInfo
+	for _, act := range p.members {
+		infos = append(infos, act.StuckActions()...)
+	}
+	return infos
+}
+
+// Stop terminates all members of the Pool.
+func (p *Pool) Stop() {
+	for _, act := range p.members {
+		if act != nil {
+			act.Stop()
+		}
+	}
+}
+
+// pick returns the member with the shortest queue. The search starts
+// round-robin, so equally loaded members are used in turn.
+func (p *Pool) pick() *Actor {
+	start := int(p.next.Add(1) % uint64(len(p.members)))
+	picked := p.members[start]
+	for i := 1; i < len(p.members); i++ {
+		act := p.members[(start+i)%len(p.members)]
+		if act.queued() < picked.queued() {
+			picked = act
+		}
+	}
+	return picked
+}
+
+// EOF
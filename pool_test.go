@@ -0,0 +1,63 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestPool verifies the distribution of Actions across the members.
+func TestPool(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	pool, err := actor.NewPool(4)
+	assert.OK(err)
+
+	var executed atomic.Int32
+	for i := 0; i < 100; i++ {
+		assert.OK(pool.DoAsync(func() {
+			time.Sleep(time.Millisecond)
+			executed.Add(1)
+		}))
+	}
+	assert.OK(pool.DoSync(func() {}))
+	assert.Retry(func() bool { return executed.Load() == 100 }, 50, 10*time.Millisecond)
+
+	metrics := pool.Metrics()
+	assert.Equal(metrics.Members, 4)
+	assert.Equal(metrics.Queued, 0)
+	assert.Equal(metrics.Executed, uint64(101))
+	for _, member := range pool.Members() {
+		assert.True(member.Sequence() >= 20, "actions spread across members")
+	}
+
+	pool.Stop()
+	for _, member := range pool.Members() {
+		<-member.Done()
+	}
+	assert.True(errors.Is(pool.DoSync(func() {}), actor.ErrDone))
+
+	_, err = actor.NewPool(0)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
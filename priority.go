@@ -0,0 +1,198 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//--------------------
+// PRIORITY CLASSES
+//--------------------
+
+// PriorityClass names a class of requests served with its own weight,
+// e.g. interactive, batch, and background work.
+type PriorityClass string
+
+// DefaultClass is the class of requests without a configured class.
+const DefaultClass PriorityClass = ""
+
+// priorityClassKey is the context key of the priority class.
+type priorityClassKey struct{}
+
+// WithPriorityClass returns a context tagging the requests sent with
+// it with the priority class.
+func WithPriorityClass(ctx context.Context, class PriorityClass) context.Context {
+	return context.WithValue(ctx, priorityClassKey{}, class)
+}
+
+// ClassMetrics contains the counters of a priority class.
+type ClassMetrics struct {
+	// Pending is the number of requests of the class taken from
+	// the queue but not yet executed.
+	Pending int
+
+	// Served is the number of requests of the class passed to
+	// the execution.
+	Served uint64
+}
+
+// priority serves the pending requests by the weights of their classes.
+type priority struct {
+	weights map[PriorityClass]int
+	current map[PriorityClass]int
+
+	mu      sync.Mutex
+	metrics map[PriorityClass]*ClassMetrics
+}
+
+// SetPriorityClasses lets the Actor serve the requests of the classes
+// in a smooth weighted round-robin. Each class gets a share of the
+// executions according to its weight as long as it has pending work,
+// so lower weighted ones never starve. The class of a request is set
+// with WithPriorityClass on its context. Requests of other classes or
+// without one belong to DefaultClass, which has the weight 1 unless it
+// is configured. Like with a sync latency budget the queued requests
+// are taken into a backlog, so senders wait for room in the queue
+// capacity instead. Markers like fences keep their position. Nil or
+// empty weights return to the order of arrival.
+func (act *Actor) SetPriorityClasses(weights map[PriorityClass]int) error {
+	if len(weights) == 0 {
+		act.priority.Store(nil)
+		return nil
+	}
+	p := &priority{
+		weights: make(map[PriorityClass]int, len(weights)),
+		current: make(map[PriorityClass]int, len(weights)+1),
+		metrics: make(map[PriorityClass]*ClassMetrics, len(weights)+1),
+	}
+	for class, weight := range weights {
+		if weight < 1 {
+			return fmt.Errorf("%w: non-positive weight of class %q", ErrInvalid, class)
+		}
+		p.weights[class] = weight
+	}
+	act.priority.Store(p)
+	return nil
+}
+
+// classify sets the class of an arriving request and counts it
+// as pending.
+func (act *Actor) classify(req *request) {
+	p := act.priority.Load()
+	if p == nil || req.marker {
+		return
+	}
+	class, _ := req.ctx.Value(priorityClassKey{}).(PriorityClass)
+	if _, ok := p.weights[class]; !ok {
+		class = DefaultClass
+	}
+	ext := req.extension()
+	ext.class = class
+	ext.priority = p
+	p.mu.Lock()
+	p.classMetrics(class).Pending++
+	p.mu.Unlock()
+}
+
+// serve counts a classified request passed to the execution.
+func (act *Actor) serve(req *request) {
+	if req.ext == nil || req.ext.priority == nil {
+		return
+	}
+	p := req.ext.priority
+	req.ext.priority = nil
+	p.mu.Lock()
+	cm := p.classMetrics(req.ext.class)
+	cm.Pending--
+	cm.Served++
+	p.mu.Unlock()
+}
+
+// priorityClass returns the class set when classifying the request.
+func (req *request) priorityClass() PriorityClass {
+	if req.ext == nil {
+		return ""
+	}
+	return req.ext.class
+}
+
+// classMetrics returns the metrics of the class. The mutex
+// has to be locked.
+func (p *priority) classMetrics(class PriorityClass) *ClassMetrics {
+	cm, ok := p.metrics[class]
+	if !ok {
+		cm = &ClassMetrics{}
+		p.metrics[class] = cm
+	}
+	return cm
+}
+
+// snapshot copies the metrics of all classes.
+func (p *priority) snapshot() map[PriorityClass]ClassMetrics {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	metrics := make(map[PriorityClass]ClassMetrics, len(p.metrics))
+	for class, cm := range p.metrics {
+		metrics[class] = *cm
+	}
+	return metrics
+}
+
+// weight returns the weight of the class.
+func (p *priority) weight(class PriorityClass) int {
+	if weight, ok := p.weights[class]; ok {
+		return weight
+	}
+	return 1
+}
+
+// pick returns the index of the pending request to execute next. It
+// chooses between the first requests of each class in front of the
+// first marker. Of those the class with the highest current weight
+// wins, ties go to the earlier request.
+func (p *priority) pick(pending []*request) int {
+	first := make(map[PriorityClass]int, len(p.weights)+1)
+	for i, req := range pending {
+		if req.marker {
+			break
+		}
+		if _, ok := first[req.priorityClass()]; !ok {
+			first[req.priorityClass()] = i
+			if len(first) > len(p.weights) {
+				break
+			}
+		}
+	}
+	if len(first) < 2 {
+		return 0
+	}
+	total := 0
+	chosen := -1
+	var chosenClass PriorityClass
+	for class, i := range first {
+		weight := p.weight(class)
+		total += weight
+		p.current[class] += weight
+		if chosen < 0 || p.current[class] > p.current[chosenClass] ||
+			(p.current[class] == p.current[chosenClass] && i < chosen) {
+			chosen = i
+			chosenClass = class
+		}
+	}
+	p.current[chosenClass] -= total
+	return chosen
+}
+
+// EOF
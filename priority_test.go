@@ -0,0 +1,145 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestPriorityClassesWeights verifies that saturated classes are
+// served according to their weights.
+func TestPriorityClassesWeights(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithQueueCap(4096))
+	assert.OK(err)
+	defer act.Stop()
+
+	weights := map[actor.PriorityClass]int{
+		"interactive": 6,
+		"batch":       3,
+		"background":  1,
+	}
+	assert.OK(act.SetPriorityClasses(weights))
+
+	// Block the Actor until all classes have a backlog.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() { <-release }))
+	served := map[actor.PriorityClass]int{}
+	executed := 0
+	const window = 1000
+	for i := 0; i < window; i++ {
+		for class := range weights {
+			class := class
+			ctx := actor.WithPriorityClass(context.Background(), class)
+			assert.OK(act.DoAsyncWithContext(ctx, func() {
+				executed++
+				if executed <= window {
+					served[class]++
+				}
+			}))
+		}
+	}
+	close(release)
+	assert.OK(actor.Quiesce(context.Background(), act))
+
+	for class, weight := range weights {
+		expected := float64(window * weight / 10)
+		got := float64(served[class])
+		assert.True(got >= 0.9*expected && got <= 1.1*expected, string(class))
+	}
+	m := act.Metrics()
+	assert.Length(m.Classes, 4)
+	assert.Equal(m.Classes["interactive"], actor.ClassMetrics{Pending: 0, Served: window})
+	assert.Equal(m.Classes[actor.DefaultClass].Served, uint64(1))
+}
+
+// TestPriorityClassesFence verifies that markers keep their position
+// and that the classes can be switched off.
+func TestPriorityClassesFence(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	assert.OK(act.SetPriorityClasses(map[actor.PriorityClass]int{"high": 10}))
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	var order []string
+	assert.OK(act.DoAsync(func() { order = append(order, "low") }))
+	fenced := make(chan error)
+	go func() {
+		fenced <- act.Fence(ctx)
+	}()
+	assert.Retry(func() bool { return act.Metrics().Queued == 2 }, 100, time.Millisecond)
+	high := actor.WithPriorityClass(ctx, "high")
+	assert.OK(act.DoAsyncWithContext(high, func() { order = append(order, "high") }))
+	close(release)
+	assert.OK(<-fenced)
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(order, []string{"low", "high"})
+
+	assert.OK(act.SetPriorityClasses(nil))
+	assert.Nil(act.Metrics().Classes)
+	err = act.SetPriorityClasses(map[actor.PriorityClass]int{"zero": 0})
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestPriorityClassesQueued verifies that requests taken from the
+// queue to choose by priority are still counted as queued.
+func TestPriorityClassesQueued(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	assert.OK(act.SetPriorityClasses(map[actor.PriorityClass]int{"batch": 1}))
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	block := func() {
+		started <- struct{}{}
+		<-release
+	}
+	assert.OK(act.DoAsync(block))
+	<-started
+	assert.OK(act.DoAsync(block))
+	for i := 0; i < 10; i++ {
+		assert.OK(act.DoAsync(func() {}))
+	}
+	assert.Equal(act.Metrics().Queued, 11)
+
+	// The backend takes all queued ones before executing the second.
+	release <- struct{}{}
+	<-started
+	assert.Equal(act.Metrics().Queued, 10)
+	close(release)
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(act.Metrics().Queued, 0)
+}
+
+// EOF
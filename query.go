@@ -0,0 +1,284 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+//--------------------
+// QUERY
+//--------------------
+
+// Query2 executes the function synchronously and returns its two
+// values. Both come from the same execution, so they are consistent.
+// Like DoRead the function must not change the state.
+func Query2[A, B any](ctx context.Context, act *Actor, fn func() (A, B)) (A, B, error) {
+	var a A
+	var b B
+	if err := act.admitFor(ctx, fn != nil, true); err != nil {
+		return a, b, err
+	}
+	if err := act.DoRead(ctx, func() {
+		a, b = fn()
+	}); err != nil {
+		// The function may still be executed later.
+		var za A
+		var zb B
+		return za, zb, err
+	}
+	return a, b, nil
+}
+
+// Query3 executes the function synchronously and returns its three
+// values. All come from the same execution, so they are consistent.
+// Like DoRead the function must not change the state.
+func Query3[A, B, C any](ctx context.Context, act *Actor, fn func() (A, B, C)) (A, B, C, error) {
+	var a A
+	var b B
+	var c C
+	if err := act.admitFor(ctx, fn != nil, true); err != nil {
+		return a, b, c, err
+	}
+	if err := act.DoRead(ctx, func() {
+		a, b, c = fn()
+	}); err != nil {
+		// The function may still be executed later.
+		var za A
+		var zb B
+		var zc C
+		return za, zb, zc, err
+	}
+	return a, b, c, nil
+}
+
+// QueryCopy executes the getter synchronously and returns a copy of
+// its result made by clone inside the backend. So the caller owns the
+// result instead of sharing a slice, map, or pointer with the state of
+// the Actor. CloneSlice and CloneMap help for the common cases. A nil
+// clone is only accepted for result types without references at their
+// top level, otherwise ErrInvalid is returned to force a decision.
+func QueryCopy[R any](ctx context.Context, act *Actor, getter func() R, clone func(R) R) (R, error) {
+	var r R
+	if clone == nil {
+		if kind := reflect.TypeOf(&r).Elem().Kind(); isReferenceKind(kind) {
+			return r, fmt.Errorf("%w: nil clone for %v result", ErrInvalid, kind)
+		}
+		clone = func(r R) R { return r }
+	}
+	if err := act.admitFor(ctx, getter != nil, true); err != nil {
+		return r, err
+	}
+	if err := act.DoRead(ctx, func() {
+		r = clone(getter())
+	}); err != nil {
+		// The getter may still be executed later.
+		var zr R
+		return zr, err
+	}
+	return r, nil
+}
+
+// CloneSlice returns a shallow copy of the slice. It fits for slices
+// of value types. A nil slice stays nil.
+func CloneSlice[T any](s []T) []T {
+	if s == nil {
+		return nil
+	}
+	return append(make([]T, 0, len(s)), s...)
+}
+
+// CloneMap returns a shallow copy of the map. It fits for maps with
+// values of value types. A nil map stays nil.
+func CloneMap[K comparable, V any](m map[K]V) map[K]V {
+	if m == nil {
+		return nil
+	}
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// SmartQuery returns the result of the getter like Get, but calls it
+// directly if the caller already runs inside the Actor. So helpers
+// shared by Actions and other code can query the Actor without the
+// deadlock of sending to it from its own Action.
+func SmartQuery[R any](ctx context.Context, act *Actor, getter func() R) (R, error) {
+	if getter != nil && act.InActor() {
+		return getter(), nil
+	}
+	return Get(ctx, act, getter)
+}
+
+// DoInspect executes the action synchronously and then the inspect
+// function in the same execution, so the returned result and state
+// reflect exactly this one change. The inspect function typically
+// returns a copy of the state owned by the Actor. The copy is shallow,
+// slices, maps, and pointers in it are still shared with the Actor and
+// must be cloned by inspect, e.g. with CloneSlice or CloneMap, if the
+// caller wants to use them.
+func DoInspect[R, S any](ctx context.Context, act *Actor, action func() R, inspect func() S) (R, S, error) {
+	var r R
+	var s S
+	if err := act.admit(ctx, action != nil && inspect != nil); err != nil {
+		return r, s, err
+	}
+	if err := act.DoSyncWithContext(ctx, func() {
+		r = action()
+		s = inspect()
+	}); err != nil {
+		// The action may still be executed later.
+		var zr R
+		var zs S
+		return zr, zs, err
+	}
+	return r, s, nil
+}
+
+// StatesEqual takes snapshots of the states owned by two Actors, e.g.
+// a replica and its source or an Actor and a golden reference, and
+// compares them with eq outside of the Actors. Each snapshot is taken
+// consistently inside its Actor, but one after the other. So changes
+// between the two snapshots are not seen, the comparison is only
+// meaningful for Actors being quiet meanwhile.
+func StatesEqual[S any](
+	ctx context.Context,
+	a *Actor, snapA func() S,
+	b *Actor, snapB func() S,
+	eq func(S, S) bool) (bool, error) {
+	if a == nil || b == nil || eq == nil {
+		return false, fmt.Errorf("%w: nil actor or equality", ErrInvalid)
+	}
+	sa, err := Get(ctx, a, snapA)
+	if err != nil {
+		return false, err
+	}
+	sb, err := Get(ctx, b, snapB)
+	if err != nil {
+		return false, err
+	}
+	return eq(sa, sb), nil
+}
+
+//--------------------
+// PROPERTIES
+//--------------------
+
+// Get executes the field function synchronously and returns its value,
+// typically a field of the state owned by the Actor. Together with Set
+// it forms a minimal typed property API for simple states. Like DoRead
+// the function must not change the state.
+func Get[R any](ctx context.Context, act *Actor, field func() R) (R, error) {
+	var r R
+	if err := act.admitFor(ctx, field != nil, true); err != nil {
+		return r, err
+	}
+	if err := act.DoRead(ctx, func() {
+		r = field()
+	}); err != nil {
+		// The function may still be executed later.
+		var zr R
+		return zr, err
+	}
+	return r, nil
+}
+
+// Set executes the assign function synchronously with the value,
+// typically to set a field of the state owned by the Actor. It is the
+// counterpart of Get.
+func Set[R any](ctx context.Context, act *Actor, assign func(R), value R) error {
+	if err := act.admitFor(ctx, assign != nil, false); err != nil {
+		return err
+	}
+	return act.DoSyncWithContext(ctx, func() {
+		assign(value)
+	})
+}
+
+// DoTx executes the transaction synchronously on a copy of the state
+// made by clone. Only if tx returns nil the copy replaces the state,
+// otherwise the state stays untouched and the error is returned. So
+// changes of several fields are applied all or nothing. The state has
+// to be owned by the Actor and clone has to copy everything tx may
+// change, e.g. with CloneSlice or CloneMap.
+func DoTx[S any](ctx context.Context, act *Actor, state *S, clone func(S) S, tx func(*S) error) error {
+	if err := act.admit(ctx, state != nil && clone != nil && tx != nil); err != nil {
+		return err
+	}
+	var txErr error
+	if err := act.DoSyncWithContext(ctx, func() {
+		draft := clone(*state)
+		if txErr = tx(&draft); txErr != nil {
+			return
+		}
+		*state = draft
+	}); err != nil {
+		return err
+	}
+	return txErr
+}
+
+// Reset sets the state owned by the Actor to the zero value of its
+// type inside a synchronous Action. Unlike stopping the Actor and
+// starting a new one, the Actor keeps running with its queue, options,
+// and metrics, and requests queued before are executed before.
+func Reset[S any](ctx context.Context, act *Actor, state *S) error {
+	var zero S
+	return ResetTo(ctx, act, state, zero)
+}
+
+// ResetTo replaces the state owned by the Actor with the value inside
+// a synchronous Action, like Reset does with the zero value.
+func ResetTo[S any](ctx context.Context, act *Actor, state *S, value S) error {
+	if err := act.admit(ctx, state != nil); err != nil {
+		return err
+	}
+	return act.DoSyncWithContext(ctx, func() {
+		*state = value
+	})
+}
+
+// isReferenceKind tells if values of the kind share their data.
+func isReferenceKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Slice, reflect.Map, reflect.Pointer, reflect.Chan,
+		reflect.Func, reflect.Interface, reflect.UnsafePointer:
+		return true
+	}
+	return false
+}
+
+// Ask sends the request to the target Actor, where the handler computes
+// the response, and waits for it. This way an Action of one Actor may
+// ask another one, as long as both never ask each other at the same
+// time. The context limits the waiting, the handler may still be
+// executed later.
+func Ask[Req, Resp any](ctx context.Context, target *Actor, req Req, handle func(Req) (Resp, error)) (Resp, error) {
+	var resp Resp
+	var herr error
+	if err := target.admit(ctx, handle != nil); err != nil {
+		return resp, err
+	}
+	if err := target.DoSyncWithContext(ctx, func() {
+		resp, herr = handle(req)
+	}); err != nil {
+		var zresp Resp
+		return zresp, err
+	}
+	return resp, herr
+}
+
+// EOF
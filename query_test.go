@@ -0,0 +1,456 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestQueryTuples verifies the typed multi-value queries.
+func TestQueryTuples(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	ctx := context.Background()
+
+	balance := 100
+	holder := "alice"
+	items := make([]int, 0, 8)
+
+	b, h, err := actor.Query2(ctx, act, func() (int, string) {
+		return balance, holder
+	})
+	assert.OK(err)
+	assert.Equal(b, 100)
+	assert.Equal(h, "alice")
+
+	l, c, h, err := actor.Query3(ctx, act, func() (int, int, string) {
+		return len(items), cap(items), holder
+	})
+	assert.OK(err)
+	assert.Equal(l, 0)
+	assert.Equal(c, 8)
+	assert.Equal(h, "alice")
+
+	// Consistency: writers keep both values equal, so every
+	// query must see them equal.
+	x, y := 0, 0
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 500; i++ {
+			act.DoAsync(func() {
+				x++
+				y++
+			})
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		qx, qy, err := actor.Query2(ctx, act, func() (int, int) {
+			return x, y
+		})
+		assert.OK(err)
+		assert.Equal(qx, qy)
+		qx, qy, _, err = actor.Query3(ctx, act, func() (int, int, int) {
+			return x, y, 0
+		})
+		assert.OK(err)
+		assert.Equal(qx, qy)
+	}
+	<-done
+
+	// Timeout propagation.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	b, h, err = actor.Query2(tctx, act, func() (int, string) {
+		return balance, holder
+	})
+	assert.ErrorMatch(err, "action context.*deadline exceeded")
+	assert.Equal(b, 0)
+	assert.Equal(h, "")
+	_, _, _, err = actor.Query3(tctx, act, func() (int, int, string) {
+		return 1, 2, "3"
+	})
+	assert.ErrorMatch(err, "action context.*deadline exceeded")
+	close(release)
+
+	// Shutdown propagation.
+	act.Stop()
+	_, _, err = actor.Query2(ctx, act, func() (int, string) {
+		return balance, holder
+	})
+	assert.True(err == actor.ErrDone)
+	_, _, _, err = actor.Query3(ctx, act, func() (int, int, string) {
+		return 1, 2, "3"
+	})
+	assert.True(err == actor.ErrDone)
+}
+
+// TestDoInspect verifies that the result and the inspected state
+// reflect one single change.
+func TestDoInspect(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	type account struct {
+		Balance int
+		Entries []int
+	}
+	acc := account{Balance: 100}
+	withdraw := func(amount int) func() bool {
+		return func() bool {
+			if acc.Balance < amount {
+				return false
+			}
+			acc.Balance -= amount
+			acc.Entries = append(acc.Entries, -amount)
+			return true
+		}
+	}
+	inspect := func() account {
+		return account{Balance: acc.Balance, Entries: actor.CloneSlice(acc.Entries)}
+	}
+
+	ok, state, err := actor.DoInspect(ctx, act, withdraw(30), inspect)
+	assert.OK(err)
+	assert.True(ok)
+	assert.Equal(state, account{Balance: 70, Entries: []int{-30}})
+
+	ok, state, err = actor.DoInspect(ctx, act, withdraw(100), inspect)
+	assert.OK(err)
+	assert.False(ok)
+	assert.Equal(state.Balance, 70)
+
+	// The cloned entries are owned by the caller.
+	state.Entries[0] = 0
+	_, state, err = actor.DoInspect(ctx, act, func() bool { return true }, inspect)
+	assert.OK(err)
+	assert.Equal(state.Entries, []int{-30})
+
+	_, _, err = actor.DoInspect[bool, account](ctx, act, nil, inspect)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestStatesEqual verifies the comparison of the states of two
+// equal and two divergent Actors.
+func TestStatesEqual(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	ctx := context.Background()
+	source, err := actor.Go()
+	assert.OK(err)
+	defer source.Stop()
+	replica, err := actor.Go()
+	assert.OK(err)
+	defer replica.Stop()
+
+	sourceItems := []string{}
+	replicaItems := []string{}
+	snapSource := func() []string { return actor.CloneSlice(sourceItems) }
+	snapReplica := func() []string { return actor.CloneSlice(replicaItems) }
+	eq := func(a, b []string) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if a[i] != b[i] {
+				return false
+			}
+		}
+		return true
+	}
+	apply := func(item string) {
+		assert.OK(source.DoSync(func() { sourceItems = append(sourceItems, item) }))
+		assert.OK(replica.DoSync(func() { replicaItems = append(replicaItems, item) }))
+	}
+
+	apply("a")
+	apply("b")
+	equal, err := actor.StatesEqual(ctx, source, snapSource, replica, snapReplica, eq)
+	assert.OK(err)
+	assert.True(equal)
+
+	// The replica misses an item.
+	assert.OK(source.DoSync(func() { sourceItems = append(sourceItems, "c") }))
+	equal, err = actor.StatesEqual(ctx, source, snapSource, replica, snapReplica, eq)
+	assert.OK(err)
+	assert.False(equal)
+
+	_, err = actor.StatesEqual(ctx, source, snapSource, replica, snapReplica, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	replica.Stop()
+	_, err = actor.StatesEqual(ctx, source, snapSource, replica, snapReplica, eq)
+	assert.True(errors.Is(err, actor.ErrDone))
+}
+
+// TestAsk verifies asking a worker Actor from the Action of another one.
+func TestAsk(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	worker, err := actor.Go()
+	assert.OK(err)
+	defer worker.Stop()
+	front, err := actor.Go()
+	assert.OK(err)
+	defer front.Stop()
+	ctx := context.Background()
+
+	factor := 3
+	multiply := func(n int) (int, error) {
+		if n < 0 {
+			return 0, errors.New("negative")
+		}
+		return n * factor, nil
+	}
+
+	var resp int
+	var aerr error
+	assert.OK(front.DoSync(func() {
+		resp, aerr = actor.Ask(ctx, worker, 14, multiply)
+	}))
+	assert.OK(aerr)
+	assert.Equal(resp, 42)
+
+	resp, err = actor.Ask(ctx, worker, -1, multiply)
+	assert.ErrorMatch(err, "negative")
+	assert.Equal(resp, 0)
+
+	// Context and lifecycle of the target.
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	assert.OK(worker.DoAsync(func() {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	_, err = actor.Ask(tctx, worker, 1, multiply)
+	assert.ErrorMatch(err, ".*deadline exceeded.*")
+
+	worker.Stop()
+	_, err = actor.Ask(ctx, worker, 1, multiply)
+	assert.True(errors.Is(err, actor.ErrDone))
+	_, err = actor.Ask[int, int](ctx, front, 1, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestQueryCopy verifies that results of QueryCopy are owned by the
+// caller and that reference results need a clone.
+func TestQueryCopy(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	items := []int{1, 2, 3}
+	counts := map[string]int{"a": 1}
+
+	s, err := actor.QueryCopy(ctx, act, func() []int { return items }, actor.CloneSlice[int])
+	assert.OK(err)
+	s[0] = 99
+	s = append(s, 4)
+	s, err = actor.QueryCopy(ctx, act, func() []int { return items }, actor.CloneSlice[int])
+	assert.OK(err)
+	assert.Equal(s, []int{1, 2, 3})
+
+	m, err := actor.QueryCopy(ctx, act, func() map[string]int { return counts }, actor.CloneMap[string, int])
+	assert.OK(err)
+	m["a"] = 99
+	m["b"] = 2
+	m, err = actor.QueryCopy(ctx, act, func() map[string]int { return counts }, actor.CloneMap[string, int])
+	assert.OK(err)
+	assert.Equal(m, map[string]int{"a": 1})
+
+	// Value results need no clone, references do.
+	n, err := actor.QueryCopy(ctx, act, func() int { return len(items) }, nil)
+	assert.OK(err)
+	assert.Equal(n, 3)
+	_, err = actor.QueryCopy(ctx, act, func() []int { return items }, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	_, err = actor.QueryCopy(ctx, act, func() map[string]int { return counts }, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	_, err = actor.QueryCopy(ctx, act, func() *int { return &n }, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+
+	assert.Nil(actor.CloneSlice[int](nil))
+	assert.Nil(actor.CloneMap[string, int](nil))
+
+	act.Stop()
+	_, err = actor.QueryCopy(ctx, act, func() int { return 1 }, nil)
+	assert.True(errors.Is(err, actor.ErrDone))
+}
+
+// TestGetSet verifies the typed property access.
+func TestGetSet(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	type state struct {
+		name  string
+		count int
+	}
+	s := state{name: "one", count: 1}
+
+	name, err := actor.Get(ctx, act, func() string { return s.name })
+	assert.OK(err)
+	assert.Equal(name, "one")
+
+	assert.OK(actor.Set(ctx, act, func(n string) { s.name = n }, "two"))
+	assert.OK(actor.Set(ctx, act, func(c int) { s.count = c }, 2))
+	name, err = actor.Get(ctx, act, func() string { return s.name })
+	assert.OK(err)
+	assert.Equal(name, "two")
+	count, err := actor.Get(ctx, act, func() int { return s.count })
+	assert.OK(err)
+	assert.Equal(count, 2)
+
+	_, err = actor.Get[int](ctx, act, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	err = actor.Set(ctx, act, nil, 1)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+
+	act.Stop()
+	_, err = actor.Get(ctx, act, func() int { return s.count })
+	assert.True(errors.Is(err, actor.ErrDone))
+	err = actor.Set(ctx, act, func(c int) { s.count = c }, 3)
+	assert.True(errors.Is(err, actor.ErrDone))
+}
+
+// TestDoTx verifies that a failing transaction leaves the state
+// untouched while a successful one commits all changes.
+func TestDoTx(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	type transfer struct {
+		From    int
+		To      int
+		Journal map[string]int
+	}
+	state := transfer{From: 100, To: 0, Journal: map[string]int{}}
+	clone := func(s transfer) transfer {
+		s.Journal = actor.CloneMap(s.Journal)
+		return s
+	}
+	errLimit := errors.New("limit exceeded")
+	move := func(amount int) func(*transfer) error {
+		return func(s *transfer) error {
+			s.From -= amount
+			s.To += amount
+			s.Journal["moved"] += amount
+			if s.From < 0 {
+				return errLimit
+			}
+			return nil
+		}
+	}
+
+	assert.OK(actor.DoTx(ctx, act, &state, clone, move(60)))
+	err = actor.DoTx(ctx, act, &state, clone, move(60))
+	assert.True(errors.Is(err, errLimit))
+	assert.OK(act.DoSync(func() {
+		assert.Equal(state.From, 40)
+		assert.Equal(state.To, 60)
+		assert.Equal(state.Journal, map[string]int{"moved": 60})
+	}))
+
+	err = actor.DoTx(ctx, act, &state, nil, move(1))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestReset verifies resetting a state to its zero value and to
+// a given one.
+func TestReset(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	type counter struct {
+		Count int
+		Names []string
+	}
+	state := counter{}
+	assert.OK(act.DoAsync(func() {
+		state.Count = 5
+		state.Names = append(state.Names, "a")
+	}))
+	assert.OK(actor.Reset(ctx, act, &state))
+	c, err := actor.Get(ctx, act, func() counter { return state })
+	assert.OK(err)
+	assert.Equal(c.Count, 0)
+	assert.Nil(c.Names)
+
+	assert.OK(actor.ResetTo(ctx, act, &state, counter{Count: 3}))
+	count, err := actor.Get(ctx, act, func() int { return state.Count })
+	assert.OK(err)
+	assert.Equal(count, 3)
+
+	err = actor.Reset[counter](ctx, act, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestSmartQuery verifies querying from inside and outside the Actor.
+func TestSmartQuery(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	counter := 42
+	count := func() (int, error) {
+		return actor.SmartQuery(ctx, act, func() int { return counter })
+	}
+
+	assert.False(act.InActor())
+	n, err := count()
+	assert.OK(err)
+	assert.Equal(n, 42)
+
+	var inside bool
+	var ierr error
+	assert.OK(act.DoSyncWithContext(ctx, func() {
+		inside = act.InActor()
+		counter++
+		n, ierr = count()
+	}))
+	assert.True(inside)
+	assert.OK(ierr)
+	assert.Equal(n, 43)
+
+	_, err = actor.SmartQuery[int](ctx, act, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
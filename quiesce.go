@@ -0,0 +1,77 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"time"
+)
+
+//--------------------
+// QUIESCE
+//--------------------
+
+// quiescePause is the pause between two rounds of Quiesce.
+const quiescePause = time.Millisecond
+
+// Quiesce waits until a group of interacting Actors settled, e.g. in
+// system tests. In rounds it checks that every Actor handled all
+// requests it received. The group is quiescent when two consecutive
+// rounds find all Actors idle with unchanged counters, so that no
+// Action sent a request to another one in between. Done Actors count
+// as idle. It returns the context error if it ends before.
+func Quiesce(ctx context.Context, actors ...*Actor) error {
+	var last []uint64
+	for {
+		counters, idle := quiesceRound(actors)
+		if idle && equalCounters(last, counters) {
+			return nil
+		}
+		last = counters
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(quiescePause):
+		}
+	}
+}
+
+// quiesceRound reads the counters of all Actors and tells if
+// all are idle.
+func quiesceRound(actors []*Actor) ([]uint64, bool) {
+	counters := make([]uint64, 0, 2*len(actors))
+	idle := true
+	for _, act := range actors {
+		received := act.received.Load()
+		handled := act.handled.Load()
+		if received != handled && !act.IsDone() {
+			idle = false
+		}
+		counters = append(counters, received, handled)
+	}
+	return counters, idle
+}
+
+// equalCounters compares the counters of two rounds.
+func equalCounters(a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// EOF
@@ -0,0 +1,79 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestQuiesce verifies waiting for a ping pong network to settle
+// after the injected traffic stopped.
+func TestQuiesce(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	const nodes = 20
+	const balls = 10
+	const hops = 200
+	actors := make([]*actor.Actor, nodes)
+	for i := range actors {
+		act, err := actor.Go()
+		assert.OK(err)
+		defer act.Stop()
+		actors[i] = act
+	}
+
+	// Each ball bounces between random nodes until its hops are used.
+	var bounced atomic.Int64
+	var bounce func(ttl int)
+	bounce = func(ttl int) {
+		bounced.Add(1)
+		if ttl == 0 {
+			return
+		}
+		next := actors[rand.Intn(nodes)]
+		next.DoAsync(func() {
+			time.Sleep(10 * time.Microsecond)
+			bounce(ttl - 1)
+		})
+	}
+	for i := 0; i < balls; i++ {
+		bounce(hops)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	assert.OK(actor.Quiesce(ctx, actors...))
+	assert.Equal(bounced.Load(), int64(balls*(hops+1)))
+
+	// A never settling network runs into the context timeout.
+	var forever func()
+	forever = func() {
+		actors[rand.Intn(nodes)].DoAsync(forever)
+	}
+	actors[0].DoAsync(forever)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorMatch(actor.Quiesce(ctx, actors...), "context deadline exceeded")
+}
+
+// EOF
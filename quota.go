@@ -0,0 +1,100 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrQuotaExceeded is returned if a request is rejected because its
+// quota key already has its limit of requests in the queue.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+//--------------------
+// QUOTA
+//--------------------
+
+// QuotaKey returns the quota key of a request based on its context,
+// e.g. a tenant ID stored as context value.
+type QuotaKey func(ctx context.Context) string
+
+// quota limits the queued requests per key.
+type quota struct {
+	key          QuotaKey
+	limits       map[string]int
+	defaultLimit int
+	mu           sync.Mutex
+	counts       map[string]int
+}
+
+// QuotaUsage returns the number of queued requests per quota key
+// if WithQuota is set.
+func (act *Actor) QuotaUsage() map[string]int {
+	usage := make(map[string]int)
+	if act.quota == nil {
+		return usage
+	}
+	act.quota.mu.Lock()
+	defer act.quota.mu.Unlock()
+	for key, count := range act.quota.counts {
+		usage[key] = count
+	}
+	return usage
+}
+
+// acquireQuota counts the request for its quota key or rejects it
+// with ErrQuotaExceeded if the key is at its limit.
+func (act *Actor) acquireQuota(req *request) error {
+	q := act.quota
+	if q == nil || req.marker {
+		return nil
+	}
+	key := q.key(req.ctx)
+	limit, ok := q.limits[key]
+	if !ok {
+		limit = q.defaultLimit
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if limit > 0 && q.counts[key] >= limit {
+		return fmt.Errorf("%w: %q", ErrQuotaExceeded, key)
+	}
+	q.counts[key]++
+	ext := req.extension()
+	ext.quotaKey = key
+	ext.quotaCounted = true
+	return nil
+}
+
+// releaseQuota uncounts a request leaving the queue.
+func (act *Actor) releaseQuota(req *request) {
+	if req.ext == nil || !req.ext.quotaCounted {
+		return
+	}
+	q := act.quota
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.counts[req.ext.quotaKey]--
+	if q.counts[req.ext.quotaKey] == 0 {
+		delete(q.counts, req.ext.quotaKey)
+	}
+	req.ext.quotaCounted = false
+}
+
+// EOF
@@ -0,0 +1,78 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// tenantKey is the context key of the tenant.
+type tenantKey struct{}
+
+// tenantCtx returns a context for the tenant.
+func tenantCtx(tenant string) context.Context {
+	return context.WithValue(context.Background(), tenantKey{}, tenant)
+}
+
+// TestQuota verifies that a tenant at its quota is rejected while
+// the other tenants still can queue their requests.
+func TestQuota(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	tenant := func(ctx context.Context) string {
+		s, _ := ctx.Value(tenantKey{}).(string)
+		return s
+	}
+	act, err := actor.Go(actor.WithQuota(tenant, map[string]int{"a": 3}, 0))
+	assert.OK(err)
+	defer act.Stop()
+
+	// Block the Actor, so the Actions stay queued.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	assert.Retry(func() bool { return len(act.QuotaUsage()) == 0 }, 100, time.Millisecond)
+
+	executed := 0
+	count := func() { executed++ }
+	for i := 0; i < 3; i++ {
+		assert.OK(act.DoAsyncWithContext(tenantCtx("a"), count))
+	}
+	err = act.DoAsyncWithContext(tenantCtx("a"), count)
+	assert.True(errors.Is(err, actor.ErrQuotaExceeded))
+
+	// Tenant b has no limit.
+	for i := 0; i < 10; i++ {
+		assert.OK(act.DoAsyncWithContext(tenantCtx("b"), count))
+	}
+	assert.Equal(act.QuotaUsage(), map[string]int{"a": 3, "b": 10})
+
+	// Executing frees the quota again.
+	close(release)
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(executed, 13)
+	assert.Length(act.QuotaUsage(), 0)
+	assert.OK(act.DoAsyncWithContext(tenantCtx("a"), count))
+}
+
+// EOF
@@ -0,0 +1,74 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+)
+
+//--------------------
+// READ VIEW
+//--------------------
+
+// ReadView splits a state into writes serialized by an Actor and reads
+// running in parallel. Updates change the state inside the Actor and
+// then publish a copy of it made by the clone function. View returns
+// the latest published copy without passing the Actor, so reads scale
+// with the number of readers. As the copies are shared by all readers
+// they must not be changed.
+type ReadView[T any] struct {
+	act     *Actor
+	state   T
+	clone   func(T) T
+	current atomic.Pointer[T]
+}
+
+// NewReadView creates a ReadView of the initial state written via the
+// Actor. The clone function has to copy all data a later update may
+// change, e.g. with CloneSlice or CloneMap.
+func NewReadView[T any](act *Actor, initial T, clone func(T) T) (*ReadView[T], error) {
+	if act == nil || clone == nil {
+		return nil, fmt.Errorf("%w: nil actor or clone", ErrInvalid)
+	}
+	rv := &ReadView[T]{
+		act:   act,
+		state: initial,
+		clone: clone,
+	}
+	published := clone(initial)
+	rv.current.Store(&published)
+	return rv, nil
+}
+
+// Update executes the function with the state synchronously inside
+// the Actor and publishes a copy of the changed state. After it
+// returned View reflects the change.
+func (rv *ReadView[T]) Update(ctx context.Context, update func(state *T)) error {
+	if err := rv.act.admit(ctx, update != nil); err != nil {
+		return err
+	}
+	return rv.act.DoSyncWithContext(ctx, func() {
+		update(&rv.state)
+		published := rv.clone(rv.state)
+		rv.current.Store(&published)
+	})
+}
+
+// View returns the latest published state. It may be called
+// concurrently and does not wait for running updates.
+func (rv *ReadView[T]) View() T {
+	return *rv.current.Load()
+}
+
+// EOF
@@ -0,0 +1,131 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// pair is a state whose fields have to stay equal.
+type pair struct {
+	a, b  int
+	items []int
+}
+
+// clonePair copies a pair including its items.
+func clonePair(p pair) pair {
+	p.items = actor.CloneSlice(p.items)
+	return p
+}
+
+// TestReadView verifies read-after-write and consistent
+// views during concurrent updates.
+func TestReadView(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	rv, err := actor.NewReadView(act, pair{}, clonePair)
+	assert.OK(err)
+	assert.Equal(rv.View().a, 0)
+
+	// Read after write.
+	assert.OK(rv.Update(ctx, func(p *pair) {
+		p.a, p.b = 1, 1
+		p.items = append(p.items, 1)
+	}))
+	v := rv.View()
+	assert.Equal(v.a, 1)
+	assert.Equal(v.items, []int{1})
+
+	// Readers never see half updates.
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				v := rv.View()
+				if v.a != v.b || len(v.items) != v.a {
+					t.Errorf("inconsistent view: %+v", v)
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 100; i++ {
+		assert.OK(rv.Update(ctx, func(p *pair) {
+			p.a++
+			p.items = append(p.items, p.a)
+			p.b++
+		}))
+	}
+	close(stop)
+	wg.Wait()
+	assert.Equal(rv.View().a, 101)
+
+	_, err = actor.NewReadView[pair](act, pair{}, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	assert.True(errors.Is(rv.Update(ctx, nil), actor.ErrInvalid))
+	act.Stop()
+	assert.True(errors.Is(rv.Update(ctx, func(p *pair) {}), actor.ErrDone))
+	assert.Equal(rv.View().a, 101)
+}
+
+// BenchmarkReadView measures parallel reads of a ReadView.
+func BenchmarkReadView(b *testing.B) {
+	act, _ := actor.Go()
+	defer act.Stop()
+	rv, _ := actor.NewReadView(act, pair{a: 1, b: 1}, clonePair)
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_ = rv.View().a
+		}
+	})
+}
+
+// BenchmarkReadViaActor measures the same reads passing the Actor
+// for comparison.
+func BenchmarkReadViaActor(b *testing.B) {
+	act, _ := actor.Go()
+	defer act.Stop()
+	ctx := context.Background()
+	p := pair{a: 1, b: 1}
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, _ = actor.Get(ctx, act, func() int { return p.a })
+		}
+	})
+}
+
+// EOF
@@ -0,0 +1,184 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+//--------------------
+// REAPER
+//--------------------
+
+// ReapCleanup is called by a Reaper with the key and the error of a
+// terminated Actor, e.g. to remove it from a Directory.
+type ReapCleanup[K comparable] func(key K, err error)
+
+// ReapRespawn is called by a Reaper after the cleanup of a terminated
+// Actor. A returned Actor is watched under the same key, a nil one
+// or an error ends the watching of the key.
+type ReapRespawn[K comparable] func(key K, err error) (*Actor, error)
+
+// Reaper watches Actors for their termination, e.g. the owners of a
+// Directory or the members of a Pool, so that stopped ones are not
+// referenced anymore. All Actors are watched by one goroutine, not
+// by one per Actor.
+type Reaper[K comparable] struct {
+	mu      sync.Mutex
+	actors  map[K]*Actor
+	cleanup ReapCleanup[K]
+	respawn ReapRespawn[K]
+	changed chan struct{}
+	stopped sync.Once
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewReaper starts a Reaper calling the cleanup for each terminated
+// Actor. The optional respawn replaces the terminated Actor.
+func NewReaper[K comparable](cleanup ReapCleanup[K], respawn ReapRespawn[K]) (*Reaper[K], error) {
+	if cleanup == nil {
+		return nil, fmt.Errorf("%w: nil reaper cleanup", ErrInvalid)
+	}
+	r := &Reaper[K]{
+		actors:  make(map[K]*Actor),
+		cleanup: cleanup,
+		respawn: respawn,
+		changed: make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go r.run()
+	return r, nil
+}
+
+// Watch lets the Reaper watch the Actor under the key. It replaces
+// an Actor already watched under the key without cleaning it up.
+func (r *Reaper[K]) Watch(key K, act *Actor) error {
+	if act == nil {
+		return fmt.Errorf("%w: nil actor", ErrInvalid)
+	}
+	select {
+	case <-r.done:
+		return ErrDone
+	default:
+	}
+	r.mu.Lock()
+	r.actors[key] = act
+	r.mu.Unlock()
+	r.notify()
+	return nil
+}
+
+// Unwatch ends the watching of the key.
+func (r *Reaper[K]) Unwatch(key K) {
+	r.mu.Lock()
+	delete(r.actors, key)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// Len returns the number of watched Actors.
+func (r *Reaper[K]) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.actors)
+}
+
+// Stop ends the watching. The Actors are not affected.
+func (r *Reaper[K]) Stop() {
+	r.stopped.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
+
+// notify signals the goroutine that the watched Actors changed.
+func (r *Reaper[K]) notify() {
+	select {
+	case r.changed <- struct{}{}:
+	default:
+	}
+}
+
+// run waits in one dynamic select for the watched Actors to be done
+// or the set of them to change.
+func (r *Reaper[K]) run() {
+	defer close(r.done)
+	for {
+		keys, cases := r.selectCases()
+		chosen, _, _ := reflect.Select(cases)
+		switch chosen {
+		case 0:
+			return
+		case 1:
+			continue
+		}
+		r.reap(keys[chosen-2], cases[chosen].Chan.Interface().(<-chan struct{}))
+	}
+}
+
+// selectCases returns the keys of the watched Actors and the cases
+// for stopping, changes, and their Done channels in the same order.
+func (r *Reaper[K]) selectCases() ([]K, []reflect.SelectCase) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	keys := make([]K, 0, len(r.actors))
+	cases := make([]reflect.SelectCase, 2, len(r.actors)+2)
+	cases[0] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(r.stop),
+	}
+	cases[1] = reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(r.changed),
+	}
+	for key, act := range r.actors {
+		keys = append(keys, key)
+		cases = append(cases, reflect.SelectCase{
+			Dir:  reflect.SelectRecv,
+			Chan: reflect.ValueOf(act.Done()),
+		})
+	}
+	return keys, cases
+}
+
+// reap cleans up the terminated Actor of the key and possibly
+// respawns it. The key is skipped if it has been changed since.
+func (r *Reaper[K]) reap(key K, done <-chan struct{}) {
+	r.mu.Lock()
+	act, ok := r.actors[key]
+	if !ok || act.Done() != done {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.actors, key)
+	r.mu.Unlock()
+	err := act.Err()
+	r.cleanup(key, err)
+	if r.respawn == nil {
+		return
+	}
+	next, rerr := r.respawn(key, err)
+	if rerr != nil || next == nil {
+		return
+	}
+	r.mu.Lock()
+	if _, ok := r.actors[key]; !ok {
+		r.actors[key] = next
+	}
+	r.mu.Unlock()
+}
+
+// EOF
@@ -0,0 +1,117 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"math/rand"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestReaper verifies that a Reaper cleans up exactly the terminated
+// Actors with a single goroutine.
+func TestReaper(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	actors := make([]*actor.Actor, 1000)
+	for i := range actors {
+		act, err := actor.Go()
+		assert.OK(err)
+		actors[i] = act
+	}
+	defer func() {
+		for _, act := range actors {
+			act.Stop()
+		}
+	}()
+
+	var mu sync.Mutex
+	reaped := make(map[int]error)
+	goroutines := runtime.NumGoroutine()
+	r, err := actor.NewReaper(func(key int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reaped[key] = err
+	}, nil)
+	assert.OK(err)
+	defer r.Stop()
+	for i, act := range actors {
+		assert.OK(r.Watch(i, act))
+	}
+	assert.True(runtime.NumGoroutine() <= goroutines+1)
+
+	killed := make(map[int]bool)
+	for _, i := range rand.Perm(len(actors))[:100] {
+		killed[i] = true
+		actors[i].Stop()
+	}
+	assert.Retry(func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(reaped) == 100
+	}, 100, 10*time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	mu.Lock()
+	assert.Length(reaped, 100)
+	for i, err := range reaped {
+		assert.True(killed[i])
+		assert.NoError(err)
+	}
+	mu.Unlock()
+	assert.Equal(r.Len(), 900)
+	assert.Retry(func() bool {
+		return runtime.NumGoroutine() <= goroutines+1-100
+	}, 100, 10*time.Millisecond)
+}
+
+// TestReaperRespawn verifies replacing terminated Actors.
+func TestReaperRespawn(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	errBoom := errors.New("boom")
+	respawned := make(chan *actor.Actor, 1)
+	r, err := actor.NewReaper(func(key string, err error) {
+		assert.Equal(key, "worker")
+		assert.ErrorContains(err, "boom")
+	}, func(key string, err error) (*actor.Actor, error) {
+		act, err := actor.Go()
+		respawned <- act
+		return act, err
+	})
+	assert.OK(err)
+	defer r.Stop()
+
+	act, err := actor.Go()
+	assert.OK(err)
+	assert.OK(r.Watch("worker", act))
+	assert.OK(act.DoAsync(func() { panic(errBoom) }))
+
+	next := <-respawned
+	defer next.Stop()
+	assert.Retry(func() bool { return r.Len() == 1 }, 100, 10*time.Millisecond)
+
+	r.Stop()
+	assert.True(errors.Is(r.Watch("other", next), actor.ErrDone))
+	_, err = actor.NewReaper[string](nil, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
@@ -0,0 +1,356 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// maxFrameSize is the maximum size of an encoded remote message.
+	maxFrameSize = 16 << 20
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrRemote wraps the errors returned by a remote command, as
+// errors cannot be transported with their identity.
+var ErrRemote = errors.New("remote error")
+
+// ErrRemoteClosed is returned by calls of a RemoteActor whose
+// connection is closed.
+var ErrRemoteClosed = errors.New("remote actor closed")
+
+//--------------------
+// CODEC
+//--------------------
+
+// Codec encodes and decodes the commands and responses sent to
+// and from remote Actors.
+type Codec interface {
+	// Encode encodes the value.
+	Encode(v any) ([]byte, error)
+
+	// Decode decodes the data into the value v points to.
+	Decode(data []byte, v any) error
+}
+
+// JSONCodec is a Codec using encoding/json.
+type JSONCodec struct{}
+
+// Encode implements Codec.
+func (JSONCodec) Encode(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode implements Codec.
+func (JSONCodec) Decode(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// envelope is a remote message on the wire.
+type envelope struct {
+	ID      uint64
+	Name    string
+	Payload []byte
+	Err     string
+}
+
+// writeEnvelope encodes the envelope and writes it length prefixed.
+func writeEnvelope(w io.Writer, codec Codec, env *envelope) error {
+	data, err := codec.Encode(env)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("%w: remote message too large", ErrInvalid)
+	}
+	frame := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(frame, uint32(len(data)))
+	copy(frame[4:], data)
+	_, err = w.Write(frame)
+	return err
+}
+
+// readEnvelope reads a length prefixed envelope and decodes it.
+func readEnvelope(r io.Reader, codec Codec) (*envelope, error) {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("%w: remote message too large", ErrInvalid)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	var env envelope
+	if err := codec.Decode(data, &env); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+//--------------------
+// REMOTE SERVER
+//--------------------
+
+// remoteHandler decodes a command, executes it in the Actor, and
+// encodes the response.
+type remoteHandler func(payload []byte) ([]byte, error)
+
+// RemoteServer serves commands sent by RemoteActors with the handlers
+// registered for their names. As closures cannot be transported the
+// handlers define what a remote peer may let the Actor do.
+type RemoteServer struct {
+	act      *Actor
+	codec    Codec
+	mu       sync.RWMutex
+	handlers map[string]remoteHandler
+}
+
+// NewRemoteServer creates a RemoteServer executing the commands with
+// the Actor. A nil codec uses the JSONCodec.
+func NewRemoteServer(act *Actor, codec Codec) (*RemoteServer, error) {
+	if act == nil {
+		return nil, fmt.Errorf("%w: nil actor", ErrInvalid)
+	}
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &RemoteServer{
+		act:      act,
+		codec:    codec,
+		handlers: make(map[string]remoteHandler),
+	}, nil
+}
+
+// Handle registers the handler for the command name. It is executed
+// inside the Actor of the server.
+func Handle[Cmd, Resp any](srv *RemoteServer, name string, handle func(Cmd) (Resp, error)) error {
+	if name == "" || handle == nil {
+		return fmt.Errorf("%w: invalid remote handler", ErrInvalid)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.handlers[name] = func(payload []byte) ([]byte, error) {
+		var cmd Cmd
+		if err := srv.codec.Decode(payload, &cmd); err != nil {
+			return nil, fmt.Errorf("decoding command %q: %v", name, err)
+		}
+		var resp Resp
+		var herr error
+		if err := srv.act.DoSync(func() {
+			resp, herr = handle(cmd)
+		}); err != nil {
+			return nil, err
+		}
+		if herr != nil {
+			return nil, herr
+		}
+		return srv.codec.Encode(resp)
+	}
+	return nil
+}
+
+// Serve reads the commands from the connection, lets the Actor
+// execute them one after another, and writes the responses. It
+// returns when reading or writing fails, e.g. because the connection
+// has been closed. Reaching its end is no error.
+func (srv *RemoteServer) Serve(rw io.ReadWriter) error {
+	for {
+		env, err := readEnvelope(rw, srv.codec)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		srv.mu.RLock()
+		handler, ok := srv.handlers[env.Name]
+		srv.mu.RUnlock()
+		resp := &envelope{
+			ID: env.ID,
+		}
+		if !ok {
+			resp.Err = fmt.Sprintf("unknown command %q", env.Name)
+		} else if resp.Payload, err = handler(env.Payload); err != nil {
+			resp.Err = err.Error()
+		}
+		if err := writeEnvelope(rw, srv.codec, resp); err != nil {
+			return err
+		}
+	}
+}
+
+//--------------------
+// REMOTE ACTOR
+//--------------------
+
+// RemoteActor is the proxy of an Actor served by a RemoteServer in
+// another process. Use Call to send commands.
+type RemoteActor struct {
+	rw      io.ReadWriter
+	codec   Codec
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	nextID  uint64
+	pending map[uint64]chan *envelope
+	err     error
+}
+
+// NewRemoteActor creates a proxy sending the commands via the
+// connection. A nil codec uses the JSONCodec.
+func NewRemoteActor(rw io.ReadWriter, codec Codec) (*RemoteActor, error) {
+	if rw == nil {
+		return nil, fmt.Errorf("%w: nil connection", ErrInvalid)
+	}
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	ra := &RemoteActor{
+		rw:      rw,
+		codec:   codec,
+		pending: make(map[uint64]chan *envelope),
+	}
+	go ra.receive()
+	return ra, nil
+}
+
+// Call sends the command to the remote Actor and waits for its
+// response within the context. Errors of the remote handler are
+// wrapping ErrRemote.
+func Call[Cmd, Resp any](ctx context.Context, ra *RemoteActor, name string, cmd Cmd) (Resp, error) {
+	var resp Resp
+	if ctx == nil {
+		return resp, fmt.Errorf("%w: nil context", ErrInvalid)
+	}
+	payload, err := ra.codec.Encode(cmd)
+	if err != nil {
+		return resp, fmt.Errorf("encoding command %q: %v", name, err)
+	}
+	id, respc, err := ra.register()
+	if err != nil {
+		return resp, err
+	}
+	defer ra.unregister(id)
+	ra.writeMu.Lock()
+	err = writeEnvelope(ra.rw, ra.codec, &envelope{
+		ID:      id,
+		Name:    name,
+		Payload: payload,
+	})
+	ra.writeMu.Unlock()
+	if err != nil {
+		return resp, err
+	}
+	select {
+	case env, ok := <-respc:
+		if !ok {
+			return resp, ra.closedErr()
+		}
+		if env.Err != "" {
+			return resp, fmt.Errorf("%w: %s", ErrRemote, env.Err)
+		}
+		if err := ra.codec.Decode(env.Payload, &resp); err != nil {
+			return resp, fmt.Errorf("decoding response of %q: %v", name, err)
+		}
+		return resp, nil
+	case <-ctx.Done():
+		return resp, ctx.Err()
+	}
+}
+
+// Close closes the connection if it is an io.Closer and fails
+// the pending calls.
+func (ra *RemoteActor) Close() error {
+	ra.fail(ErrRemoteClosed)
+	if closer, ok := ra.rw.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// register creates a response channel for the next call.
+func (ra *RemoteActor) register() (uint64, chan *envelope, error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.err != nil {
+		return 0, nil, ra.err
+	}
+	ra.nextID++
+	respc := make(chan *envelope, 1)
+	ra.pending[ra.nextID] = respc
+	return ra.nextID, respc, nil
+}
+
+// unregister removes the response channel of a call.
+func (ra *RemoteActor) unregister(id uint64) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	delete(ra.pending, id)
+}
+
+// closedErr returns the error the connection failed with.
+func (ra *RemoteActor) closedErr() error {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	return ra.err
+}
+
+// receive reads the responses and passes them to their calls.
+func (ra *RemoteActor) receive() {
+	for {
+		env, err := readEnvelope(ra.rw, ra.codec)
+		if err != nil {
+			ra.fail(fmt.Errorf("%w: %v", ErrRemoteClosed, err))
+			return
+		}
+		ra.mu.Lock()
+		respc, ok := ra.pending[env.ID]
+		delete(ra.pending, env.ID)
+		ra.mu.Unlock()
+		if ok {
+			respc <- env
+		}
+	}
+}
+
+// fail closes all pending calls and rejects further ones.
+func (ra *RemoteActor) fail(err error) {
+	ra.mu.Lock()
+	defer ra.mu.Unlock()
+	if ra.err != nil {
+		return
+	}
+	ra.err = err
+	for id, respc := range ra.pending {
+		close(respc)
+		delete(ra.pending, id)
+	}
+}
+
+// EOF
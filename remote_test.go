@@ -0,0 +1,97 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestRemoteActor verifies round-tripping commands and responses
+// over an in-memory connection.
+func TestRemoteActor(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	balance := 0
+	srv, err := actor.NewRemoteServer(act, nil)
+	assert.OK(err)
+	assert.OK(actor.Handle(srv, "deposit", func(d Deposit) (Balance, error) {
+		if d.Amount <= 0 {
+			return Balance{}, errors.New("invalid amount")
+		}
+		balance += d.Amount
+		return Balance{Holder: d.Holder, Amount: balance}, nil
+	}))
+	assert.True(errors.Is(actor.Handle[int, int](srv, "", nil), actor.ErrInvalid))
+
+	serverConn, clientConn := net.Pipe()
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(serverConn)
+	}()
+	ra, err := actor.NewRemoteActor(clientConn, nil)
+	assert.OK(err)
+	ctx := context.Background()
+
+	b, err := actor.Call[Deposit, Balance](ctx, ra, "deposit", Deposit{"alice", 10})
+	assert.OK(err)
+	assert.Equal(b, Balance{"alice", 10})
+	b, err = actor.Call[Deposit, Balance](ctx, ra, "deposit", Deposit{"alice", 5})
+	assert.OK(err)
+	assert.Equal(b.Amount, 15)
+
+	_, err = actor.Call[Deposit, Balance](ctx, ra, "deposit", Deposit{"alice", -1})
+	assert.True(errors.Is(err, actor.ErrRemote))
+	assert.ErrorMatch(err, ".*invalid amount")
+	_, err = actor.Call[Deposit, Balance](ctx, ra, "withdraw", Deposit{"alice", 1})
+	assert.ErrorMatch(err, `.*unknown command "withdraw"`)
+
+	assert.OK(ra.Close())
+	_, err = actor.Call[Deposit, Balance](ctx, ra, "deposit", Deposit{"alice", 1})
+	assert.True(errors.Is(err, actor.ErrRemoteClosed))
+	serverConn.Close()
+	<-served
+	assert.OK(act.DoSync(func() {
+		assert.Equal(balance, 15)
+	}))
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// Deposit is a remote command.
+type Deposit struct {
+	Holder string
+	Amount int
+}
+
+// Balance is a remote response.
+type Balance struct {
+	Holder string
+	Amount int
+}
+
+// EOF
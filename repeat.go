@@ -13,51 +13,307 @@ package actor // import "tideland.dev/go/actor"
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
 	"time"
 )
 
+//--------------------
+// REPEAT OPTIONS
+//--------------------
+
+// QueueFraction is the utilization of the Actor queue, from 0.0 for
+// an empty queue up to 1.0 for a full one.
+type QueueFraction float64
+
+// repeatConfig contains the configuration of a repetition.
+type repeatConfig struct {
+	adaptive   bool
+	minimum    time.Duration
+	maximum    time.Duration
+	target     QueueFraction
+	dropIfBusy bool
+}
+
+// RepeatOption defines the signature of a repeat option setting function.
+type RepeatOption func(cfg *repeatConfig) error
+
+// Adaptive lets the repetition adapt its interval to the load of the
+// Actor. After each tick the queue utilization is checked. If it is
+// above the target fraction the interval is doubled, if the queue is
+// empty it is halved, otherwise it is kept. The interval always stays
+// between minimum and maximum.
+func Adaptive(minimum, maximum time.Duration, target QueueFraction) RepeatOption {
+	return func(cfg *repeatConfig) error {
+		if minimum <= 0 || maximum < minimum || target <= 0 || target > 1 {
+			return fmt.Errorf("%w: invalid adaptive repeat", ErrInvalid)
+		}
+		cfg.adaptive = true
+		cfg.minimum = minimum
+		cfg.maximum = maximum
+		cfg.target = target
+		return nil
+	}
+}
+
+// DropIfBusy lets the repetition skip a tick if the queue of the Actor
+// is full instead of waiting for room. This keeps the repetition on
+// its schedule under load and avoids a growing backlog of ticks. The
+// Repeater counts the dropped ticks.
+func DropIfBusy() RepeatOption {
+	return func(cfg *repeatConfig) error {
+		cfg.dropIfBusy = true
+		return nil
+	}
+}
+
+// next computes the interval following the current one.
+func (cfg *repeatConfig) next(act *Actor, current time.Duration) time.Duration {
+	utilization := QueueFraction(act.queued()) / QueueFraction(act.slots.capacity())
+	switch {
+	case utilization > cfg.target:
+		current *= 2
+	case utilization == 0:
+		current /= 2
+	}
+	return cfg.clamp(current)
+}
+
+// clamp keeps the interval between minimum and maximum.
+func (cfg *repeatConfig) clamp(interval time.Duration) time.Duration {
+	switch {
+	case interval < cfg.minimum:
+		return cfg.minimum
+	case interval > cfg.maximum:
+		return cfg.maximum
+	}
+	return interval
+}
+
+//--------------------
+// REPEATER
+//--------------------
+
+// Repeater is the handle of a repeated Action. It allows to stop
+// the repetition and tells why it terminated.
+type Repeater struct {
+	cancel   func()
+	done     chan struct{}
+	err      error
+	interval atomic.Int64
+	dropped  atomic.Uint64
+}
+
+// Interval returns the current interval of the repetition. It
+// only changes for an adaptive one.
+func (r *Repeater) Interval() time.Duration {
+	return time.Duration(r.interval.Load())
+}
+
+// Dropped returns the number of ticks dropped due to a busy Actor
+// with DropIfBusy.
+func (r *Repeater) Dropped() uint64 {
+	return r.dropped.Load()
+}
+
+// Stop terminates the repetition.
+func (r *Repeater) Stop() {
+	r.cancel()
+}
+
+// Done returns a channel that is closed when the repetition
+// terminated.
+func (r *Repeater) Done() <-chan struct{} {
+	return r.done
+}
+
+// Err returns the reason of the termination, nil while still
+// running. It is ErrDone if the Actor has been stopped, the error
+// of the repeat context if it has been canceled or Stop has been
+// called, or the wrapped error of a failed enqueueing.
+func (r *Repeater) Err() error {
+	select {
+	case <-r.done:
+		return r.err
+	default:
+		return nil
+	}
+}
+
 //--------------------
 // REPEAT
 //--------------------
 
 // RepeatWithContext runs an Action in a given interval. It will
 // be done asynchronously until the context is canceled or timeout, the
-// returned stopper function is called or the Actor is stopped.
+// returned function is called or the Actor is stopped. No Actions are
+// enqueued anymore as soon as the Actor is stopping.
 func (act *Actor) RepeatWithContext(
 	ctx context.Context,
 	interval time.Duration,
-	action Action) (func(), error) {
-	if act.Err() != nil {
-		return nil, act.Err()
+	action Action,
+	options ...RepeatOption) (func(), error) {
+	r, err := act.StartRepeaterWithContext(ctx, interval, action, options...)
+	if err != nil {
+		return nil, err
+	}
+	return r.Stop, nil
+}
+
+// Repeat runs an Action in a given interval. It will
+// be done asynchronously until the returned function
+// is called or the Actor is stopped.
+func (act *Actor) Repeat(
+	interval time.Duration,
+	action Action,
+	options ...RepeatOption) (func(), error) {
+	return act.RepeatWithContext(context.Background(), interval, action, options...)
+}
+
+// StartRepeaterWithContext works like RepeatWithContext but returns
+// a Repeater handle. Beside stopping the repetition it tells why it
+// terminated.
+func (act *Actor) StartRepeaterWithContext(
+	ctx context.Context,
+	interval time.Duration,
+	action Action,
+	options ...RepeatOption) (*Repeater, error) {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("%w: non-positive interval", ErrInvalid)
+	}
+	cfg := &repeatConfig{}
+	for _, option := range options {
+		if err := option(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.adaptive {
+		interval = cfg.clamp(interval)
 	}
 	ctx, cancel := context.WithCancel(ctx)
+	r := &Repeater{
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	r.interval.Store(int64(interval))
 	// Goroutine to run the interval.
 	go func() {
+		act.applyLabels()
+		defer close(r.done)
+		defer cancel()
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-act.Done():
+				r.err = ErrDone
 				return
 			case <-ctx.Done():
+				r.err = ctx.Err()
 				return
 			case <-ticker.C:
-				if act.DoAsyncWithContext(ctx, action) != nil {
+				if r.err = act.repeatOnce(ctx, action, cfg, r); r.err != nil {
 					return
 				}
+				if cfg.adaptive {
+					interval = cfg.next(act, interval)
+					r.interval.Store(int64(interval))
+					ticker.Reset(interval)
+				}
 			}
 		}
 	}()
-	return cancel, nil
+	return r, nil
 }
 
-// Repeat runs an Action in a given interval. It will
-// be done asynchronously until the returned stopper function
-// is called or the Actor is stopped.
-func (act *Actor) Repeat(
+// StartRepeater works like Repeat but returns a Repeater handle.
+// Beside stopping the repetition it tells why it terminated.
+func (act *Actor) StartRepeater(
 	interval time.Duration,
-	action Action) (func(), error) {
-	return act.RepeatWithContext(context.Background(), interval, action)
+	action Action,
+	options ...RepeatOption) (*Repeater, error) {
+	return act.StartRepeaterWithContext(context.Background(), interval, action, options...)
+}
+
+// RepeatCollect runs collect in a given interval inside the Actor and
+// streams the results to the returned channel, e.g. to sample values
+// of its state periodically. A slow receiver only gets the latest
+// result. The channel is closed when the returned function is called
+// or the Actor is stopped.
+func RepeatCollect[R any](
+	act *Actor,
+	interval time.Duration,
+	collect func() R,
+	options ...RepeatOption) (<-chan R, func(), error) {
+	if collect == nil {
+		return nil, nil, fmt.Errorf("%w: nil collect", ErrInvalid)
+	}
+	// Only the Actor writes the latest result, so it never blocks.
+	latest := make(chan R, 1)
+	r, err := act.StartRepeater(interval, func() {
+		result := collect()
+		select {
+		case latest <- result:
+		default:
+			select {
+			case <-latest:
+			default:
+			}
+			latest <- result
+		}
+	}, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make(chan R)
+	go func() {
+		defer close(results)
+		for {
+			select {
+			case result := <-latest:
+				select {
+				case results <- result:
+				case <-r.Done():
+					return
+				}
+			case <-r.Done():
+				return
+			}
+		}
+	}()
+	return results, r.Stop, nil
+}
+
+// repeatOnce enqueues the repeated Action and classifies a failure
+// as Actor shutdown, repeat cancelation, or enqueue failure.
+func (act *Actor) repeatOnce(ctx context.Context, action Action, cfg *repeatConfig, r *Repeater) error {
+	if err := act.alive(); err != nil {
+		return ErrDone
+	}
+	var err error
+	if cfg.dropIfBusy {
+		var queued bool
+		queued, err = act.trySend(newActionRequest(ctx, action))
+		if !queued && err == nil {
+			r.dropped.Add(1)
+		}
+	} else {
+		err = act.DoAsyncWithContext(ctx, action)
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, ErrDone) || act.alive() != nil:
+		return ErrDone
+	case ctx.Err() != nil:
+		return ctx.Err()
+	default:
+		return fmt.Errorf("repeat enqueue: %w", err)
+	}
 }
 
 // EOF
@@ -12,6 +12,8 @@ package actor_test
 //--------------------
 
 import (
+	"context"
+	"errors"
 	"testing"
 	"time"
 
@@ -91,4 +93,145 @@ func TestIntervalStopInterval(t *testing.T) {
 	act.Stop()
 }
 
+// TestRepeatAdaptive verifies that an adaptive interval grows while the
+// Actor is busy and shrinks when it is idle again.
+func TestRepeatAdaptive(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	minimum := 2 * time.Millisecond
+	maximum := 32 * time.Millisecond
+	repeater, err := act.StartRepeater(8*time.Millisecond, func() {},
+		actor.Adaptive(minimum, maximum, 0.25))
+	assert.OK(err)
+	assert.Equal(repeater.Interval(), 8*time.Millisecond)
+
+	// Idle Actor lets the interval shrink to the minimum.
+	assert.Retry(func() bool {
+		return repeater.Interval() == minimum
+	}, 100, 5*time.Millisecond)
+
+	// Busy Actor with a queue filled above the target lets
+	// it grow to the maximum.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	for i := 0; i < 128; i++ {
+		assert.OK(act.DoAsync(func() {}))
+	}
+	assert.Retry(func() bool {
+		return repeater.Interval() == maximum
+	}, 100, 5*time.Millisecond)
+
+	// Idle again.
+	close(release)
+	assert.Retry(func() bool {
+		return repeater.Interval() == minimum
+	}, 100, 5*time.Millisecond)
+
+	_, err = act.StartRepeater(time.Millisecond, func() {}, actor.Adaptive(0, time.Second, 0.5))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestRepeatShutdownDuringTicks verifies that stopping the Actor during
+// a burst of ticks is reported as shutdown by the Repeater.
+func TestRepeatShutdownDuringTicks(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	for i := 0; i < 10; i++ {
+		act, err := actor.Go()
+		assert.OK(err)
+		repeater, err := act.StartRepeater(time.Microsecond, func() {})
+		assert.OK(err)
+		assert.NoError(repeater.Err())
+
+		time.Sleep(5 * time.Millisecond)
+		act.Stop()
+
+		<-repeater.Done()
+		assert.True(repeater.Err() == actor.ErrDone, "shutdown, not a generic failure")
+	}
+}
+
+// TestRepeatDropIfBusy verifies that ticks are dropped instead of
+// waiting while the queue of a slow Actor is full.
+func TestRepeatDropIfBusy(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	// Block the Actor, so that the queue fills up.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	repeater, err := act.StartRepeater(50*time.Microsecond, func() {}, actor.DropIfBusy())
+	assert.OK(err)
+
+	full := func() bool {
+		m := act.Metrics()
+		return m.Queued == m.Capacity
+	}
+	assert.Retry(full, 1000, time.Millisecond)
+	assert.Retry(func() bool { return repeater.Dropped() > 0 }, 1000, time.Millisecond)
+	repeater.Stop()
+	<-repeater.Done()
+	assert.True(errors.Is(repeater.Err(), context.Canceled))
+	close(release)
+
+	// Without the option nothing is dropped.
+	repeater, err = act.StartRepeater(time.Millisecond, func() {})
+	assert.OK(err)
+	time.Sleep(5 * time.Millisecond)
+	repeater.Stop()
+	assert.Equal(repeater.Dropped(), uint64(0))
+}
+
+// TestRepeatCollect verifies streaming the collected values of a
+// counter and closing the channel on stop.
+func TestRepeatCollect(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	counter := 0
+	stop, err := act.Repeat(2*time.Millisecond, func() {
+		counter++
+	})
+	assert.OK(err)
+	defer stop()
+	values, stopCollect, err := actor.RepeatCollect(act, 5*time.Millisecond, func() int {
+		return counter
+	})
+	assert.OK(err)
+
+	first := <-values
+	last := first
+	for i := 0; i < 5; i++ {
+		value := <-values
+		assert.True(value >= last, "counter does not decrease")
+		last = value
+	}
+	assert.True(last > first, "counter increased")
+	stopCollect()
+	for range values {
+	}
+
+	// Stopping the Actor closes the channel too.
+	values, _, err = actor.RepeatCollect(act, 5*time.Millisecond, func() int {
+		return counter
+	})
+	assert.OK(err)
+	<-values
+	act.Stop()
+	for range values {
+	}
+
+	_, _, err = actor.RepeatCollect[int](act, time.Millisecond, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
 // EOF
@@ -0,0 +1,86 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+//--------------------
+// RETRY
+//--------------------
+
+// DoRetryEscalate executes the action synchronously up to attempts
+// times until it succeeds. Each attempt gets a context with the
+// timeout of the same index, so a fast first try can be followed by
+// more patient retries. If there are less timeouts than attempts the
+// last one is used for the remaining. The timeout covers queueing and
+// execution, the action should watch its context as it cannot be
+// aborted. The retrying stops when the context ends or the Actor is
+// done. Otherwise the error of the last attempt is returned.
+func (act *Actor) DoRetryEscalate(
+	ctx context.Context,
+	action func(ctx context.Context) error,
+	attempts int,
+	timeouts []time.Duration,
+) error {
+	if err := act.admit(ctx, action != nil); err != nil {
+		return err
+	}
+	if attempts < 1 || len(timeouts) == 0 {
+		return fmt.Errorf("%w: invalid retry attempts or timeouts", ErrInvalid)
+	}
+	for _, timeout := range timeouts {
+		if timeout <= 0 {
+			return fmt.Errorf("%w: non-positive retry timeout", ErrInvalid)
+		}
+	}
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		timeout := timeouts[len(timeouts)-1]
+		if attempt < len(timeouts) {
+			timeout = timeouts[attempt]
+		}
+		if err = act.attempt(ctx, action, timeout); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if aerr := act.alive(); aerr != nil {
+			return aerr
+		}
+	}
+	return err
+}
+
+// attempt executes the action once with the timeout.
+func (act *Actor) attempt(ctx context.Context, action func(ctx context.Context) error, timeout time.Duration) error {
+	actx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var err error
+	req := newRequest(actx, func(ctx context.Context) {
+		err = action(ctx)
+	})
+	req.origin = action
+	if serr := act.sendSync(req); serr != nil {
+		return serr
+	}
+	if werr := act.wait(req); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// EOF
@@ -0,0 +1,79 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDoRetryEscalate verifies retrying with escalating timeouts.
+func TestDoRetryEscalate(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+	ctx := context.Background()
+
+	// The action needs 20 milliseconds, so only the second attempt
+	// has enough patience.
+	attempts := 0
+	slow := func(ctx context.Context) error {
+		attempts++
+		select {
+		case <-time.After(20 * time.Millisecond):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	timeouts := []time.Duration{5 * time.Millisecond, time.Second}
+	assert.OK(act.DoRetryEscalate(ctx, slow, 3, timeouts))
+	assert.OK(act.DoSync(func() {}))
+	assert.Equal(attempts, 2)
+
+	// Exhausted attempts return the last error, the last
+	// timeout is used for the remaining attempts.
+	errFailed := errors.New("failed")
+	attempts = 0
+	err = act.DoRetryEscalate(ctx, func(ctx context.Context) error {
+		attempts++
+		return errFailed
+	}, 3, timeouts[:1])
+	assert.True(errors.Is(err, errFailed))
+	assert.Equal(attempts, 3)
+
+	// Canceled context stops retrying.
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = act.DoRetryEscalate(cctx, slow, 10, timeouts)
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+
+	err = act.DoRetryEscalate(ctx, slow, 0, timeouts)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	err = act.DoRetryEscalate(ctx, slow, 1, nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	err = act.DoRetryEscalate(ctx, slow, 1, []time.Duration{0})
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
@@ -0,0 +1,163 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"strings"
+)
+
+//--------------------
+// SAGA
+//--------------------
+
+// Saga supports multi-step operations which have to look atomic to the
+// caller. Each step is executed as Action together with a compensation
+// undoing its effect. If a step fails, the compensations of all former
+// steps are executed in reverse order within one Action. A Saga is not
+// safe for concurrent use.
+type Saga struct {
+	act           *Actor
+	compensations []func()
+}
+
+// NewSaga starts a Saga executing its steps on the Actor.
+func (act *Actor) NewSaga() *Saga {
+	return &Saga{
+		act: act,
+	}
+}
+
+// DoCompensated executes the action synchronously. If it succeeds the
+// compensation is kept for a later rollback. If it fails, the Saga is
+// rolled back and the error of the action is returned.
+func (s *Saga) DoCompensated(action func() error, compensate func()) error {
+	if action == nil || compensate == nil {
+		return fmt.Errorf("%w: nil saga step", ErrInvalid)
+	}
+	var aerr error
+	if err := s.act.DoSync(func() {
+		aerr = action()
+	}); err != nil {
+		return err
+	}
+	if aerr != nil {
+		if err := s.Rollback(); err != nil {
+			return fmt.Errorf("saga step failed: %v; rollback failed: %w", aerr, err)
+		}
+		return aerr
+	}
+	s.compensations = append(s.compensations, compensate)
+	return nil
+}
+
+// Rollback executes the compensations of all successful steps in
+// reverse order within one Action.
+func (s *Saga) Rollback() error {
+	compensations := s.compensations
+	s.compensations = nil
+	if len(compensations) == 0 {
+		return nil
+	}
+	return s.act.DoSync(func() {
+		for i := len(compensations) - 1; i >= 0; i-- {
+			compensations[i]()
+		}
+	})
+}
+
+// Complete ends the Saga successfully and drops the compensations.
+func (s *Saga) Complete() {
+	s.compensations = nil
+}
+
+//--------------------
+// COMPENSATED STEPS
+//--------------------
+
+// Step is one step of a compensated Action. Apply changes the state,
+// Undo reverts this change if a later step fails.
+type Step struct {
+	Apply func() error
+	Undo  func() error
+}
+
+// StepError is returned by DoCompensated if a step failed. It tells
+// which step failed and if the former steps could be undone.
+type StepError struct {
+	// Step is the index of the failed step.
+	Step int
+
+	// Err is the error of the failed step.
+	Err error
+
+	// UndoErrs contains the errors of the failed Undo functions.
+	UndoErrs []error
+}
+
+// Compensated returns true if all former steps have been undone.
+func (e *StepError) Compensated() bool {
+	return len(e.UndoErrs) == 0
+}
+
+// Error implements the error interface.
+func (e *StepError) Error() string {
+	msg := fmt.Sprintf("step %d failed: %v", e.Step, e.Err)
+	if e.Compensated() {
+		return msg
+	}
+	undos := make([]string, len(e.UndoErrs))
+	for i, err := range e.UndoErrs {
+		undos[i] = err.Error()
+	}
+	return fmt.Sprintf("%s; undo failed: %s", msg, strings.Join(undos, "; "))
+}
+
+// Unwrap returns the error of the failed step.
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// DoCompensated executes the steps within one Action. If a step fails,
+// the Undo functions of all former steps are executed in reverse order
+// before any other Action, so the state never looks half changed. The
+// returned StepError tells which step failed and if all Undos
+// succeeded.
+func (act *Actor) DoCompensated(steps []Step) error {
+	for i, step := range steps {
+		if step.Apply == nil || step.Undo == nil {
+			return fmt.Errorf("%w: nil function in step %d", ErrInvalid, i)
+		}
+	}
+	var serr *StepError
+	if err := act.DoSync(func() {
+		for i, step := range steps {
+			if err := step.Apply(); err != nil {
+				serr = &StepError{Step: i, Err: err}
+				for j := i - 1; j >= 0; j-- {
+					if uerr := steps[j].Undo(); uerr != nil {
+						serr.UndoErrs = append(serr.UndoErrs, uerr)
+					}
+				}
+				return
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	if serr != nil {
+		return serr
+	}
+	return nil
+}
+
+// EOF
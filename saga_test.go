@@ -0,0 +1,176 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSagaRollback verifies that a failing third step compensates
+// the first two in reverse order.
+func TestSagaRollback(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	balance := 100
+	log := []string{}
+	saga := act.NewSaga()
+
+	assert.OK(saga.DoCompensated(func() error {
+		balance -= 30
+		log = append(log, "debit")
+		return nil
+	}, func() {
+		balance += 30
+		log = append(log, "undo debit")
+	}))
+	assert.OK(saga.DoCompensated(func() error {
+		balance -= 5
+		log = append(log, "fee")
+		return nil
+	}, func() {
+		balance += 5
+		log = append(log, "undo fee")
+	}))
+	err = saga.DoCompensated(func() error {
+		return errors.New("credit rejected")
+	}, func() {
+		log = append(log, "undo credit")
+	})
+	assert.ErrorMatch(err, "credit rejected")
+
+	assert.OK(act.DoSync(func() {
+		assert.Equal(balance, 100)
+		assert.Equal(log, []string{"debit", "fee", "undo fee", "undo debit"})
+	}))
+}
+
+// TestSagaComplete verifies that a completed Saga is not rolled back.
+func TestSagaComplete(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	value := 0
+	saga := act.NewSaga()
+	assert.OK(saga.DoCompensated(func() error {
+		value = 1
+		return nil
+	}, func() {
+		value = 0
+	}))
+	saga.Complete()
+	assert.OK(saga.Rollback())
+	assert.OK(act.DoSync(func() {
+		assert.Equal(value, 1)
+	}))
+}
+
+// TestDoCompensated verifies the all-success path and the undoing of
+// the former steps for a failure at each position.
+func TestDoCompensated(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	errFail := errors.New("fail")
+	var log []string
+	steps := func(failing int) []actor.Step {
+		var steps []actor.Step
+		for i := 0; i < 3; i++ {
+			i := i
+			steps = append(steps, actor.Step{
+				Apply: func() error {
+					if i == failing {
+						return errFail
+					}
+					log = append(log, fmt.Sprintf("apply-%d", i))
+					return nil
+				},
+				Undo: func() error {
+					log = append(log, fmt.Sprintf("undo-%d", i))
+					return nil
+				},
+			})
+		}
+		return steps
+	}
+
+	assert.OK(act.DoCompensated(steps(-1)))
+	assert.Equal(log, []string{"apply-0", "apply-1", "apply-2"})
+
+	expected := [][]string{
+		{},
+		{"apply-0", "undo-0"},
+		{"apply-0", "apply-1", "undo-1", "undo-0"},
+	}
+	for failing := 0; failing < 3; failing++ {
+		log = []string{}
+		err := act.DoCompensated(steps(failing))
+		var serr *actor.StepError
+		assert.True(errors.As(err, &serr))
+		assert.True(errors.Is(err, errFail))
+		assert.Equal(serr.Step, failing)
+		assert.True(serr.Compensated())
+		assert.Equal(log, expected[failing])
+	}
+
+	err = act.DoCompensated([]actor.Step{{Apply: func() error { return nil }}})
+	assert.ErrorMatch(err, ".*invalid argument.*")
+}
+
+// TestDoCompensatedUndoFails verifies that failing Undos are reported
+// while the remaining ones are still executed.
+func TestDoCompensatedUndoFails(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	balance := 100
+	ledger := []int{}
+	undone := false
+	err = act.DoCompensated([]actor.Step{{
+		Apply: func() error { balance -= 30; return nil },
+		Undo:  func() error { balance += 30; undone = true; return nil },
+	}, {
+		Apply: func() error { ledger = append(ledger, -30); return nil },
+		Undo:  func() error { return errors.New("ledger locked") },
+	}, {
+		Apply: func() error { return errors.New("notification failed") },
+		Undo:  func() error { return nil },
+	}})
+	var serr *actor.StepError
+	assert.True(errors.As(err, &serr))
+	assert.Equal(serr.Step, 2)
+	assert.False(serr.Compensated())
+	assert.Length(serr.UndoErrs, 1)
+	assert.Equal(err.Error(), "step 2 failed: notification failed; undo failed: ledger locked")
+	assert.True(undone)
+	assert.Equal(balance, 100)
+}
+
+// EOF
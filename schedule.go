@@ -0,0 +1,81 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+//--------------------
+// SCHEDULE
+//--------------------
+
+// defaultScheduleCadence is the default interval DoAt re-evaluates
+// the wall clock in.
+const defaultScheduleCadence = time.Second
+
+// DoAt sends the Action asynchronously when the wall clock reaches
+// the time. Instead of one long timer the remaining time is evaluated
+// again at least in the cadence set with WithScheduleCadence. So the
+// Action is sent within the cadence of the time, even if the wall
+// clock is stepped meanwhile, e.g. by NTP. A time in the past sends
+// it immediately. The returned function cancels it if it has not been
+// sent yet. Actions still waiting when the Actor stops are dropped.
+func (act *Actor) DoAt(at time.Time, action Action) (func(), error) {
+	if err := act.admit(context.Background(), action != nil); err != nil {
+		return nil, err
+	}
+	// Compare wall clock readings only.
+	at = at.Round(0)
+	cadence := act.scheduleCadence
+	if cadence <= 0 {
+		cadence = defaultScheduleCadence
+	}
+	now := act.wallClock
+	if now == nil {
+		now = time.Now
+	}
+	canceled := make(chan struct{})
+	var once sync.Once
+	go func() {
+		act.applyLabels()
+		for {
+			wait := at.Sub(now().Round(0))
+			if wait <= 0 {
+				_ = act.DoAsync(action)
+				return
+			}
+			if wait > cadence {
+				wait = cadence
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-canceled:
+				timer.Stop()
+				return
+			case <-act.done:
+				timer.Stop()
+				return
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() {
+			close(canceled)
+		})
+	}, nil
+}
+
+// EOF
@@ -0,0 +1,102 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+	"tideland.dev/go/actor/actortest"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDoAtClockStep verifies that DoAt follows a stepped wall clock.
+func TestDoAtClockStep(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	clock := actortest.NewClock()
+	cadence := 10 * time.Millisecond
+	act, err := actor.Go(actor.WithWallClock(clock.Now), actor.WithScheduleCadence(cadence))
+	assert.OK(err)
+	defer act.Stop()
+
+	fired := make(chan time.Time, 1)
+	_, err = act.DoAt(clock.Now().Add(time.Hour), func() {
+		fired <- clock.Now()
+	})
+	assert.OK(err)
+
+	// Step forward, so that 50 milliseconds remain.
+	time.Sleep(3 * cadence)
+	remaining := 50 * time.Millisecond
+	clock.Step(time.Hour - 3*cadence - remaining)
+	stepped := time.Now()
+	select {
+	case <-fired:
+		elapsed := time.Since(stepped)
+		assert.True(elapsed >= remaining-cadence, "not early")
+		assert.True(elapsed <= remaining+5*cadence, "within cadence")
+	case <-time.After(time.Second):
+		assert.Fail("action not fired")
+	}
+
+	// Stepping beyond the time fires within the cadence.
+	_, err = act.DoAt(clock.Now().Add(time.Hour), func() {
+		fired <- clock.Now()
+	})
+	assert.OK(err)
+	clock.Step(2 * time.Hour)
+	stepped = time.Now()
+	select {
+	case <-fired:
+		assert.True(time.Since(stepped) <= 5*cadence, "within cadence")
+	case <-time.After(time.Second):
+		assert.Fail("action not fired")
+	}
+}
+
+// TestDoAtCancel verifies canceling a scheduled Action.
+func TestDoAtCancel(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithScheduleCadence(5 * time.Millisecond))
+	assert.OK(err)
+	defer act.Stop()
+
+	fired := make(chan struct{}, 2)
+	cancel, err := act.DoAt(time.Now().Add(20*time.Millisecond), func() {
+		fired <- struct{}{}
+	})
+	assert.OK(err)
+	cancel()
+	cancel()
+	_, err = act.DoAt(time.Now().Add(-time.Second), func() {
+		fired <- struct{}{}
+	})
+	assert.OK(err)
+	time.Sleep(50 * time.Millisecond)
+	assert.Length(fired, 1)
+
+	_, err = act.DoAt(time.Now(), nil)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	_, err = actor.Go(actor.WithScheduleCadence(0))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+	_, err = actor.Go(actor.WithWallClock(nil))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
@@ -0,0 +1,74 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor/actortest"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSequence verifies that the sequences returned to concurrent
+// producers match the order the Actor applied their Actions.
+func TestSequence(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, rec := actortest.NewTestActor(t)
+
+	type change struct {
+		producer int
+		n        int
+	}
+	const producers = 5
+	const changes = 50
+	applied := []change{}
+	returned := make([][]uint64, producers)
+
+	var wg sync.WaitGroup
+	wg.Add(producers)
+	for p := 0; p < producers; p++ {
+		go func(p int) {
+			defer wg.Done()
+			for n := 0; n < changes; n++ {
+				c := change{p, n}
+				seq, err := act.DoSyncSequenced(context.Background(), func() {
+					applied = append(applied, c)
+				})
+				assert.OK(err)
+				returned[p] = append(returned[p], seq)
+			}
+		}(p)
+	}
+	wg.Wait()
+
+	assert.Equal(act.Sequence(), uint64(producers*changes))
+	assert.Length(applied, producers*changes)
+	recorded := rec.Actions()
+	assert.Length(recorded, producers*changes)
+	for i, e := range recorded {
+		assert.Equal(e.Sequence, uint64(i+1))
+	}
+	for p := 0; p < producers; p++ {
+		for n, seq := range returned[p] {
+			assert.Equal(applied[seq-1], change{p, n})
+		}
+	}
+}
+
+// EOF
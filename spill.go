@@ -0,0 +1,338 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+//--------------------
+// CONSTANTS
+//--------------------
+
+const (
+	// spillFileName is the name of the overflow file in the
+	// spill directory.
+	spillFileName = "overflow.spill"
+
+	// spillHeaderSize is the size of the spill file header
+	// containing the offset of the first not executed record.
+	spillHeaderSize = 8
+)
+
+//--------------------
+// OVERFLOW SPILL
+//--------------------
+
+// Command is an Action as serializable value, so it can be spilled
+// to disk when the queue is full.
+type Command interface {
+	Apply()
+}
+
+// CommandEncoder encodes a Command for spilling it.
+type CommandEncoder func(cmd Command) ([]byte, error)
+
+// CommandDecoder decodes a spilled Command.
+type CommandDecoder func(data []byte) (Command, error)
+
+// spill stores the overflowing Commands in a file. Records between
+// doneOff and readOff are replayed but not yet executed. The pending
+// ones are those not yet queued, including the one being sent.
+type spill struct {
+	mu         sync.Mutex
+	file       *os.File
+	encode     CommandEncoder
+	decode     CommandDecoder
+	doneOff    int64
+	readOff    int64
+	writeOff   int64
+	pending    int
+	notify     chan struct{}
+	replayDone chan struct{}
+}
+
+// spillConfig keeps the settings of WithOverflowSpill until the
+// Actor is started.
+type spillConfig struct {
+	dir    string
+	encode CommandEncoder
+	decode CommandDecoder
+}
+
+// startSpill opens the spill file configured with WithOverflowSpill
+// and starts replaying it once the backend is running.
+func (act *Actor) startSpill() error {
+	cfg := act.spillConfig
+	if cfg == nil {
+		return nil
+	}
+	if err := os.MkdirAll(cfg.dir, 0o700); err != nil {
+		return fmt.Errorf("creating spill directory: %v", err)
+	}
+	file, err := os.OpenFile(filepath.Join(cfg.dir, spillFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return fmt.Errorf("opening spill file: %v", err)
+	}
+	s := &spill{
+		file:       file,
+		encode:     cfg.encode,
+		decode:     cfg.decode,
+		notify:     make(chan struct{}, 1),
+		replayDone: make(chan struct{}),
+	}
+	if err := s.scan(); err != nil {
+		file.Close()
+		return err
+	}
+	closer := func() error {
+		// The replay ends with the Actor, but may still
+		// read the file.
+		<-s.replayDone
+		return file.Close()
+	}
+	if err := act.Defer(closer); err != nil {
+		file.Close()
+		return err
+	}
+	act.spill.Store(s)
+	go act.replaySpill(s)
+	return nil
+}
+
+// DoCommand sends the Command to the backend and returns when it's
+// queued or spilled. Without WithOverflowSpill it works like DoAsync.
+func (act *Actor) DoCommand(cmd Command) error {
+	if err := act.admit(context.Background(), cmd != nil); err != nil {
+		return err
+	}
+	s := act.spill.Load()
+	if s == nil {
+		return act.DoAsync(cmd.Apply)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == 0 {
+		queued, err := act.trySend(newActionRequest(context.Background(), cmd.Apply))
+		if err != nil || queued {
+			return err
+		}
+	}
+	data, err := s.encode(cmd)
+	if err != nil {
+		return fmt.Errorf("encoding command: %v", err)
+	}
+	if len(data) > maxFrameSize {
+		return fmt.Errorf("%w: command too large", ErrInvalid)
+	}
+	record := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(record, uint32(len(data)))
+	copy(record[4:], data)
+	if _, err := s.file.WriteAt(record, s.writeOff); err != nil {
+		return fmt.Errorf("spilling command: %v", err)
+	}
+	s.writeOff += int64(len(record))
+	s.pending++
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// SpilledLen returns the number of Commands waiting in the spill file.
+func (act *Actor) SpilledLen() int {
+	s := act.spill.Load()
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pending
+}
+
+// replaySpill sends the spilled Commands to the backend until the
+// Actor is done. Each replayed Command records its progress after
+// its execution.
+func (act *Actor) replaySpill(s *spill) {
+	defer close(s.replayDone)
+	for {
+		data, end, ok := s.next()
+		if !ok {
+			select {
+			case <-s.notify:
+				continue
+			case <-act.done:
+				return
+			}
+		}
+		// A broken record cannot be applied, but its progress is
+		// recorded in order with the others.
+		cmd, err := s.decode(data)
+		if err != nil {
+			cmd = nil
+		}
+		replay := func() {
+			if cmd != nil {
+				cmd.Apply()
+			}
+			s.executed(end)
+		}
+		if act.send(newActionRequest(context.Background(), replay)) != nil {
+			return
+		}
+		s.queued()
+	}
+}
+
+// next reads the oldest pending record and returns it with its
+// end offset. It stays pending until it is queued, so DoCommand
+// keeps spilling instead of overtaking it.
+func (s *spill) next() ([]byte, int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.readOff == s.writeOff {
+		return nil, 0, false
+	}
+	data, err := readRecord(s.file, s.readOff)
+	if err != nil {
+		// Drop the unreadable rest.
+		s.pending = 0
+		if s.file.Truncate(s.readOff) == nil {
+			s.writeOff = s.readOff
+		}
+		return nil, 0, false
+	}
+	s.readOff += int64(4 + len(data))
+	return data, s.readOff, true
+}
+
+// queued marks the record returned by next as queued. The file is
+// emptied if it already has been executed as the last one.
+func (s *spill) queued() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending--
+	if s.pending == 0 && s.doneOff == s.writeOff {
+		s.reset()
+	}
+}
+
+// executed persists the progress after the record ending at the
+// offset has been executed. If no other one is left the file is
+// emptied. It is called inside the backend, so errors only lead
+// to replaying the records again.
+func (s *spill) executed(end int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.doneOff = end
+	if s.pending == 0 && s.doneOff == s.writeOff {
+		if s.reset() == nil {
+			return
+		}
+	}
+	s.writeHeader(end)
+}
+
+// reset empties the file.
+func (s *spill) reset() error {
+	if err := s.file.Truncate(spillHeaderSize); err != nil {
+		return err
+	}
+	if err := s.writeHeader(spillHeaderSize); err != nil {
+		return err
+	}
+	s.doneOff = spillHeaderSize
+	s.readOff = spillHeaderSize
+	s.writeOff = spillHeaderSize
+	s.pending = 0
+	return nil
+}
+
+// writeHeader stores the offset of the first not executed record.
+func (s *spill) writeHeader(off int64) error {
+	var header [spillHeaderSize]byte
+	binary.BigEndian.PutUint64(header[:], uint64(off))
+	_, err := s.file.WriteAt(header[:], 0)
+	return err
+}
+
+// scan restores the progress and counts the records of an existing
+// spill file. A partially written last record is cut off. A missing
+// or invalid header, e.g. after an interrupted reset, empties it.
+func (s *spill) scan() error {
+	var header [spillHeaderSize]byte
+	if _, err := s.file.ReadAt(header[:], 0); err != nil {
+		if err != io.EOF {
+			return fmt.Errorf("reading spill file header: %v", err)
+		}
+		return s.reset()
+	}
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("reading spill file info: %v", err)
+	}
+	off := int64(binary.BigEndian.Uint64(header[:]))
+	if off < spillHeaderSize || off > info.Size() {
+		return s.reset()
+	}
+	s.doneOff = off
+	s.readOff = off
+	s.writeOff = off
+	for {
+		data, err := readRecord(s.file, s.writeOff)
+		if err == io.EOF {
+			return nil
+		}
+		if err == io.ErrUnexpectedEOF {
+			return s.file.Truncate(s.writeOff)
+		}
+		if err != nil {
+			return fmt.Errorf("scanning spill file: %v", err)
+		}
+		s.writeOff += int64(4 + len(data))
+		s.pending++
+	}
+}
+
+// readRecord reads the length prefixed record at the offset. It
+// returns io.EOF at the end and io.ErrUnexpectedEOF for a partially
+// written record.
+func readRecord(r io.ReaderAt, off int64) ([]byte, error) {
+	var size [4]byte
+	if n, err := r.ReadAt(size[:], off); err != nil {
+		if err == io.EOF && n > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > maxFrameSize {
+		return nil, fmt.Errorf("spill record too large: %d", n)
+	}
+	data := make([]byte, n)
+	if _, err := r.ReadAt(data, off+4); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// EOF
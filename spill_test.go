@@ -0,0 +1,187 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestOverflowSpill verifies that Commands overflowing the queue are
+// spilled to disk and replayed in order.
+func TestOverflowSpill(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	ledger := &ledger{}
+	act, err := actor.Go(actor.WithOverflowSpill(dir, encodeEntry, ledger.decode))
+	assert.OK(err)
+	defer act.Stop()
+
+	// Block the Actor, so the queue fills up.
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	const commands = 400
+	for i := 0; i < commands; i++ {
+		assert.OK(act.DoCommand(&entry{ledger, i}))
+	}
+	spilled := act.SpilledLen()
+	assert.True(spilled > 0)
+	assert.True(spilled < commands)
+
+	// Commands sent during the replay must not overtake it.
+	close(release)
+	for i := commands; i < 2*commands; i++ {
+		assert.OK(act.DoCommand(&entry{ledger, i}))
+	}
+	assert.Retry(func() bool { return act.SpilledLen() == 0 }, 100, 10*time.Millisecond)
+	assert.OK(act.DoSync(func() {
+		assert.Length(ledger.amounts, 2*commands)
+		for i, amount := range ledger.amounts {
+			assert.Equal(amount, i)
+		}
+	}))
+}
+
+// TestOverflowSpillRestart verifies that Commands still spilled when
+// an Actor stops are replayed by the next one.
+func TestOverflowSpillRestart(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	old := &ledger{}
+	act, err := actor.Go(
+		actor.WithQueueCap(256),
+		actor.WithOverflowSpill(dir, encodeEntry, old.decode),
+	)
+	assert.OK(err)
+
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	for i := 0; i < 300; i++ {
+		assert.OK(act.DoCommand(&entry{old, i}))
+	}
+	spilled := act.SpilledLen()
+	assert.True(spilled > 0)
+	act.Stop()
+	close(release)
+	<-act.Done()
+
+	ledger := &ledger{}
+	next, err := actor.Go(actor.WithOverflowSpill(dir, encodeEntry, ledger.decode))
+	assert.OK(err)
+	defer next.Stop()
+	assert.Retry(func() bool { return next.SpilledLen() == 0 }, 100, 10*time.Millisecond)
+	assert.OK(next.DoSync(func() {
+		assert.Length(ledger.amounts, spilled)
+		assert.Equal(ledger.amounts[0], 300-spilled)
+	}))
+}
+
+// TestOverflowSpillQueuedReplay verifies that replayed Commands still
+// queued when an Actor stops are replayed by the next one.
+func TestOverflowSpillQueuedReplay(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	dir := t.TempDir()
+	old := &ledger{}
+	act, err := actor.Go(actor.WithOverflowSpill(dir, encodeEntry, old.decode))
+	assert.OK(err)
+
+	// Fill the queue behind a blocked Action, so the Commands are
+	// spilled, and block a second time behind the filling.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	for act.Metrics().Queued < act.Metrics().Capacity-1 {
+		assert.OK(act.DoAsync(func() {}))
+	}
+	gate := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		<-gate
+	}))
+	const commands = 10
+	for i := 0; i < commands; i++ {
+		assert.OK(act.DoCommand(&entry{old, i}))
+	}
+	assert.Equal(act.SpilledLen(), commands)
+
+	// Let the replay queue all Commands, but stop before they
+	// are executed.
+	close(release)
+	assert.Retry(func() bool { return act.SpilledLen() == 0 }, 100, 10*time.Millisecond)
+	act.Stop()
+	close(gate)
+	<-act.Done()
+
+	ledger := &ledger{}
+	next, err := actor.Go(actor.WithOverflowSpill(dir, encodeEntry, ledger.decode))
+	assert.OK(err)
+	defer next.Stop()
+	assert.Retry(func() bool { return next.SpilledLen() == 0 }, 100, 10*time.Millisecond)
+	assert.OK(next.DoSync(func() {
+		assert.Length(ledger.amounts, commands)
+		for i, amount := range ledger.amounts {
+			assert.Equal(amount, i)
+		}
+	}))
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// ledger is a state changed by Commands.
+type ledger struct {
+	amounts []int
+}
+
+// decode decodes an entry for the ledger.
+func (l *ledger) decode(data []byte) (actor.Command, error) {
+	amount, err := strconv.Atoi(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return &entry{l, amount}, nil
+}
+
+// entry is a Command adding an amount to a ledger.
+type entry struct {
+	ledger *ledger
+	amount int
+}
+
+// Apply implements actor.Command.
+func (e *entry) Apply() {
+	e.ledger.amounts = append(e.ledger.amounts, e.amount)
+}
+
+// encodeEntry encodes an entry.
+func encodeEntry(cmd actor.Command) ([]byte, error) {
+	return []byte(strconv.Itoa(cmd.(*entry).amount)), nil
+}
+
+// EOF
@@ -0,0 +1,67 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"sync"
+)
+
+//--------------------
+// STOP CONTEXT
+//--------------------
+
+// stopContext uses the parent context directly instead of deriving a
+// cancelable one from it. Its Done channel is only closed by stop, so
+// it is not registered at the parent. The backend selects on the Done
+// channel of the parent itself and stops the context then.
+type stopContext struct {
+	context.Context
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// newStopContext creates a stop context for the parent.
+func newStopContext(parent context.Context) *stopContext {
+	return &stopContext{
+		Context: parent,
+		done:    make(chan struct{}),
+	}
+}
+
+// stop closes the Done channel.
+func (c *stopContext) stop() {
+	c.stopOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// Done implements context.Context.
+func (c *stopContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err implements context.Context. It returns the error of the parent
+// if that one caused the stop, otherwise context.Canceled.
+func (c *stopContext) Err() error {
+	select {
+	case <-c.done:
+		if err := c.Context.Err(); err != nil {
+			return err
+		}
+		return context.Canceled
+	default:
+		return nil
+	}
+}
+
+// EOF
@@ -0,0 +1,60 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"fmt"
+)
+
+//--------------------
+// ERRORS
+//--------------------
+
+// ErrStopCondition is the error of an Actor stopped because the
+// predicate set with StopWhen returned true.
+var ErrStopCondition = errors.New("stop condition met")
+
+//--------------------
+// STOP WHEN
+//--------------------
+
+// StopWhen sets a predicate evaluated inside the backend after each
+// executed Action. When it returns true the Actor stops with the
+// reason ErrStopCondition, the remaining queued Actions are dropped
+// and the finalizer is called. So an Actor can terminate itself when
+// its work is complete. A later call replaces the predicate, nil
+// removes it.
+func (act *Actor) StopWhen(predicate func() bool) error {
+	if act.InActor() {
+		act.stopWhen = predicate
+		return nil
+	}
+	if err := act.DoSync(func() {
+		act.stopWhen = predicate
+	}); err != nil {
+		return fmt.Errorf("setting stop condition: %w", err)
+	}
+	return nil
+}
+
+// checkStopWhen stops the Actor if the stop predicate is true.
+func (act *Actor) checkStopWhen() {
+	if act.stopWhen == nil || act.ctx.Err() != nil || !act.stopWhen() {
+		return
+	}
+	err := ErrStopCondition
+	act.err.Store(&err)
+	act.cancel()
+}
+
+// EOF
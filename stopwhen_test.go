@@ -0,0 +1,58 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestStopWhen verifies that an Actor stops itself when the stop
+// predicate becomes true.
+func TestStopWhen(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	finalized := make(chan error, 1)
+	act, err := actor.Go(actor.WithFinalizer(func(err error) error {
+		finalized <- err
+		return err
+	}))
+	assert.OK(err)
+
+	tasks := 3
+	assert.OK(act.StopWhen(func() bool {
+		return tasks == 0
+	}))
+	for i := 0; i < 2; i++ {
+		assert.OK(act.DoSync(func() {
+			tasks--
+		}))
+	}
+	assert.False(act.IsDone())
+
+	assert.OK(act.DoSync(func() {
+		tasks--
+	}))
+	<-act.Done()
+	assert.True(errors.Is(<-finalized, actor.ErrStopCondition))
+	assert.True(errors.Is(act.Err(), actor.ErrStopCondition))
+	assert.True(errors.Is(act.DoAsync(func() {}), actor.ErrStopCondition))
+}
+
+// EOF
@@ -0,0 +1,98 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"time"
+)
+
+//--------------------
+// SHUTDOWN SUMMARY
+//--------------------
+
+// ShutdownSummary describes the life of an Actor for its finalizer.
+type ShutdownSummary struct {
+	// Started is the time the Actor has been started.
+	Started time.Time
+
+	// Uptime is the time the Actor has been running.
+	Uptime time.Duration
+
+	// Processed is the number of handled requests, including
+	// the ones skipped due to their canceled context.
+	Processed uint64
+
+	// Panics is the number of recovered panics.
+	Panics uint64
+
+	// Dropped is the number of queued requests not executed
+	// anymore due to the termination.
+	Dropped int
+
+	// HandedOver tells if the queued requests have been kept
+	// for a handover instead of being dropped.
+	HandedOver bool
+
+	// Reason is the error which stopped the Actor, the error of the
+	// context passed with WithContext, or nil if Stop has been called.
+	Reason error
+}
+
+// Clean tells if the Actor terminated without errors and
+// dropped requests.
+func (sum ShutdownSummary) Clean() bool {
+	return sum.Reason == nil && sum.Dropped == 0
+}
+
+// SummaryFinalizer defines the signature of a finalizer receiving a
+// ShutdownSummary in addition to the error of the Actor.
+type SummaryFinalizer func(sum ShutdownSummary, err error) error
+
+// summary creates the ShutdownSummary of the terminated Actor.
+func (act *Actor) summary(err error) ShutdownSummary {
+	reason := err
+	if reason == nil && act.parentCtx.Err() != nil {
+		reason = act.parentCtx.Err()
+	}
+	return ShutdownSummary{
+		Started:    act.started,
+		Uptime:     time.Since(act.started),
+		Processed:  act.handled.Load(),
+		Panics:     act.panics.Load(),
+		Dropped:    act.dropped,
+		HandedOver: act.handingOver.Load(),
+		Reason:     reason,
+	}
+}
+
+// ShutdownCause returns the error the Actor terminated with before
+// the finalizer has been called, including the errors of the closers
+// registered with Defer. Different from Err it is not replaced by the
+// finalizer. It is nil until the finalizer has been called.
+func (act *Actor) ShutdownCause() error {
+	if cause := act.cause.Load(); cause != nil {
+		return *cause
+	}
+	return nil
+}
+
+// FinalizerError returns the error returned by the finalizer, which
+// becomes the one returned by Err if it is not nil. It is nil until
+// the finalizer returned.
+func (act *Actor) FinalizerError() error {
+	if ferr := act.finalizerErr.Load(); ferr != nil {
+		return *ferr
+	}
+	return nil
+}
+
+// EOF
@@ -0,0 +1,151 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"time"
+)
+
+//--------------------
+// SYNC LATENCY BUDGET
+//--------------------
+
+// SetSyncLatencyBudget sets the time synchronous requests may wait
+// in the queue. Once a waiting synchronous request is older it is
+// executed ahead of all asynchronous ones queued, keeping the order
+// among the synchronous and among the asynchronous requests. For this
+// the backend takes all queued requests into a backlog, while senders
+// of asynchronous requests still block if the queue capacity is
+// exhausted. A budget of zero or less switches the promotion off.
+func (act *Actor) SetSyncLatencyBudget(d time.Duration) {
+	act.syncBudget.Store(int64(d))
+}
+
+// syncLatencyBudget returns the current sync latency budget.
+func (act *Actor) syncLatencyBudget() time.Duration {
+	return time.Duration(act.syncBudget.Load())
+}
+
+// sendSync sends a request whose sender waits for its execution.
+func (act *Actor) sendSync(req *request) error {
+	req.sync = true
+	return act.send(req)
+}
+
+// acquireSlot lets an asynchronous request wait for room in the queue
+// capacity while the sync latency budget is set, as the backlog does
+// not block the senders anymore.
+func (act *Actor) acquireSlot(req *request) error {
+	if req.sync || req.marker || !act.absorbing() {
+		return nil
+	}
+	for {
+		ok, freed := act.slots.acquire()
+		if ok {
+			req.extension().slotted = true
+			return nil
+		}
+		select {
+		case <-freed:
+		case <-req.ctx.Done():
+			return fmt.Errorf("action context sending: %v", req.ctx.Err())
+		case <-act.ctx.Done():
+			return act.alive()
+		}
+	}
+}
+
+// tryAcquireSlot is the non-blocking variant of acquireSlot.
+func (act *Actor) tryAcquireSlot(req *request) bool {
+	if req.sync || req.marker || !act.absorbing() {
+		return true
+	}
+	if ok, _ := act.slots.acquire(); !ok {
+		return false
+	}
+	req.extension().slotted = true
+	return true
+}
+
+// releaseSlot frees the room of a request leaving the queue.
+func (act *Actor) releaseSlot(req *request) {
+	if req.ext == nil || !req.ext.slotted {
+		return
+	}
+	req.ext.slotted = false
+	act.slots.release()
+}
+
+// nextPending takes the next pending request. With a sync latency
+// budget or priority classes the queued requests are taken into the
+// pending ones first. Then the oldest synchronous request is promoted
+// if its budget is exceeded, otherwise the classes are served by their
+// weights.
+func (act *Actor) nextPending() *request {
+	i := 0
+	if budget := act.syncLatencyBudget(); budget > 0 {
+		act.absorbQueued()
+		if j := act.overdueSync(budget); j > 0 {
+			i = j
+		}
+	}
+	if p := act.priority.Load(); p != nil && i == 0 {
+		act.absorbQueued()
+		i = p.pick(act.pending)
+	}
+	req := act.pending[i]
+	if i == 0 {
+		act.pending = act.pending[1:]
+	} else {
+		act.pending = append(act.pending[:i], act.pending[i+1:]...)
+	}
+	act.pendingLen.Store(int64(len(act.pending)))
+	act.serve(req)
+	return req
+}
+
+// absorbing tells if the queued requests are taken into the pending
+// ones to choose the next one, due to a sync latency budget, priority
+// classes, or an auto-scaling queue.
+func (act *Actor) absorbing() bool {
+	return act.syncLatencyBudget() > 0 || act.priority.Load() != nil || act.autoScale.Load() != nil
+}
+
+// absorbQueued moves the queued requests into the pending ones.
+func (act *Actor) absorbQueued() {
+	for {
+		select {
+		case req := <-act.requests:
+			act.arrive(req)
+			act.pending = append(act.pending, req)
+		default:
+			return
+		}
+	}
+}
+
+// overdueSync returns the index of the first pending synchronous
+// request if it waits longer than the budget, otherwise -1.
+func (act *Actor) overdueSync(budget time.Duration) int {
+	for i, req := range act.pending {
+		if req.sync {
+			if time.Since(req.submitted) >= budget {
+				return i
+			}
+			return -1
+		}
+	}
+	return -1
+}
+
+// EOF
@@ -0,0 +1,188 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestSyncLatencyBudget verifies that synchronous requests overtake
+// an asynchronous flood once their budget is exceeded, while the
+// asynchronous requests keep their order.
+func TestSyncLatencyBudget(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	const budget = 5 * time.Millisecond
+	act, err := actor.Go(actor.WithQueueCap(1024))
+	assert.OK(err)
+	defer act.Stop()
+	act.SetSyncLatencyBudget(budget)
+
+	latencies, ordered, executed := floodWithSyncs(act, 50)
+	assert.Length(latencies, 50)
+	assert.True(ordered)
+	assert.True(executed > 0)
+}
+
+// TestSyncLatencyBudgetPromotion verifies that an overdue synchronous
+// request is promoted ahead of the queued asynchronous ones, which
+// keep their order, while without budget it waits for them.
+func TestSyncLatencyBudgetPromotion(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	const budget = 5 * time.Millisecond
+	for _, test := range []struct {
+		budget time.Duration
+		want   []string
+	}{
+		{0, []string{"a0", "a1", "a2", "sync"}},
+		{budget, []string{"sync", "a0", "a1", "a2"}},
+	} {
+		act, err := actor.Go(actor.WithSyncLatencyBudget(test.budget))
+		assert.OK(err)
+
+		// Queue the requests behind a blocked Action.
+		var order []string
+		started := make(chan struct{})
+		release := make(chan struct{})
+		assert.OK(act.DoAsync(func() {
+			close(started)
+			<-release
+		}))
+		<-started
+		for i := 0; i < 3; i++ {
+			name := "a" + strconv.Itoa(i)
+			assert.OK(act.DoAsync(func() {
+				order = append(order, name)
+			}))
+		}
+		synced := make(chan error)
+		go func() {
+			synced <- act.DoSync(func() {
+				order = append(order, "sync")
+			})
+		}()
+		assert.Retry(func() bool { return act.Metrics().Queued == 4 }, 100, time.Millisecond)
+
+		// Exceed the budget before the backend continues.
+		time.Sleep(2 * budget)
+		close(release)
+		assert.OK(<-synced)
+		assert.OK(act.DoSync(func() {
+			assert.Equal(order, test.want)
+		}))
+		act.Stop()
+	}
+}
+
+//--------------------
+// BENCHMARKS
+//--------------------
+
+// BenchmarkSyncLatencyBudget measures the latency of synchronous
+// requests and the throughput of asynchronous ones during a flood
+// with and without a sync latency budget.
+func BenchmarkSyncLatencyBudget(b *testing.B) {
+	for _, budget := range []time.Duration{0, 5 * time.Millisecond} {
+		b.Run("budget-"+budget.String(), func(b *testing.B) {
+			act, err := actor.Go(actor.WithQueueCap(256))
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer act.Stop()
+			act.SetSyncLatencyBudget(budget)
+
+			started := time.Now()
+			latencies, _, executed := floodWithSyncs(act, b.N)
+			elapsed := time.Since(started)
+			b.ReportMetric(float64(p99(latencies).Microseconds()), "sync-p99-µs")
+			b.ReportMetric(float64(executed)/elapsed.Seconds(), "async/s")
+		})
+	}
+}
+
+//--------------------
+// HELPERS
+//--------------------
+
+// floodWithSyncs floods the Actor with asynchronous Actions from
+// multiple senders and measures the latencies of the given number
+// of synchronous ones. It also returns if the Actions of each
+// sender kept their order and how many have been executed.
+func floodWithSyncs(act *actor.Actor, syncs int) ([]time.Duration, bool, uint64) {
+	const senders = 8
+	var executed atomic.Uint64
+	var stop atomic.Bool
+	var wg sync.WaitGroup
+	last := make([]int, senders)
+	ordered := true
+	for s := 0; s < senders; s++ {
+		wg.Add(1)
+		go func(s int) {
+			defer wg.Done()
+			for i := 1; !stop.Load(); i++ {
+				i := i
+				if act.DoAsync(func() {
+					if last[s] != i-1 {
+						ordered = false
+					}
+					last[s] = i
+					time.Sleep(20 * time.Microsecond)
+					executed.Add(1)
+				}) != nil {
+					return
+				}
+			}
+		}(s)
+	}
+	// Let the queue fill up.
+	time.Sleep(50 * time.Millisecond)
+	latencies := make([]time.Duration, 0, syncs)
+	for i := 0; i < syncs; i++ {
+		started := time.Now()
+		if act.DoSync(func() {}) != nil {
+			break
+		}
+		latencies = append(latencies, time.Since(started))
+	}
+	stop.Store(true)
+	var result bool
+	act.DoSync(func() {})
+	wg.Wait()
+	act.DoSync(func() {
+		result = ordered
+	})
+	return latencies, result, executed.Load()
+}
+
+// p99 returns the 99th percentile of the durations.
+func p99(ds []time.Duration) time.Duration {
+	if len(ds) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), ds...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*99-1)/100]
+}
+
+// EOF
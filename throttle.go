@@ -0,0 +1,106 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+//--------------------
+// THROTTLE
+//--------------------
+
+// throttle coalesces the Actions submitted for one key.
+type throttle struct {
+	timer   *time.Timer
+	pending Action
+}
+
+// throttles manages the throttles of an Actor by key.
+type throttles struct {
+	mu    sync.Mutex
+	byKey map[string]*throttle
+}
+
+// DoThrottled runs the Action at most once per interval for the given
+// key. The first Action is sent immediately. Actions submitted during
+// the interval are dropped except the most recent one, which is sent
+// when the interval ends. So a burst of submissions, e.g. while dragging
+// a slider, results in the first and the latest Action being executed.
+func (act *Actor) DoThrottled(key string, interval time.Duration, action Action) error {
+	if err := act.admit(act.ctx, action != nil); err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return fmt.Errorf("%w: non-positive interval", ErrInvalid)
+	}
+	// Enqueue outside the lock, so that a full queue does not
+	// block the other keys and the timers.
+	act.throttles.mu.Lock()
+	if act.throttles.byKey == nil {
+		act.throttles.byKey = make(map[string]*throttle)
+	}
+	if t, ok := act.throttles.byKey[key]; ok {
+		// Within the interval, keep only the latest.
+		t.pending = action
+		act.throttles.mu.Unlock()
+		return nil
+	}
+	t := &throttle{}
+	act.throttles.byKey[key] = t
+	act.throttles.mu.Unlock()
+	err := act.DoAsync(action)
+	act.throttles.mu.Lock()
+	defer act.throttles.mu.Unlock()
+	if err != nil {
+		act.removeThrottle(key, t)
+		return err
+	}
+	t.timer = time.AfterFunc(interval, func() {
+		act.fireThrottle(key, t, interval)
+	})
+	return nil
+}
+
+// fireThrottle ends an interval of a throttle. A pending Action is
+// sent and starts a new interval, otherwise the throttle is removed.
+func (act *Actor) fireThrottle(key string, t *throttle, interval time.Duration) {
+	act.throttles.mu.Lock()
+	pending := t.pending
+	t.pending = nil
+	if pending == nil {
+		act.removeThrottle(key, t)
+		act.throttles.mu.Unlock()
+		return
+	}
+	act.throttles.mu.Unlock()
+	err := act.DoAsync(pending)
+	act.throttles.mu.Lock()
+	defer act.throttles.mu.Unlock()
+	if err != nil {
+		act.removeThrottle(key, t)
+		return
+	}
+	t.timer.Reset(interval)
+}
+
+// removeThrottle removes the throttle of the key if it is still the
+// given one. The mutex has to be held.
+func (act *Actor) removeThrottle(key string, t *throttle) {
+	if act.throttles.byKey[key] == t {
+		delete(act.throttles.byKey, key)
+	}
+}
+
+// EOF
@@ -0,0 +1,114 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestDoThrottled verifies that rapid submissions are throttled to
+// about one per interval while the latest one is executed.
+func TestDoThrottled(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	runs := 0
+	value := 0
+	interval := 50 * time.Millisecond
+	start := time.Now()
+	last := 0
+	for time.Since(start) < 250*time.Millisecond {
+		last++
+		v := last
+		assert.OK(act.DoThrottled("slider", interval, func() {
+			runs++
+			value = v
+		}))
+		time.Sleep(time.Millisecond)
+	}
+
+	// Wait for the trailing Action.
+	time.Sleep(2 * interval)
+	assert.OK(act.DoSync(func() {
+		assert.Range(runs, 5, 8)
+		assert.Equal(value, last)
+	}))
+
+	// Other keys are throttled independently.
+	other := false
+	assert.OK(act.DoThrottled("other", interval, func() {
+		other = true
+	}))
+	assert.OK(act.DoSync(func() {
+		assert.True(other)
+	}))
+
+	err = act.DoThrottled("slider", 0, func() {})
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestDoThrottledFullQueue verifies that a key waiting for a full
+// queue does not block the other keys.
+func TestDoThrottledFullQueue(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	interval := time.Second
+	assert.OK(act.DoThrottled("other", interval, func() {}))
+	assert.OK(act.DoSync(func() {}))
+
+	// Block the backend and fill the queue.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.DoAsync(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+	for act.Metrics().Queued < act.Metrics().Capacity {
+		assert.OK(act.DoAsync(func() {}))
+	}
+	waiting := make(chan error)
+	go func() {
+		waiting <- act.DoThrottled("blocked", interval, func() {})
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		done <- act.DoThrottled("other", interval, func() {})
+	}()
+	select {
+	case err := <-done:
+		assert.OK(err)
+	case <-time.After(time.Second):
+		t.Fatal("throttle of other key blocked by full queue")
+	}
+	close(release)
+	assert.OK(<-waiting)
+}
+
+// EOF
@@ -0,0 +1,144 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+//--------------------
+// TOKENS
+//--------------------
+
+// actorIDs provides the origins of the Tokens.
+var actorIDs atomic.Uint64
+
+// Token is a causal token of a write, so that later reads can wait
+// until it is reflected, e.g. by a replica. It consists of the origin
+// identifying the writing Actor and the apply sequence of the write.
+// The zero Token is reflected by all Actors.
+type Token struct {
+	Origin   uint64
+	Sequence uint64
+}
+
+// IsZero tells if the Token is the zero Token.
+func (t Token) IsZero() bool {
+	return t.Origin == 0 && t.Sequence == 0
+}
+
+// String implements fmt.Stringer.
+func (t Token) String() string {
+	return fmt.Sprintf("%d:%d", t.Origin, t.Sequence)
+}
+
+// tokens keeps the writes of other Actors reflected by an Actor.
+type tokens struct {
+	mu      sync.Mutex
+	applied map[uint64]uint64
+	changed chan struct{}
+}
+
+// DoSyncToken executes the Action like DoSyncSequenced and returns
+// the Token of the write.
+func (act *Actor) DoSyncToken(ctx context.Context, action Action) (Token, error) {
+	seq, err := act.DoSyncSequenced(ctx, action)
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{
+		Origin:   act.id,
+		Sequence: seq,
+	}, nil
+}
+
+// Applied records that the Actor reflects the write of the Token,
+// e.g. after a replica applied it. It is typically called inside
+// the Action applying the write. Readers waiting for the Token or an
+// earlier one of the same origin continue.
+func (act *Actor) Applied(token Token) {
+	t := &act.tokens
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.applied == nil {
+		t.applied = make(map[uint64]uint64)
+	}
+	if token.Sequence <= t.applied[token.Origin] {
+		return
+	}
+	t.applied[token.Origin] = token.Sequence
+	if t.changed != nil {
+		close(t.changed)
+		t.changed = nil
+	}
+}
+
+// AwaitToken waits until the Actor reflects the write of the Token.
+// The writing Actor itself does so as soon as the write returned,
+// others after the Token or a later one of the same origin has been
+// passed to Applied. It returns the context error if the context ends
+// first, and ErrDone or the error of the Actor if it terminates.
+func (act *Actor) AwaitToken(ctx context.Context, token Token) error {
+	if ctx == nil {
+		return fmt.Errorf("%w: nil context", ErrInvalid)
+	}
+	for {
+		if err := act.alive(); err != nil {
+			return err
+		}
+		reflected, changed := act.reflects(token)
+		if reflected {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-act.done:
+		}
+	}
+}
+
+// DoReadToken executes the Action like DoRead after the Actor
+// reflects the write of the Token, so it reads its own writes.
+func (act *Actor) DoReadToken(ctx context.Context, token Token, action Action) error {
+	if err := act.admitFor(ctx, action != nil, true); err != nil {
+		return err
+	}
+	if err := act.AwaitToken(ctx, token); err != nil {
+		return err
+	}
+	return act.DoRead(ctx, action)
+}
+
+// reflects tells if the Actor reflects the Token. Otherwise it returns
+// the channel closed at the next change.
+func (act *Actor) reflects(token Token) (bool, <-chan struct{}) {
+	if token.IsZero() || (token.Origin == act.id && token.Sequence <= act.Sequence()) {
+		return true, nil
+	}
+	t := &act.tokens
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if token.Sequence <= t.applied[token.Origin] {
+		return true, nil
+	}
+	if t.changed == nil {
+		t.changed = make(chan struct{})
+	}
+	return false, t.changed
+}
+
+// EOF
@@ -0,0 +1,104 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestTokenReadYourWrites verifies that a read on a lagging replica
+// with the Token of a write waits until the replica caught up.
+func TestTokenReadYourWrites(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	primary, err := actor.Go()
+	assert.OK(err)
+	defer primary.Stop()
+	replica, err := actor.Go()
+	assert.OK(err)
+	defer replica.Stop()
+	ctx := context.Background()
+
+	primaryValue := 0
+	replicaValue := 0
+	token, err := primary.DoSyncToken(ctx, func() {
+		primaryValue = 42
+	})
+	assert.OK(err)
+	assert.False(token.IsZero())
+
+	// The primary reflects its own writes immediately.
+	var value int
+	assert.OK(primary.DoReadToken(ctx, token, func() {
+		value = primaryValue
+	}))
+	assert.Equal(value, 42)
+
+	// The replica lags behind.
+	lag := 30 * time.Millisecond
+	go func() {
+		time.Sleep(lag)
+		replica.DoSync(func() {
+			replicaValue = 42
+			replica.Applied(token)
+		})
+	}()
+	start := time.Now()
+	assert.OK(replica.DoReadToken(ctx, token, func() {
+		value = replicaValue
+	}))
+	assert.True(time.Since(start) >= lag)
+	assert.Equal(value, 42)
+
+	// Earlier Tokens of the same origin are reflected too.
+	assert.OK(replica.AwaitToken(ctx, actor.Token{Origin: token.Origin, Sequence: 1}))
+	assert.OK(replica.AwaitToken(ctx, actor.Token{}))
+}
+
+// TestTokenNeverReflected verifies the errors if a Token is not
+// reflected.
+func TestTokenNeverReflected(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	primary, err := actor.Go()
+	assert.OK(err)
+	defer primary.Stop()
+	replica, err := actor.Go()
+	assert.OK(err)
+	ctx := context.Background()
+
+	token, err := primary.DoSyncToken(ctx, func() {})
+	assert.OK(err)
+
+	tctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	err = replica.DoReadToken(tctx, token, func() {})
+	assert.True(errors.Is(err, context.DeadlineExceeded))
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		replica.Stop()
+	}()
+	err = replica.AwaitToken(ctx, token)
+	assert.True(errors.Is(err, actor.ErrDone))
+}
+
+// EOF
@@ -0,0 +1,93 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//--------------------
+// TRACKED GOROUTINES
+//--------------------
+
+// defaultShutdownTimeout is the default time the termination waits
+// for tracked goroutines.
+const defaultShutdownTimeout = 5 * time.Second
+
+// tracked keeps the goroutines started with TrackedGo.
+type tracked struct {
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+	live   atomic.Int64
+}
+
+// TrackedGo runs the function in a goroutine owned by the Actor, e.g.
+// for side work of an Action like sending a mail. Its context is
+// canceled when the Actor stops, and the termination waits for it up
+// to the timeout set with WithShutdownTimeout before Done is closed.
+// So once an Actor is done its side effects are finished too. The
+// number of live tracked goroutines is part of the Metrics.
+func (act *Actor) TrackedGo(fn func(ctx context.Context)) error {
+	if fn == nil {
+		return fmt.Errorf("%w: nil function", ErrInvalid)
+	}
+	t := &act.tracked
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || act.IsDone() {
+		return ErrDone
+	}
+	t.wg.Add(1)
+	t.live.Add(1)
+	go func() {
+		defer t.wg.Done()
+		defer t.live.Add(-1)
+		act.applyLabels()
+		fn(act.ctx)
+	}()
+	return nil
+}
+
+// awaitTracked rejects further tracked goroutines, cancels the running
+// ones, and waits for them up to the shutdown timeout.
+func (act *Actor) awaitTracked() {
+	t := &act.tracked
+	t.mu.Lock()
+	t.closed = true
+	t.mu.Unlock()
+	if t.live.Load() == 0 {
+		return
+	}
+	act.cancel()
+	timeout := act.shutdownTimeout
+	if timeout <= 0 {
+		timeout = defaultShutdownTimeout
+	}
+	finished := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(finished)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-finished:
+	case <-timer.C:
+	}
+}
+
+// EOF
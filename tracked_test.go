@@ -0,0 +1,98 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestTrackedGoDelaysDone verifies that the termination waits for
+// tracked goroutines.
+func TestTrackedGoDelaysDone(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	goroutines := runtime.NumGoroutine()
+	act, err := actor.Go()
+	assert.OK(err)
+
+	var finished atomic.Bool
+	release := make(chan struct{})
+	assert.OK(act.DoSync(func() {
+		assert.OK(act.TrackedGo(func(ctx context.Context) {
+			// Side work ignoring the cancelation.
+			<-release
+			time.Sleep(20 * time.Millisecond)
+			finished.Store(true)
+		}))
+	}))
+	assert.Equal(act.Metrics().Tracked, int64(1))
+	close(release)
+	act.Stop()
+	<-act.Done()
+	assert.True(finished.Load())
+	assert.Equal(act.Metrics().Tracked, int64(0))
+
+	err = act.TrackedGo(func(ctx context.Context) {})
+	assert.True(errors.Is(err, actor.ErrDone))
+	assert.Retry(func() bool {
+		return runtime.NumGoroutine() <= goroutines
+	}, 100, 10*time.Millisecond)
+}
+
+// TestTrackedGoCanceled verifies that tracked goroutines are canceled
+// when the Actor stops and waited for only up to the timeout.
+func TestTrackedGoCanceled(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithShutdownTimeout(20 * time.Millisecond))
+	assert.OK(err)
+
+	canceled := make(chan struct{})
+	release := make(chan struct{})
+	assert.OK(act.TrackedGo(func(ctx context.Context) {
+		<-ctx.Done()
+		close(canceled)
+	}))
+	assert.OK(act.TrackedGo(func(ctx context.Context) {
+		<-release
+	}))
+	assert.Equal(act.Metrics().Tracked, int64(2))
+
+	// A failing Action stops the Actor too.
+	start := time.Now()
+	assert.OK(act.DoAsync(func() { panic("ouch") }))
+	<-act.Done()
+	<-canceled
+	elapsed := time.Since(start)
+	assert.True(elapsed >= 20*time.Millisecond, "waited for timeout")
+	assert.True(elapsed < time.Second, "bounded by timeout")
+	assert.Equal(act.Metrics().Tracked, int64(1))
+	close(release)
+	assert.Retry(func() bool { return act.Metrics().Tracked == 0 }, 100, time.Millisecond)
+
+	assert.True(errors.Is(act.TrackedGo(nil), actor.ErrInvalid))
+	_, err = actor.Go(actor.WithShutdownTimeout(0))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
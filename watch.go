@@ -0,0 +1,236 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+//--------------------
+// FIELD WATCHER
+//--------------------
+
+// Projection returns a value derived from the state guarded by an
+// Actor. It is called inside the backend.
+type Projection func() any
+
+// Equal compares an old and a new projected value. It is called
+// inside the backend, so it must be cheap.
+type Equal func(old, new any) bool
+
+// WatchOption defines the signature of a field watch option
+// setting function.
+type WatchOption func(w *fieldWatcher) error
+
+// FieldEqual sets a custom equality for the named projection, e.g.
+// ignoring timestamps or comparing slices as sets. Changes it
+// considers equal are suppressed.
+func FieldEqual(name string, eq Equal) WatchOption {
+	return func(w *fieldWatcher) error {
+		if _, ok := w.projections[name]; !ok || eq == nil {
+			return fmt.Errorf("%w: invalid field equality for %q", ErrInvalid, name)
+		}
+		w.equals[name] = eq
+		return nil
+	}
+}
+
+// FieldChange describes the change of a projected value.
+type FieldChange struct {
+	Name string
+	Old  any
+	New  any
+}
+
+// fieldWatcher evaluates the projections after each Action and
+// forwards the changes to its subscriber.
+type fieldWatcher struct {
+	names       []string
+	projections map[string]Projection
+	equals      map[string]Equal
+	values      map[string]any
+	changes     chan FieldChange
+	stop        chan struct{}
+	stopOnce    sync.Once
+
+	mu      sync.Mutex
+	order   []string
+	pending map[string]FieldChange
+	notify  chan struct{}
+}
+
+// WatchFields subscribes to the changes of the projected values. The
+// projections are evaluated inside the backend after each executed
+// Action and one FieldChange per changed projection is emitted. If the
+// subscriber lags behind the buffer, the changes of a projection are
+// coalesced, keeping the oldest old and the newest new value. Values
+// of comparable types are compared with ==, all others with
+// reflect.DeepEqual, unless a custom equality is set with FieldEqual.
+// The returned function unsubscribes, the channel is closed then or
+// when the Actor is done.
+func (act *Actor) WatchFields(
+	projections map[string]Projection,
+	buffer int,
+	options ...WatchOption) (<-chan FieldChange, func(), error) {
+	if len(projections) == 0 || buffer < 0 {
+		return nil, nil, fmt.Errorf("%w: watch projections or buffer", ErrInvalid)
+	}
+	w := &fieldWatcher{
+		projections: make(map[string]Projection, len(projections)),
+		equals:      make(map[string]Equal),
+		values:      make(map[string]any, len(projections)),
+		changes:     make(chan FieldChange, buffer),
+		stop:        make(chan struct{}),
+		pending:     make(map[string]FieldChange),
+		notify:      make(chan struct{}, 1),
+	}
+	for name, projection := range projections {
+		if projection == nil {
+			return nil, nil, fmt.Errorf("%w: nil projection %q", ErrInvalid, name)
+		}
+		w.names = append(w.names, name)
+		w.projections[name] = projection
+	}
+	sort.Strings(w.names)
+	for _, option := range options {
+		if err := option(w); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := act.DoSync(func() {
+		for _, name := range w.names {
+			w.values[name] = w.projections[name]()
+		}
+		act.watchers = append(act.watchers, w)
+	}); err != nil {
+		return nil, nil, err
+	}
+	go w.forward(act.done)
+	unsubscribe := func() {
+		w.stopOnce.Do(func() {
+			close(w.stop)
+			act.DoAsync(func() {
+				act.removeWatcher(w)
+			})
+		})
+	}
+	return w.changes, unsubscribe, nil
+}
+
+// evaluateWatchers lets all field watchers evaluate their projections.
+func (act *Actor) evaluateWatchers() {
+	for _, w := range act.watchers {
+		w.evaluate()
+	}
+}
+
+// removeWatcher removes the field watcher from the Actor.
+func (act *Actor) removeWatcher(w *fieldWatcher) {
+	for i, aw := range act.watchers {
+		if aw == w {
+			act.watchers = append(act.watchers[:i], act.watchers[i+1:]...)
+			return
+		}
+	}
+}
+
+// evaluate calls the projections and collects the changes.
+func (w *fieldWatcher) evaluate() {
+	changed := false
+	for _, name := range w.names {
+		old := w.values[name]
+		value := w.projections[name]()
+		equal := equalValues
+		if eq, ok := w.equals[name]; ok {
+			equal = eq
+		}
+		if equal(old, value) {
+			continue
+		}
+		w.values[name] = value
+		w.mu.Lock()
+		if change, ok := w.pending[name]; ok {
+			change.New = value
+			w.pending[name] = change
+		} else {
+			w.order = append(w.order, name)
+			w.pending[name] = FieldChange{Name: name, Old: old, New: value}
+		}
+		w.mu.Unlock()
+		changed = true
+	}
+	if changed {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// forward sends the pending changes to the subscriber until it
+// unsubscribes or the Actor is done.
+func (w *fieldWatcher) forward(done <-chan struct{}) {
+	defer close(w.changes)
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-done:
+			return
+		case <-w.notify:
+		}
+		for {
+			change, ok := w.next()
+			if !ok {
+				break
+			}
+			select {
+			case <-w.stop:
+				return
+			case <-done:
+				return
+			case w.changes <- change:
+			}
+		}
+	}
+}
+
+// next takes the oldest pending change.
+func (w *fieldWatcher) next() (FieldChange, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.order) == 0 {
+		return FieldChange{}, false
+	}
+	name := w.order[0]
+	w.order = w.order[1:]
+	change := w.pending[name]
+	delete(w.pending, name)
+	return change, true
+}
+
+// equalValues compares two projected values.
+func equalValues(a, b any) bool {
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	if ta != tb {
+		return false
+	}
+	if ta == nil || !ta.Comparable() {
+		return reflect.DeepEqual(a, b)
+	}
+	return a == b
+}
+
+// EOF
@@ -0,0 +1,157 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWatchFields verifies that only the changed projections
+// are emitted.
+func TestWatchFields(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	name := "alice"
+	tags := []string{"a"}
+	count := 0
+	changes, unsubscribe, err := act.WatchFields(map[string]actor.Projection{
+		"name": func() any { return name },
+		"tags": func() any { return append([]string{}, tags...) },
+	}, 10)
+	assert.OK(err)
+
+	assert.OK(act.DoSync(func() { count++ }))
+	assert.OK(act.DoSync(func() { name = "bob" }))
+	assert.OK(act.DoSync(func() { tags = append(tags, "b") }))
+	assert.OK(act.DoSync(func() { name = "bob" }))
+
+	change := <-changes
+	assert.Equal(change, actor.FieldChange{Name: "name", Old: "alice", New: "bob"})
+	change = <-changes
+	assert.Equal(change.Name, "tags")
+	assert.Equal(change.Old, []string{"a"})
+	assert.Equal(change.New, []string{"a", "b"})
+
+	unsubscribe()
+	unsubscribe()
+	assert.OK(act.DoSync(func() { name = "carol" }))
+	_, ok := <-changes
+	assert.False(ok)
+
+	_, _, err = act.WatchFields(nil, 1)
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// TestWatchFieldsCoalescing verifies the coalescing of changes
+// for a lagging subscriber and the closing on stop.
+func TestWatchFieldsCoalescing(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+
+	counter := 0
+	other := 0
+	changes, unsubscribe, err := act.WatchFields(map[string]actor.Projection{
+		"counter": func() any { return counter },
+		"other":   func() any { return other },
+	}, 0)
+	assert.OK(err)
+	defer unsubscribe()
+
+	for i := 0; i < 100; i++ {
+		assert.OK(act.DoSync(func() { counter++ }))
+	}
+	assert.OK(act.DoSync(func() { other = 1 }))
+	time.Sleep(10 * time.Millisecond)
+
+	// The first change may be taken before the stalling,
+	// the rest is coalesced.
+	received := []actor.FieldChange{}
+	for len(received) < 3 {
+		change := <-changes
+		received = append(received, change)
+		if change.Name == "other" {
+			break
+		}
+	}
+	assert.True(len(received) <= 3)
+	last := received[len(received)-1]
+	assert.Equal(last, actor.FieldChange{Name: "other", Old: 0, New: 1})
+	counted := received[len(received)-2]
+	assert.Equal(counted.Name, "counter")
+	assert.Equal(counted.New, 100)
+
+	act.Stop()
+	_, ok := <-changes
+	assert.False(ok)
+}
+
+// TestWatchFieldsEqual verifies the suppression of changes considered
+// equal by a custom equality.
+func TestWatchFieldsEqual(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	type status struct {
+		State   string
+		Updated time.Time
+	}
+	current := status{"idle", time.Now()}
+	sameState := func(old, new any) bool {
+		return old.(status).State == new.(status).State
+	}
+	changes, unsubscribe, err := act.WatchFields(map[string]actor.Projection{
+		"status": func() any { return current },
+	}, 10, actor.FieldEqual("status", sameState))
+	assert.OK(err)
+	defer unsubscribe()
+
+	// Timestamp-only changes are suppressed.
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {
+			current.Updated = time.Now()
+		}))
+	}
+	assert.OK(act.DoSync(func() {
+		current = status{"busy", time.Now()}
+	}))
+	change := <-changes
+	assert.Equal(change.Old.(status).State, "idle")
+	assert.Equal(change.New.(status).State, "busy")
+	select {
+	case change := <-changes:
+		t.Fatalf("unexpected change: %v", change)
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	_, _, err = act.WatchFields(map[string]actor.Projection{
+		"status": func() any { return current },
+	}, 1, actor.FieldEqual("unknown", sameState))
+	assert.True(errors.Is(err, actor.ErrInvalid))
+}
+
+// EOF
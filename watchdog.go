@@ -0,0 +1,151 @@
+// Tideland Go Actor
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor // import "tideland.dev/go/actor"
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"runtime"
+	"time"
+)
+
+//--------------------
+// WATCHDOG
+//--------------------
+
+// ActionInfo describes an Action currently executed by an Actor.
+type ActionInfo struct {
+	// Name is the name of the Action function as known by
+	// the runtime, e.g. "pkg.(*Type).Method.func1".
+	Name string
+
+	// Started is the time the execution started.
+	Started time.Time
+}
+
+// watchdogExemptKey is the context key marking exempted requests.
+type watchdogExemptKey struct{}
+
+// WithWatchdogExempt returns a context exempting the Actions sent
+// with it from the watchdog, e.g. known slow ones.
+func WithWatchdogExempt(ctx context.Context) context.Context {
+	return context.WithValue(ctx, watchdogExemptKey{}, true)
+}
+
+// execution tracks the currently executed request.
+type execution struct {
+	req     *request
+	started time.Time
+}
+
+// watchStuck arms the watchdog timer for the Action about to be
+// executed. If it runs longer than the configured duration the Actor
+// is considered stuck and the handler is called once. The timer is
+// stopped when the Action completes, so the next one starts a new
+// episode. It returns nil if no watchdog is configured or the Action
+// is exempted.
+func (act *Actor) watchStuck(req *request) *time.Timer {
+	if act.watchdog <= 0 || req.exempt {
+		return nil
+	}
+	return time.AfterFunc(act.watchdog, act.onStuck)
+}
+
+// StuckActions returns the currently executed Action if it exceeded
+// the watchdog duration. Without a watchdog it always returns nil.
+func (act *Actor) StuckActions() []ActionInfo {
+	if act.watchdog <= 0 {
+		return nil
+	}
+	exe := act.executing.Load()
+	if exe == nil || time.Since(exe.started) < act.watchdog {
+		return nil
+	}
+	return []ActionInfo{{
+		Name:    funcName(exe.req.origin),
+		Started: exe.started,
+	}}
+}
+
+// trackExecution stores the request as currently executed if a
+// watchdog is configured and the Action is not exempted. The returned
+// function clears it again.
+func (act *Actor) trackExecution(req *request) func() {
+	if act.watchdog <= 0 || req.exempt {
+		return func() {}
+	}
+	act.executing.Store(&execution{
+		req:     req,
+		started: time.Now(),
+	})
+	return func() {
+		act.executing.Store(nil)
+	}
+}
+
+// isExempt checks if the request has been sent with a context
+// exempting it from the watchdog.
+func (act *Actor) isExempt(req *request) bool {
+	if act.watchdog <= 0 || req.marker {
+		return false
+	}
+	exempt, _ := req.ctx.Value(watchdogExemptKey{}).(bool)
+	return exempt
+}
+
+// funcName returns the runtime name of the function.
+func funcName(f any) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return "unknown"
+	}
+	fn := runtime.FuncForPC(v.Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return fn.Name()
+}
+
+// DoWatched executes the action synchronously and watches it for a
+// single risky call instead of a global watchdog. If the action runs
+// longer than d onOverrun is called once, e.g. to raise an alert. The
+// action is not abandoned, the caller waits for its completion however
+// late and receives its error together with the information if it
+// overran.
+func (act *Actor) DoWatched(d time.Duration, action func() error, onOverrun func()) (bool, error) {
+	if err := act.admit(context.Background(), action != nil); err != nil {
+		return false, err
+	}
+	if d <= 0 || onOverrun == nil {
+		return false, fmt.Errorf("%w: invalid watch", ErrInvalid)
+	}
+	var overran bool
+	var err error
+	req := newRequest(context.Background(), func(context.Context) {
+		timer := time.AfterFunc(d, onOverrun)
+		defer func() {
+			overran = !timer.Stop()
+		}()
+		err = action()
+	})
+	req.origin = action
+	if serr := act.sendSync(req); serr != nil {
+		return false, serr
+	}
+	if werr := act.wait(req); werr != nil {
+		return false, werr
+	}
+	return overran, err
+}
+
+// EOF
@@ -0,0 +1,175 @@
+// Tideland Go Actor - Unit Tests
+//
+// Copyright (C) 2019-2023 Frank Mueller / Tideland / Oldenburg / Germany
+//
+// All rights reserved. Use of this source code is governed
+// by the new BSD license.
+
+package actor_test
+
+//--------------------
+// IMPORTS
+//--------------------
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tideland.dev/go/audit/asserts"
+
+	"tideland.dev/go/actor"
+)
+
+//--------------------
+// TESTS
+//--------------------
+
+// TestWatchdog verifies that the watchdog fires once per stuck Action
+// without aborting it.
+func TestWatchdog(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var stuck atomic.Int32
+	act, err := actor.Go(actor.WithWatchdog(30*time.Millisecond, func() {
+		stuck.Add(1)
+	}))
+	assert.OK(err)
+	defer act.Stop()
+
+	// Quick Actions don't fire.
+	for i := 0; i < 5; i++ {
+		assert.OK(act.DoSync(func() {}))
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(stuck.Load(), int32(0))
+
+	// Long blocking Action fires once after the duration.
+	firedInside := int32(-1)
+	start := time.Now()
+	assert.OK(act.DoSync(func() {
+		time.Sleep(45 * time.Millisecond)
+		firedInside = stuck.Load()
+		time.Sleep(100 * time.Millisecond)
+	}))
+	assert.True(time.Since(start) >= 145*time.Millisecond)
+	assert.Equal(firedInside, int32(1))
+	assert.Equal(stuck.Load(), int32(1))
+
+	// Next stuck Action is a new episode.
+	assert.OK(act.DoSync(func() {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	assert.Equal(stuck.Load(), int32(2))
+}
+
+// TestStuckActions verifies the introspection of stuck Actions.
+func TestStuckActions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go(actor.WithWatchdog(20*time.Millisecond, func() {}))
+	assert.OK(err)
+	defer act.Stop()
+
+	assert.Empty(act.StuckActions())
+
+	release := make(chan struct{})
+	started := time.Now()
+	assert.OK(act.DoAsync(func() {
+		<-release
+	}))
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(act.StuckActions())
+	time.Sleep(30 * time.Millisecond)
+
+	stuck := act.StuckActions()
+	assert.Length(stuck, 1)
+	assert.Substring("TestStuckActions.func", stuck[0].Name)
+	assert.True(stuck[0].Started.After(started))
+
+	close(release)
+	assert.OK(act.DoSync(func() {}))
+	assert.Empty(act.StuckActions())
+}
+
+// TestDoWatched verifies watching a single Action which still
+// returns its result after an overrun.
+func TestDoWatched(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	act, err := actor.Go()
+	assert.OK(err)
+	defer act.Stop()
+
+	var overruns atomic.Int32
+	onOverrun := func() {
+		overruns.Add(1)
+	}
+
+	// Fast Action.
+	overran, err := act.DoWatched(50*time.Millisecond, func() error {
+		return nil
+	}, onOverrun)
+	assert.NoError(err)
+	assert.False(overran)
+
+	// Overrunning Action.
+	overran, err = act.DoWatched(20*time.Millisecond, func() error {
+		time.Sleep(60 * time.Millisecond)
+		return errors.New("late but real")
+	}, onOverrun)
+	assert.ErrorMatch(err, "late but real")
+	assert.True(overran)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(overruns.Load(), int32(1))
+}
+
+// TestWatchdogExemptions verifies that exempted Actions are not
+// watched while others are.
+func TestWatchdogExemptions(t *testing.T) {
+	assert := asserts.NewTesting(t, asserts.FailStop)
+	var stuck atomic.Int32
+	exempt := make(chan bool, 3)
+	act, err := actor.Go(
+		actor.WithWatchdog(20*time.Millisecond, func() {
+			stuck.Add(1)
+		}),
+		actor.WithInterceptors(func(meta *actor.RequestMeta) error {
+			exempt <- meta.WatchdogExempt
+			return nil
+		}),
+	)
+	assert.OK(err)
+	defer act.Stop()
+
+	exemptCtx := actor.WithWatchdogExempt(context.Background())
+	assert.OK(act.DoSyncWithContext(exemptCtx, generateStatement))
+	assert.True(<-exempt)
+	assert.Equal(stuck.Load(), int32(0))
+
+	assert.OK(act.DoSync(slowTransfer))
+	assert.False(<-exempt)
+	assert.Equal(stuck.Load(), int32(1))
+
+	// The same function is only exempted when sent as such.
+	assert.OK(act.DoAsyncWithContext(exemptCtx, slowTransfer))
+	assert.True(<-exempt)
+	assert.OK(act.DoSync(func() {}))
+	<-exempt
+	assert.Equal(stuck.Load(), int32(1))
+}
+
+//--------------------
+// HELPER
+//--------------------
+
+// generateStatement is a known slow Action.
+func generateStatement() {
+	time.Sleep(60 * time.Millisecond)
+}
+
+// slowTransfer is an unexpectedly slow Action.
+func slowTransfer() {
+	time.Sleep(60 * time.Millisecond)
+}
+
+// EOF